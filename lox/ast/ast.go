@@ -6,6 +6,7 @@ import (
 
 // Expr 表达式接口
 type Expr interface {
+	Node
 	Accept(visitor ExprVisitor) interface{}
 }
 
@@ -20,10 +21,21 @@ type ExprVisitor interface {
 	VisitAssignExpr(expr *Assign) interface{}
 	VisitLogicalExpr(expr *Logical) interface{}
 	VisitCallExpr(expr *Call) interface{}
+	VisitGetExpr(expr *Get) interface{}
+	VisitSetExpr(expr *Set) interface{}
+	VisitThisExpr(expr *This) interface{}
+	VisitSuperExpr(expr *Super) interface{}
+	VisitListExpr(expr *List) interface{}
+	VisitIndexExpr(expr *Index) interface{}
+	VisitIndexSetExpr(expr *IndexSet) interface{}
+	VisitMapExpr(expr *Map) interface{}
+	VisitInterpolationExpr(expr *Interpolation) interface{}
+	VisitLambdaExpr(expr *Lambda) interface{}
 }
 
 // Binary 二元表达式
 type Binary struct {
+	Position
 	Left     Expr
 	Operator *token.Token
 	Right    Expr
@@ -45,6 +57,7 @@ func NewBinary(left Expr, operator *token.Token, right Expr) *Binary {
 
 // Grouping 分组表达式
 type Grouping struct {
+	Position
 	Expression Expr
 }
 
@@ -62,6 +75,7 @@ func NewGrouping(expression Expr) *Grouping {
 
 // Literal 字面量表达式
 type Literal struct {
+	Position
 	Value interface{}
 }
 
@@ -79,6 +93,7 @@ func NewLiteral(value interface{}) *Literal {
 
 // Unary 一元表达式
 type Unary struct {
+	Position
 	Operator *token.Token
 	Right    Expr
 }
@@ -98,7 +113,9 @@ func NewUnary(operator *token.Token, right Expr) *Unary {
 
 // Ternary 三元条件表达式
 type Ternary struct {
+	Position
 	Condition  Expr
+	Question   *token.Token // '?'运算符
 	ThenBranch Expr
 	ElseBranch Expr
 }
@@ -109,9 +126,10 @@ func (t *Ternary) Accept(visitor ExprVisitor) interface{} {
 }
 
 // NewTernary 创建三元表达式
-func NewTernary(condition Expr, thenBranch Expr, elseBranch Expr) *Ternary {
+func NewTernary(condition Expr, question *token.Token, thenBranch Expr, elseBranch Expr) *Ternary {
 	return &Ternary{
 		Condition:  condition,
+		Question:   question,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
 	}
@@ -119,6 +137,7 @@ func NewTernary(condition Expr, thenBranch Expr, elseBranch Expr) *Ternary {
 
 // Variable 变量表达式
 type Variable struct {
+	Position
 	Name *token.Token
 }
 
@@ -136,6 +155,7 @@ func NewVariable(name *token.Token) *Variable {
 
 // Assign 赋值表达式
 type Assign struct {
+	Position
 	Name  *token.Token
 	Value Expr
 }
@@ -155,6 +175,7 @@ func NewAssign(name *token.Token, value Expr) *Assign {
 
 // Logical 逻辑表达式
 type Logical struct {
+	Position
 	Left     Expr
 	Operator *token.Token
 	Right    Expr
@@ -176,9 +197,11 @@ func NewLogical(left Expr, operator *token.Token, right Expr) *Logical {
 
 // Call 函数调用表达式
 type Call struct {
-	Callee    Expr         // 被调用的表达式
-	Paren     *token.Token // 右括号标记(用于错误报告)
-	Arguments []Expr       // 参数列表
+	Position
+	Callee    Expr           // 被调用的表达式
+	Paren     *token.Token   // 右括号标记(用于错误报告)
+	Arguments []Expr         // 参数列表，命名参数位于位置参数之后
+	Names     []*token.Token // 命名参数的名称，与Arguments末尾的len(Names)个参数一一对应
 }
 
 // Accept 接受访问者
@@ -194,3 +217,225 @@ func NewCall(callee Expr, paren *token.Token, arguments []Expr) *Call {
 		Arguments: arguments,
 	}
 }
+
+// Positional 返回按位置传入的参数
+func (c *Call) Positional() []Expr {
+	return c.Arguments[:len(c.Arguments)-len(c.Names)]
+}
+
+// Name 返回第i个参数的名称，按位置传入的参数返回nil
+func (c *Call) Name(i int) *token.Token {
+	if positional := len(c.Arguments) - len(c.Names); i >= positional {
+		return c.Names[i-positional]
+	}
+	return nil
+}
+
+// Get 属性访问表达式
+type Get struct {
+	Position
+	Object Expr         // 被访问的对象
+	Name   *token.Token // 属性名
+}
+
+// Accept 接受访问者
+func (g *Get) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitGetExpr(g)
+}
+
+// NewGet 创建属性访问表达式
+func NewGet(object Expr, name *token.Token) *Get {
+	return &Get{
+		Object: object,
+		Name:   name,
+	}
+}
+
+// Set 属性赋值表达式
+type Set struct {
+	Position
+	Object Expr         // 被赋值的对象
+	Name   *token.Token // 属性名
+	Value  Expr         // 新值
+}
+
+// Accept 接受访问者
+func (s *Set) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSetExpr(s)
+}
+
+// NewSet 创建属性赋值表达式
+func NewSet(object Expr, name *token.Token, value Expr) *Set {
+	return &Set{
+		Object: object,
+		Name:   name,
+		Value:  value,
+	}
+}
+
+// This this表达式
+type This struct {
+	Position
+	Keyword *token.Token
+}
+
+// Accept 接受访问者
+func (t *This) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitThisExpr(t)
+}
+
+// NewThis 创建this表达式
+func NewThis(keyword *token.Token) *This {
+	return &This{
+		Keyword: keyword,
+	}
+}
+
+// Super super方法访问表达式
+type Super struct {
+	Position
+	Keyword *token.Token // super关键字
+	Method  *token.Token // 方法名
+}
+
+// Accept 接受访问者
+func (s *Super) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSuperExpr(s)
+}
+
+// NewSuper 创建super表达式
+func NewSuper(keyword *token.Token, method *token.Token) *Super {
+	return &Super{
+		Keyword: keyword,
+		Method:  method,
+	}
+}
+
+// List 列表字面量表达式
+type List struct {
+	Position
+	Bracket  *token.Token // 左方括号(用于错误报告)
+	Elements []Expr       // 元素列表
+}
+
+// Accept 接受访问者
+func (l *List) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitListExpr(l)
+}
+
+// NewList 创建列表字面量表达式
+func NewList(bracket *token.Token, elements []Expr) *List {
+	return &List{
+		Bracket:  bracket,
+		Elements: elements,
+	}
+}
+
+// Index 下标访问表达式
+type Index struct {
+	Position
+	Object  Expr         // 被访问的对象
+	Bracket *token.Token // 左方括号(用于错误报告)
+	Index   Expr         // 下标
+}
+
+// Accept 接受访问者
+func (i *Index) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIndexExpr(i)
+}
+
+// NewIndex 创建下标访问表达式
+func NewIndex(object Expr, bracket *token.Token, index Expr) *Index {
+	return &Index{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+// IndexSet 下标赋值表达式
+type IndexSet struct {
+	Position
+	Object  Expr         // 被赋值的对象
+	Bracket *token.Token // 左方括号(用于错误报告)
+	Index   Expr         // 下标
+	Value   Expr         // 新值
+}
+
+// Accept 接受访问者
+func (i *IndexSet) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIndexSetExpr(i)
+}
+
+// NewIndexSet 创建下标赋值表达式
+func NewIndexSet(object Expr, bracket *token.Token, index Expr, value Expr) *IndexSet {
+	return &IndexSet{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+		Value:   value,
+	}
+}
+
+// Map 映射字面量表达式
+type Map struct {
+	Position
+	Brace  *token.Token // 左花括号(用于错误报告)
+	Keys   []Expr       // 键列表
+	Values []Expr       // 值列表，与键一一对应
+}
+
+// Accept 接受访问者
+func (m *Map) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitMapExpr(m)
+}
+
+// NewMap 创建映射字面量表达式
+func NewMap(brace *token.Token, keys []Expr, values []Expr) *Map {
+	return &Map{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+	}
+}
+
+// Interpolation 插值字符串表达式，例如"Point(${x}, ${y})"
+type Interpolation struct {
+	Position
+	Quote *token.Token // 字符串的第一部分(用于错误报告)
+	Parts []Expr       // 按顺序排列的字符串字面量和插值表达式，空的字面量已被省略
+}
+
+// Accept 接受访问者
+func (i *Interpolation) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitInterpolationExpr(i)
+}
+
+// NewInterpolation 创建插值字符串表达式
+func NewInterpolation(quote *token.Token, parts []Expr) *Interpolation {
+	return &Interpolation{
+		Quote: quote,
+		Parts: parts,
+	}
+}
+
+// AnonymousName 匿名函数使用的函数名，它不是合法的标识符，因此不会与具名函数混淆
+const AnonymousName = "(anonymous)"
+
+// Lambda 匿名函数表达式，例如 fun (a, b) { return a + b; } 或 (a, b) => a + b
+type Lambda struct {
+	Position
+	Function *Function // 函数声明，函数名为AnonymousName，位于fun关键字或=>处
+}
+
+// Accept 接受访问者
+func (l *Lambda) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLambdaExpr(l)
+}
+
+// NewLambda 创建匿名函数表达式
+func NewLambda(function *Function) *Lambda {
+	return &Lambda{
+		Function: function,
+	}
+}
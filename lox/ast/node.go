@@ -0,0 +1,27 @@
+package ast
+
+import (
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Node 所有表达式和语句共有的接口
+type Node interface {
+	Span() token.Span
+	SetSpan(span token.Span)
+}
+
+// Position 记录节点在源代码中的区间，嵌入到每个节点中
+// 区间由解析器在创建节点后设置，合成的节点(例如for循环展开生成的while)可能没有区间
+type Position struct {
+	span token.Span
+}
+
+// Span 返回节点覆盖的源代码区间
+func (p *Position) Span() token.Span {
+	return p.span
+}
+
+// SetSpan 设置节点覆盖的源代码区间
+func (p *Position) SetSpan(span token.Span) {
+	p.span = span
+}
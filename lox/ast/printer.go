@@ -72,6 +72,9 @@ func (p *AstPrinter) VisitCallExpr(expr *Call) interface{} {
 		if i > 0 {
 			builder.WriteString(", ")
 		}
+		if name := expr.Name(i); name != nil {
+			builder.WriteString(name.Lexeme + ": ")
+		}
 		builder.WriteString(fmt.Sprintf("%v", arg.Accept(p)))
 	}
 
@@ -80,6 +83,73 @@ func (p *AstPrinter) VisitCallExpr(expr *Call) interface{} {
 	return builder.String()
 }
 
+// VisitGetExpr 访问属性访问表达式
+func (p *AstPrinter) VisitGetExpr(expr *Get) interface{} {
+	return p.parenthesize2(".", expr.Object, NewLiteral(expr.Name.Lexeme))
+}
+
+// VisitSetExpr 访问属性赋值表达式
+func (p *AstPrinter) VisitSetExpr(expr *Set) interface{} {
+	target := p.parenthesize2(".", expr.Object, NewLiteral(expr.Name.Lexeme))
+	return p.parenthesize2("=", target, expr.Value)
+}
+
+// VisitThisExpr 访问this表达式
+func (p *AstPrinter) VisitThisExpr(expr *This) interface{} {
+	return "this"
+}
+
+// VisitSuperExpr 访问super表达式
+func (p *AstPrinter) VisitSuperExpr(expr *Super) interface{} {
+	return "super." + expr.Method.Lexeme
+}
+
+// VisitListExpr 访问列表字面量表达式
+func (p *AstPrinter) VisitListExpr(expr *List) interface{} {
+	return p.parenthesize("list", expr.Elements...)
+}
+
+// VisitIndexExpr 访问下标访问表达式
+func (p *AstPrinter) VisitIndexExpr(expr *Index) interface{} {
+	return p.parenthesize("[]", expr.Object, expr.Index)
+}
+
+// VisitIndexSetExpr 访问下标赋值表达式
+func (p *AstPrinter) VisitIndexSetExpr(expr *IndexSet) interface{} {
+	target := p.parenthesize("[]", expr.Object, expr.Index)
+	return p.parenthesize2("=", target, expr.Value)
+}
+
+// VisitMapExpr 访问映射字面量表达式
+func (p *AstPrinter) VisitMapExpr(expr *Map) interface{} {
+	var entries []Expr
+	for j, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[j])
+	}
+	return p.parenthesize("map", entries...)
+}
+
+// VisitInterpolationExpr 访问插值字符串表达式
+func (p *AstPrinter) VisitInterpolationExpr(expr *Interpolation) interface{} {
+	return p.parenthesize("interpolate", expr.Parts...)
+}
+
+// VisitLambdaExpr 访问匿名函数表达式，只打印参数列表
+func (p *AstPrinter) VisitLambdaExpr(expr *Lambda) interface{} {
+	var params []string
+	for j, param := range expr.Function.Params {
+		switch {
+		case expr.Function.IsRest(j):
+			params = append(params, "..."+param.Lexeme)
+		case expr.Function.Default(j) != nil:
+			params = append(params, param.Lexeme+"="+expr.Function.Default(j).Accept(p).(string))
+		default:
+			params = append(params, param.Lexeme)
+		}
+	}
+	return "(fun (" + strings.Join(params, " ") + "))"
+}
+
 // parenthesize 将表达式转换为带括号的形式
 func (p *AstPrinter) parenthesize(name string, exprs ...Expr) string {
 	var builder strings.Builder
@@ -123,9 +123,12 @@ func (p *RpnPrinter) VisitLogicalExpr(expr *Logical) interface{} {
 func (p *RpnPrinter) VisitCallExpr(expr *Call) interface{} {
 	var builder strings.Builder
 
-	// 先添加所有参数
-	for _, arg := range expr.Arguments {
+	// 先添加所有参数，命名参数之后跟随它的名称
+	for i, arg := range expr.Arguments {
 		builder.WriteString(fmt.Sprintf("%v ", arg.Accept(p)))
+		if name := expr.Name(i); name != nil {
+			builder.WriteString(name.Lexeme + ": ")
+		}
 	}
 
 	// 再添加被调用的表达式
@@ -136,3 +139,74 @@ func (p *RpnPrinter) VisitCallExpr(expr *Call) interface{} {
 
 	return builder.String()
 }
+
+// VisitGetExpr 访问属性访问表达式
+func (p *RpnPrinter) VisitGetExpr(expr *Get) interface{} {
+	return fmt.Sprintf("%v %s .", expr.Object.Accept(p), expr.Name.Lexeme)
+}
+
+// VisitSetExpr 访问属性赋值表达式
+func (p *RpnPrinter) VisitSetExpr(expr *Set) interface{} {
+	return fmt.Sprintf("%v %v %s .=", expr.Value.Accept(p), expr.Object.Accept(p), expr.Name.Lexeme)
+}
+
+// VisitThisExpr 访问this表达式
+func (p *RpnPrinter) VisitThisExpr(expr *This) interface{} {
+	return "this"
+}
+
+// VisitSuperExpr 访问super表达式
+func (p *RpnPrinter) VisitSuperExpr(expr *Super) interface{} {
+	return "super." + expr.Method.Lexeme
+}
+
+// VisitListExpr 访问列表字面量表达式
+func (p *RpnPrinter) VisitListExpr(expr *List) interface{} {
+	var builder strings.Builder
+
+	for _, element := range expr.Elements {
+		builder.WriteString(fmt.Sprintf("%v ", element.Accept(p)))
+	}
+	builder.WriteString(fmt.Sprintf("list(%d)", len(expr.Elements)))
+
+	return builder.String()
+}
+
+// VisitMapExpr 访问映射字面量表达式
+func (p *RpnPrinter) VisitMapExpr(expr *Map) interface{} {
+	var builder strings.Builder
+
+	for j, key := range expr.Keys {
+		builder.WriteString(fmt.Sprintf("%v %v ", key.Accept(p), expr.Values[j].Accept(p)))
+	}
+	builder.WriteString(fmt.Sprintf("map(%d)", len(expr.Keys)))
+
+	return builder.String()
+}
+
+// VisitInterpolationExpr 访问插值字符串表达式
+func (p *RpnPrinter) VisitInterpolationExpr(expr *Interpolation) interface{} {
+	var builder strings.Builder
+
+	for _, part := range expr.Parts {
+		builder.WriteString(fmt.Sprintf("%v ", part.Accept(p)))
+	}
+	builder.WriteString(fmt.Sprintf("interpolate(%d)", len(expr.Parts)))
+
+	return builder.String()
+}
+
+// VisitLambdaExpr 访问匿名函数表达式
+func (p *RpnPrinter) VisitLambdaExpr(expr *Lambda) interface{} {
+	return fmt.Sprintf("fun(%d)", len(expr.Function.Params))
+}
+
+// VisitIndexExpr 访问下标访问表达式
+func (p *RpnPrinter) VisitIndexExpr(expr *Index) interface{} {
+	return fmt.Sprintf("%v %v []", expr.Object.Accept(p), expr.Index.Accept(p))
+}
+
+// VisitIndexSetExpr 访问下标赋值表达式
+func (p *RpnPrinter) VisitIndexSetExpr(expr *IndexSet) interface{} {
+	return fmt.Sprintf("%v %v %v []=", expr.Value.Accept(p), expr.Object.Accept(p), expr.Index.Accept(p))
+}
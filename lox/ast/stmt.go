@@ -6,6 +6,7 @@ import (
 
 // Stmt 语句接口
 type Stmt interface {
+	Node
 	Accept(visitor StmtVisitor) interface{}
 }
 
@@ -18,12 +19,19 @@ type StmtVisitor interface {
 	VisitIfStmt(stmt *If) interface{}
 	VisitWhileStmt(stmt *While) interface{}
 	VisitBreakStmt(stmt *Break) interface{}
+	VisitContinueStmt(stmt *Continue) interface{}
 	VisitFunctionStmt(stmt *Function) interface{}
 	VisitReturnStmt(stmt *Return) interface{}
+	VisitClassStmt(stmt *Class) interface{}
+	VisitThrowStmt(stmt *Throw) interface{}
+	VisitTryStmt(stmt *Try) interface{}
+	VisitImportStmt(stmt *Import) interface{}
+	VisitExportStmt(stmt *Export) interface{}
 }
 
 // Expression 表达式语句
 type Expression struct {
+	Position
 	Expr Expr
 }
 
@@ -41,6 +49,7 @@ func NewExpression(expr Expr) *Expression {
 
 // Print 打印语句
 type Print struct {
+	Position
 	Expr Expr
 }
 
@@ -58,6 +67,7 @@ func NewPrint(expr Expr) *Print {
 
 // Var 变量声明语句
 type Var struct {
+	Position
 	Name        *token.Token
 	Initializer Expr
 }
@@ -77,6 +87,7 @@ func NewVar(name *token.Token, initializer Expr) *Var {
 
 // Block 代码块语句
 type Block struct {
+	Position
 	Statements []Stmt
 }
 
@@ -94,6 +105,8 @@ func NewBlock(statements []Stmt) *Block {
 
 // If 条件语句
 type If struct {
+	Position
+	Keyword    *token.Token // if关键字
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt // 可能为nil
@@ -105,8 +118,9 @@ func (i *If) Accept(visitor StmtVisitor) interface{} {
 }
 
 // NewIf 创建条件语句
-func NewIf(condition Expr, thenBranch Stmt, elseBranch Stmt) *If {
+func NewIf(keyword *token.Token, condition Expr, thenBranch Stmt, elseBranch Stmt) *If {
 	return &If{
+		Keyword:    keyword,
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
@@ -115,8 +129,11 @@ func NewIf(condition Expr, thenBranch Stmt, elseBranch Stmt) *If {
 
 // While 循环语句
 type While struct {
+	Position
+	Keyword   *token.Token // while关键字，由for循环展开时为for关键字
 	Condition Expr
 	Body      Stmt
+	Increment Expr // for循环的更新表达式，每次循环体结束(包括continue)后执行；while循环为nil
 }
 
 // Accept 接受访问者
@@ -125,8 +142,9 @@ func (w *While) Accept(visitor StmtVisitor) interface{} {
 }
 
 // NewWhile 创建循环语句
-func NewWhile(condition Expr, body Stmt) *While {
+func NewWhile(keyword *token.Token, condition Expr, body Stmt) *While {
 	return &While{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      body,
 	}
@@ -134,6 +152,7 @@ func NewWhile(condition Expr, body Stmt) *While {
 
 // Break 跳出循环语句
 type Break struct {
+	Position
 	Keyword *token.Token
 }
 
@@ -149,11 +168,32 @@ func NewBreak(keyword *token.Token) *Break {
 	}
 }
 
+// Continue 结束本次循环语句
+type Continue struct {
+	Position
+	Keyword *token.Token
+}
+
+// Accept 接受访问者
+func (c *Continue) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitContinueStmt(c)
+}
+
+// NewContinue 创建结束本次循环语句
+func NewContinue(keyword *token.Token) *Continue {
+	return &Continue{
+		Keyword: keyword,
+	}
+}
+
 // Function 函数声明语句
 type Function struct {
-	Name   *token.Token   // 函数名
-	Params []*token.Token // 参数列表
-	Body   []Stmt         // 函数体
+	Position
+	Name     *token.Token   // 函数名
+	Params   []*token.Token // 参数列表，剩余参数位于最后
+	Defaults []Expr         // 参数的默认值，与Params一一对应，没有默认值的为nil；所有参数都没有默认值时为nil
+	Rest     bool           // 最后一个参数是否为收集多余参数的剩余参数
+	Body     []Stmt         // 函数体
 }
 
 // Accept 接受访问者
@@ -170,10 +210,44 @@ func NewFunction(name *token.Token, params []*token.Token, body []Stmt) *Functio
 	}
 }
 
+// Default 返回第i个参数的默认值，没有默认值时返回nil
+func (f *Function) Default(i int) Expr {
+	if f.Defaults == nil {
+		return nil
+	}
+	return f.Defaults[i]
+}
+
+// MinArity 返回调用时至少需要传入的参数个数，即没有默认值的普通参数的个数
+func (f *Function) MinArity() int {
+	count := 0
+	for i := range f.Params {
+		if f.Default(i) == nil && !f.IsRest(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// MaxArity 返回调用时最多可以传入的参数个数，有剩余参数时为-1，表示没有上限
+func (f *Function) MaxArity() int {
+	if f.Rest {
+		return -1
+	}
+	return len(f.Params)
+}
+
+// IsRest 判断第i个参数是否为剩余参数
+func (f *Function) IsRest(i int) bool {
+	return f.Rest && i == len(f.Params)-1
+}
+
 // Return 返回语句
 type Return struct {
-	Keyword *token.Token // 关键字token
-	Value   Expr         // 返回值(可能为nil)
+	Position
+	Keyword  *token.Token // 关键字token
+	Value    Expr         // 返回值(可能为nil)
+	TailCall bool         // 返回值是否为尾调用，由解析器标记
 }
 
 // Accept 接受访问者
@@ -188,3 +262,137 @@ func NewReturn(keyword *token.Token, value Expr) *Return {
 		Value:   value,
 	}
 }
+
+// Class 类声明语句
+type Class struct {
+	Position
+	Name       *token.Token // 类名
+	Superclass *Variable    // 父类(可能为nil)
+	Methods    []*Function  // 方法列表
+}
+
+// Accept 接受访问者
+func (c *Class) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitClassStmt(c)
+}
+
+// NewClass 创建类声明语句
+func NewClass(name *token.Token, superclass *Variable, methods []*Function) *Class {
+	return &Class{
+		Name:       name,
+		Superclass: superclass,
+		Methods:    methods,
+	}
+}
+
+// Throw 抛出异常语句
+type Throw struct {
+	Position
+	Keyword *token.Token // 关键字token
+	Value   Expr         // 抛出的值
+}
+
+// Accept 接受访问者
+func (t *Throw) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitThrowStmt(t)
+}
+
+// NewThrow 创建抛出异常语句
+func NewThrow(keyword *token.Token, value Expr) *Throw {
+	return &Throw{
+		Keyword: keyword,
+		Value:   value,
+	}
+}
+
+// Try 异常处理语句，catch和finally子句至少有一个
+type Try struct {
+	Position
+	Keyword   *token.Token // 关键字token
+	Body      *Block       // try块
+	CatchName *token.Token // catch子句绑定的变量名，没有catch子句时为nil
+	Catch     *Block       // catch块，没有catch子句时为nil
+	Finally   *Block       // finally块，没有finally子句时为nil
+}
+
+// Accept 接受访问者
+func (t *Try) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitTryStmt(t)
+}
+
+// NewTry 创建异常处理语句
+func NewTry(keyword *token.Token, body *Block, catchName *token.Token, catch *Block, finally *Block) *Try {
+	return &Try{
+		Keyword:   keyword,
+		Body:      body,
+		CatchName: catchName,
+		Catch:     catch,
+		Finally:   finally,
+	}
+}
+
+// Import 导入模块语句，把模块绑定到一个变量
+type Import struct {
+	Position
+	Keyword *token.Token // 关键字token
+	Path    *token.Token // 模块路径的字符串字面量，相对于导入它的文件
+	Name    *token.Token // 绑定模块的变量名
+}
+
+// Accept 接受访问者
+func (i *Import) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitImportStmt(i)
+}
+
+// NewImport 创建导入模块语句
+func NewImport(keyword *token.Token, path *token.Token, name *token.Token) *Import {
+	return &Import{
+		Keyword: keyword,
+		Path:    path,
+		Name:    name,
+	}
+}
+
+// Export 导出声明语句，被导出的变量、函数或类可以通过模块访问
+type Export struct {
+	Position
+	Keyword     *token.Token // 关键字token
+	Declaration Stmt         // 被导出的声明：*Var、*Function或*Class
+}
+
+// Accept 接受访问者
+func (e *Export) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitExportStmt(e)
+}
+
+// NewExport 创建导出声明语句
+func NewExport(keyword *token.Token, declaration Stmt) *Export {
+	return &Export{
+		Keyword:     keyword,
+		Declaration: declaration,
+	}
+}
+
+// Name 返回被导出的名称
+func (e *Export) Name() *token.Token {
+	switch declaration := e.Declaration.(type) {
+	case *Var:
+		return declaration.Name
+	case *Function:
+		return declaration.Name
+	case *Class:
+		return declaration.Name
+	}
+	return nil
+}
+
+// ExportedNames 返回顶层语句中导出的全部名称
+func ExportedNames(statements []Stmt) []string {
+	var names []string
+	for _, stmt := range statements {
+		if export, ok := stmt.(*Export); ok {
+			names = append(names, export.Name().Lexeme)
+		}
+	}
+	return names
+}
@@ -0,0 +1,34 @@
+package compiler
+
+import (
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Chunk 字节码块，包含指令序列和常量表
+type Chunk struct {
+	Code      []byte         // 指令和操作数
+	Tokens    []*token.Token // 每个字节对应的源码标记，用于运行时错误报告
+	Constants []interface{}  // 常量表
+}
+
+// NewChunk 创建一个空的字节码块
+func NewChunk() *Chunk {
+	return &Chunk{}
+}
+
+// Write 写入一个字节，并记录其对应的源码标记
+func (c *Chunk) Write(b byte, tok *token.Token) {
+	c.Code = append(c.Code, b)
+	c.Tokens = append(c.Tokens, tok)
+}
+
+// AddConstant 向常量表添加一个常量并返回其索引
+func (c *Chunk) AddConstant(value interface{}) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// ReadShort 读取offset处的2字节大端操作数
+func (c *Chunk) ReadShort(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
@@ -0,0 +1,1124 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// FunctionType 正在编译的函数类型
+type FunctionType int
+
+const (
+	TypeScript      FunctionType = iota // 顶层脚本
+	TypeFunction                        // 普通函数
+	TypeMethod                          // 类方法
+	TypeInitializer                     // 类初始化方法
+)
+
+const (
+	maxLocals    = 256   // 单个函数中局部变量的最大数量
+	maxUpvalues  = 256   // 单个函数捕获上值的最大数量
+	maxConstants = 65536 // 单个字节码块中常量的最大数量
+	maxJump      = 65535 // 跳转指令的最大偏移
+)
+
+// local 编译期的局部变量信息
+type local struct {
+	name       string
+	depth      int  // 所在作用域深度，-1表示已声明但尚未初始化
+	isCaptured bool // 是否被闭包捕获
+}
+
+// upvalue 编译期的上值信息
+type upvalue struct {
+	index   byte // 外层函数的局部变量槽位或上值索引
+	isLocal bool // 是否直接捕获外层函数的局部变量
+}
+
+// loop 编译期的循环信息，用于break和continue语句
+type loop struct {
+	scopeDepth int   // 循环开始时的作用域深度
+	breaks     []int // 待回填的跳出循环跳转指令
+	continues  []int // 待回填的跳到循环体末尾的跳转指令
+}
+
+// tryState 编译期的try语句信息，用于跳出try块的return、break和continue语句
+type tryState struct {
+	loops   int        // try语句开始时外层循环的数量
+	finally *ast.Block // 跳出时需要执行的finally块(可能为nil)
+}
+
+// funcState 单个函数的编译状态
+type funcState struct {
+	enclosing  *funcState
+	function   *Function
+	kind       FunctionType
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+	loops      []*loop
+	tries      []*tryState         // 包围当前代码且已安装异常处理器的try语句
+	constants  map[interface{}]int // 名称、字符串和数字常量在常量表中的索引，相同的常量共享一个槽位
+}
+
+// classState 单个类的编译状态
+type classState struct {
+	enclosing     *classState
+	hasSuperclass bool
+}
+
+// Compiler 将解析后的语法树编译为字节码
+type Compiler struct {
+	errorReporter error.Reporter
+	current       *funcState
+	currentClass  *classState
+	hadError      bool
+}
+
+// NewCompiler 创建一个新的编译器
+func NewCompiler(errorReporter error.Reporter) *Compiler {
+	return &Compiler{
+		errorReporter: errorReporter,
+	}
+}
+
+// Compile 将语句列表编译为顶层脚本函数，编译失败时返回nil
+// 如果最后一条语句是表达式语句，脚本返回其值
+func (c *Compiler) Compile(statements []ast.Stmt) *Function {
+	c.hadError = false
+	c.currentClass = nil
+	c.beginFunction(TypeScript, "")
+
+	for index, stmt := range statements {
+		if expr, ok := stmt.(*ast.Expression); ok && index == len(statements)-1 {
+			c.compileExpr(expr.Expr)
+			c.emitOp(OP_RETURN, nil)
+			break
+		}
+		c.compileStmt(stmt)
+	}
+
+	function, _ := c.endFunction(nil)
+	if c.hadError {
+		return nil
+	}
+	return function
+}
+
+// compileStmt 编译语句
+func (c *Compiler) compileStmt(stmt ast.Stmt) {
+	stmt.Accept(c)
+}
+
+// compileExpr 编译表达式，结果留在栈顶
+func (c *Compiler) compileExpr(expr ast.Expr) {
+	expr.Accept(c)
+}
+
+// error 报告编译错误
+func (c *Compiler) error(tok *token.Token, message string) {
+	c.hadError = true
+	c.errorReporter.Error(tok, 0, message)
+}
+
+// ---------- 函数状态 ----------
+
+// beginFunction 开始编译一个新函数
+func (c *Compiler) beginFunction(kind FunctionType, name string) {
+	state := &funcState{
+		enclosing: c.current,
+		function:  NewFunction(name),
+		kind:      kind,
+		constants: make(map[interface{}]int),
+	}
+
+	// 槽位0保留给被调用者，方法中即为this
+	slotName := ""
+	if kind == TypeMethod || kind == TypeInitializer {
+		slotName = "this"
+	}
+	state.locals = append(state.locals, local{name: slotName, depth: 0})
+
+	c.current = state
+}
+
+// endFunction 结束当前函数的编译，返回函数原型及其上值描述
+func (c *Compiler) endFunction(tok *token.Token) (*Function, []upvalue) {
+	c.emitReturn(tok)
+
+	state := c.current
+	state.function.UpvalueCount = len(state.upvalues)
+	c.current = state.enclosing
+	return state.function, state.upvalues
+}
+
+// chunk 返回当前正在写入的字节码块
+func (c *Compiler) chunk() *Chunk {
+	return c.current.function.Chunk
+}
+
+// ---------- 指令生成 ----------
+
+// emitByte 写入一个字节
+func (c *Compiler) emitByte(b byte, tok *token.Token) {
+	c.chunk().Write(b, tok)
+}
+
+// emitOp 写入一条无操作数指令
+func (c *Compiler) emitOp(op OpCode, tok *token.Token) {
+	c.emitByte(byte(op), tok)
+}
+
+// emitOpByte 写入一条带1字节操作数的指令
+func (c *Compiler) emitOpByte(op OpCode, operand byte, tok *token.Token) {
+	c.emitByte(byte(op), tok)
+	c.emitByte(operand, tok)
+}
+
+// emitOpShort 写入一条带2字节操作数的指令
+func (c *Compiler) emitOpShort(op OpCode, operand int, tok *token.Token) {
+	c.emitByte(byte(op), tok)
+	c.emitShort(operand, tok)
+}
+
+// emitShort 写入2字节操作数
+func (c *Compiler) emitShort(operand int, tok *token.Token) {
+	c.emitByte(byte(operand>>8), tok)
+	c.emitByte(byte(operand), tok)
+}
+
+// emitReturn 写入函数的隐式返回
+func (c *Compiler) emitReturn(tok *token.Token) {
+	if c.current.kind == TypeInitializer {
+		// 初始化方法总是返回this
+		c.emitOpByte(OP_GET_LOCAL, 0, tok)
+	} else {
+		c.emitOp(OP_NIL, tok)
+	}
+	c.emitOp(OP_RETURN, tok)
+}
+
+// makeConstant 向常量表添加常量并返回索引，已有的名称、字符串和数字常量直接复用
+func (c *Compiler) makeConstant(value interface{}, tok *token.Token) int {
+	key, shared := constantKey(value)
+	if shared {
+		if index, ok := c.current.constants[key]; ok {
+			return index
+		}
+	}
+
+	index := c.chunk().AddConstant(value)
+	if index >= maxConstants {
+		c.error(tok, "单个函数中的常量过多。")
+		return 0
+	}
+	if shared {
+		c.current.constants[key] = index
+	}
+	return index
+}
+
+// floatConstant 浮点数常量的键，按位比较使0.0和-0.0是不同的常量
+type floatConstant uint64
+
+// constantKey 返回常量在复用表中的键，函数原型等其他常量每次都占用新的槽位
+func constantKey(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string, int64:
+		return v, true
+	case float64:
+		return floatConstant(math.Float64bits(v)), true
+	}
+	return nil, false
+}
+
+// identifierConstant 将名称作为字符串常量加入常量表，同名的引用共享一个槽位
+// 指令的操作数字节记录各自的名称标记，虚拟机据此报告出错位置
+func (c *Compiler) identifierConstant(name *token.Token) int {
+	return c.makeConstant(name.Lexeme, name)
+}
+
+// emitConstant 写入压入常量的指令
+func (c *Compiler) emitConstant(value interface{}, tok *token.Token) {
+	c.emitOpShort(OP_CONSTANT, c.makeConstant(value, tok), tok)
+}
+
+// emitJump 写入跳转指令并返回待回填的操作数位置
+func (c *Compiler) emitJump(op OpCode, tok *token.Token) int {
+	c.emitByte(byte(op), tok)
+	return c.emitJumpOffset(tok)
+}
+
+// emitJumpOffset 写入占位的2字节跳转偏移，返回其位置供patchJump回填
+func (c *Compiler) emitJumpOffset(tok *token.Token) int {
+	c.emitByte(0xff, tok)
+	c.emitByte(0xff, tok)
+	return len(c.chunk().Code) - 2
+}
+
+// patchJump 将跳转指令的目标回填为当前位置
+func (c *Compiler) patchJump(offset int) {
+	code := c.chunk().Code
+	jump := len(code) - offset - 2
+	if jump > maxJump {
+		c.error(c.chunk().Tokens[offset], "跳转距离过大。")
+	}
+	code[offset] = byte(jump >> 8)
+	code[offset+1] = byte(jump)
+}
+
+// emitLoop 写入跳回循环开始处的指令
+func (c *Compiler) emitLoop(loopStart int, tok *token.Token) {
+	offset := len(c.chunk().Code) - loopStart + 3
+	if offset > maxJump {
+		c.error(tok, "循环体过大。")
+	}
+	c.emitOpShort(OP_LOOP, offset, tok)
+}
+
+// ---------- 作用域与变量 ----------
+
+// beginScope 进入新的块作用域
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+// endScope 离开块作用域，弹出其中的局部变量
+func (c *Compiler) endScope(tok *token.Token) {
+	state := c.current
+	state.scopeDepth--
+
+	for len(state.locals) > 0 && state.locals[len(state.locals)-1].depth > state.scopeDepth {
+		c.popLocal(state.locals[len(state.locals)-1], tok)
+		state.locals = state.locals[:len(state.locals)-1]
+	}
+}
+
+// popLocal 写入丢弃局部变量的指令，被捕获的变量需要先关闭上值
+func (c *Compiler) popLocal(l local, tok *token.Token) {
+	if l.isCaptured {
+		c.emitOp(OP_CLOSE_UPVALUE, tok)
+	} else {
+		c.emitOp(OP_POP, tok)
+	}
+}
+
+// declareLocal 在当前作用域声明局部变量
+func (c *Compiler) declareLocal(name *token.Token) {
+	if len(c.current.locals) >= maxLocals {
+		c.error(name, "函数中的局部变量过多。")
+		return
+	}
+	c.current.locals = append(c.current.locals, local{name: name.Lexeme, depth: -1})
+}
+
+// markInitialized 将最近声明的局部变量标记为已初始化
+func (c *Compiler) markInitialized() {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+}
+
+// declareVariable 声明变量，全局作用域下不做任何处理
+func (c *Compiler) declareVariable(name *token.Token) {
+	if c.current.scopeDepth > 0 {
+		c.declareLocal(name)
+	}
+}
+
+// defineVariable 定义变量，栈顶的值成为变量的初始值
+func (c *Compiler) defineVariable(name *token.Token) {
+	if c.current.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+	c.emitOpShort(OP_DEFINE_GLOBAL, c.identifierConstant(name), name)
+}
+
+// resolveLocal 在函数的局部变量中查找变量，返回槽位或-1
+func (c *Compiler) resolveLocal(state *funcState, name *token.Token) int {
+	for i := len(state.locals) - 1; i >= 0; i-- {
+		if state.locals[i].name == name.Lexeme {
+			if state.locals[i].depth == -1 {
+				c.error(name, "不能在变量初始化中引用自身。")
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue 在外层函数中查找变量并将其捕获为上值，返回上值索引或-1
+func (c *Compiler) resolveUpvalue(state *funcState, name *token.Token) int {
+	if state.enclosing == nil {
+		return -1
+	}
+
+	if slot := c.resolveLocal(state.enclosing, name); slot != -1 {
+		state.enclosing.locals[slot].isCaptured = true
+		return c.addUpvalue(state, byte(slot), true, name)
+	}
+
+	if index := c.resolveUpvalue(state.enclosing, name); index != -1 {
+		return c.addUpvalue(state, byte(index), false, name)
+	}
+
+	return -1
+}
+
+// addUpvalue 为函数添加上值，相同的上值只添加一次
+func (c *Compiler) addUpvalue(state *funcState, index byte, isLocal bool, name *token.Token) int {
+	for i, uv := range state.upvalues {
+		if uv.index == index && uv.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(state.upvalues) >= maxUpvalues {
+		c.error(name, "函数中的闭包变量过多。")
+		return 0
+	}
+
+	state.upvalues = append(state.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(state.upvalues) - 1
+}
+
+// namedVariable 写入读取变量的指令
+func (c *Compiler) namedVariable(name *token.Token) {
+	if slot := c.resolveLocal(c.current, name); slot != -1 {
+		c.emitOpByte(OP_GET_LOCAL, byte(slot), name)
+	} else if index := c.resolveUpvalue(c.current, name); index != -1 {
+		c.emitOpByte(OP_GET_UPVALUE, byte(index), name)
+	} else {
+		c.emitOpShort(OP_GET_GLOBAL, c.identifierConstant(name), name)
+	}
+}
+
+// assignVariable 写入为变量赋值的指令，栈顶的值保持不变
+func (c *Compiler) assignVariable(name *token.Token) {
+	if slot := c.resolveLocal(c.current, name); slot != -1 {
+		c.emitOpByte(OP_SET_LOCAL, byte(slot), name)
+	} else if index := c.resolveUpvalue(c.current, name); index != -1 {
+		c.emitOpByte(OP_SET_UPVALUE, byte(index), name)
+	} else {
+		c.emitOpShort(OP_SET_GLOBAL, c.identifierConstant(name), name)
+	}
+}
+
+// syntheticToken 创建编译器内部使用的标识符标记
+func syntheticToken(lexeme string, line int) *token.Token {
+	return token.NewToken(token.IDENTIFIER, lexeme, nil, line)
+}
+
+// ---------- 语句 ----------
+
+// VisitExpressionStmt 编译表达式语句
+func (c *Compiler) VisitExpressionStmt(stmt *ast.Expression) interface{} {
+	c.compileExpr(stmt.Expr)
+	c.emitOp(OP_POP, nil)
+	return nil
+}
+
+// VisitPrintStmt 编译打印语句
+func (c *Compiler) VisitPrintStmt(stmt *ast.Print) interface{} {
+	c.compileExpr(stmt.Expr)
+	c.emitOp(OP_PRINT, nil)
+	return nil
+}
+
+// VisitVarStmt 编译变量声明语句
+func (c *Compiler) VisitVarStmt(stmt *ast.Var) interface{} {
+	c.declareVariable(stmt.Name)
+
+	if stmt.Initializer != nil {
+		c.compileExpr(stmt.Initializer)
+	} else {
+		c.emitOp(OP_NIL, stmt.Name)
+	}
+
+	c.defineVariable(stmt.Name)
+	return nil
+}
+
+// VisitBlockStmt 编译块语句
+func (c *Compiler) VisitBlockStmt(stmt *ast.Block) interface{} {
+	c.beginScope()
+	for _, s := range stmt.Statements {
+		c.compileStmt(s)
+	}
+	c.endScope(nil)
+	return nil
+}
+
+// VisitIfStmt 编译条件语句
+func (c *Compiler) VisitIfStmt(stmt *ast.If) interface{} {
+	c.compileExpr(stmt.Condition)
+
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE, stmt.Keyword)
+	c.emitOp(OP_POP, nil)
+	c.compileStmt(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OP_JUMP, stmt.Keyword)
+	c.patchJump(thenJump)
+	c.emitOp(OP_POP, nil)
+
+	if stmt.ElseBranch != nil {
+		c.compileStmt(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+// VisitWhileStmt 编译循环语句
+func (c *Compiler) VisitWhileStmt(stmt *ast.While) interface{} {
+	loopStart := len(c.chunk().Code)
+	c.compileExpr(stmt.Condition)
+
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE, stmt.Keyword)
+	c.emitOp(OP_POP, nil)
+
+	l := &loop{scopeDepth: c.current.scopeDepth}
+	c.current.loops = append(c.current.loops, l)
+	c.compileStmt(stmt.Body)
+	c.current.loops = c.current.loops[:len(c.current.loops)-1]
+
+	// continue跳转到循环体之后、更新表达式之前的位置
+	for _, offset := range l.continues {
+		c.patchJump(offset)
+	}
+	if stmt.Increment != nil {
+		c.compileExpr(stmt.Increment)
+		c.emitOp(OP_POP, nil)
+	}
+
+	c.emitLoop(loopStart, stmt.Keyword)
+	c.patchJump(exitJump)
+	c.emitOp(OP_POP, nil)
+
+	// break跳转到条件值已弹出之后的位置
+	for _, offset := range l.breaks {
+		c.patchJump(offset)
+	}
+	return nil
+}
+
+// VisitBreakStmt 编译break语句
+func (c *Compiler) VisitBreakStmt(stmt *ast.Break) interface{} {
+	loops := c.current.loops
+	if len(loops) == 0 {
+		c.error(stmt.Keyword, "Break语句只能在循环内部使用。")
+		return nil
+	}
+	l := loops[len(loops)-1]
+
+	c.exitTries(c.triesOutside(len(loops)-1), stmt.Keyword)
+	c.discardLoopLocals(l, stmt.Keyword)
+	l.breaks = append(l.breaks, c.emitJump(OP_JUMP, stmt.Keyword))
+	return nil
+}
+
+// VisitContinueStmt 编译continue语句
+func (c *Compiler) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	loops := c.current.loops
+	if len(loops) == 0 {
+		c.error(stmt.Keyword, "Continue语句只能在循环内部使用。")
+		return nil
+	}
+	l := loops[len(loops)-1]
+
+	c.exitTries(c.triesOutside(len(loops)-1), stmt.Keyword)
+	c.discardLoopLocals(l, stmt.Keyword)
+	l.continues = append(l.continues, c.emitJump(OP_JUMP, stmt.Keyword))
+	return nil
+}
+
+// discardLoopLocals 丢弃循环体内声明的局部变量，但不改变编译期的作用域
+func (c *Compiler) discardLoopLocals(l *loop, tok *token.Token) {
+	locals := c.current.locals
+	for i := len(locals) - 1; i >= 0 && locals[i].depth > l.scopeDepth; i-- {
+		c.popLocal(locals[i], tok)
+	}
+}
+
+// VisitFunctionStmt 编译函数声明语句
+func (c *Compiler) VisitFunctionStmt(stmt *ast.Function) interface{} {
+	c.declareVariable(stmt.Name)
+	// 函数体可以递归引用自身
+	c.markInitialized()
+	c.function(stmt, TypeFunction)
+	c.defineVariable(stmt.Name)
+	return nil
+}
+
+// function 编译函数体并写入创建闭包的指令
+func (c *Compiler) function(stmt *ast.Function, kind FunctionType) {
+	c.beginFunction(kind, stmt.Name.Lexeme)
+	c.beginScope()
+
+	// 参数在调用时已位于栈上，没有传入的参数在声明之前计算默认值并写入它的槽位，
+	// 因此默认值只能引用它之前的参数
+	for i, param := range stmt.Params {
+		if value := stmt.Default(i); value != nil {
+			c.emitOpByte(OP_SKIP_DEFAULT, byte(i), param)
+			jump := c.emitJumpOffset(param)
+			c.compileExpr(value)
+			c.emitOpByte(OP_SET_LOCAL, byte(i+1), param)
+			c.emitOp(OP_POP, param)
+			c.patchJump(jump)
+		}
+		c.current.function.Arity++
+		if !stmt.IsRest(i) {
+			c.current.function.Params = append(c.current.function.Params, param.Lexeme)
+		}
+		c.declareLocal(param)
+		c.markInitialized()
+	}
+	c.current.function.MinArity = stmt.MinArity()
+	c.current.function.Variadic = stmt.Rest
+
+	for _, s := range stmt.Body {
+		c.compileStmt(s)
+	}
+
+	// 函数返回时整个栈帧被丢弃，无需结束作用域
+	function, upvalues := c.endFunction(stmt.Name)
+
+	c.emitOpShort(OP_CLOSURE, c.makeConstant(function, stmt.Name), stmt.Name)
+	for _, uv := range upvalues {
+		isLocal := byte(0)
+		if uv.isLocal {
+			isLocal = 1
+		}
+		c.emitByte(isLocal, stmt.Name)
+		c.emitByte(uv.index, stmt.Name)
+	}
+}
+
+// VisitReturnStmt 编译返回语句
+func (c *Compiler) VisitReturnStmt(stmt *ast.Return) interface{} {
+	if stmt.Value == nil {
+		c.exitTries(0, stmt.Keyword)
+		c.emitReturn(stmt.Keyword)
+		return nil
+	}
+
+	// 尾调用复用当前栈帧；被调用者不是闭包时尾调用指令退化为普通调用，由随后的OP_RETURN返回结果
+	if call, ok := stmt.Value.(*ast.Call); ok && stmt.TailCall && c.current.kind != TypeInitializer && len(call.Arguments) <= 255 {
+		c.compileCall(call, true)
+		c.emitOp(OP_RETURN, stmt.Keyword)
+		return nil
+	}
+
+	c.compileExpr(stmt.Value)
+	if len(c.current.tries) > 0 {
+		// 返回值暂存为匿名局部变量，finally块中声明的局部变量位于它之上
+		c.beginScope()
+		c.declareLocal(syntheticToken("", stmt.Keyword.Line))
+		c.markInitialized()
+		c.exitTries(0, stmt.Keyword)
+		c.current.locals = c.current.locals[:len(c.current.locals)-1]
+		c.current.scopeDepth--
+	}
+	c.emitOp(OP_RETURN, stmt.Keyword)
+	return nil
+}
+
+// VisitClassStmt 编译类声明语句
+func (c *Compiler) VisitClassStmt(stmt *ast.Class) interface{} {
+	c.declareVariable(stmt.Name)
+	c.emitOpShort(OP_CLASS, c.makeConstant(stmt.Name.Lexeme, stmt.Name), stmt.Name)
+	c.defineVariable(stmt.Name)
+
+	class := &classState{enclosing: c.currentClass}
+	c.currentClass = class
+
+	if stmt.Superclass != nil {
+		c.namedVariable(stmt.Superclass.Name)
+
+		// 父类保存在名为super的局部变量中，供方法捕获
+		c.beginScope()
+		c.declareLocal(syntheticToken("super", stmt.Name.Line))
+		c.markInitialized()
+
+		c.namedVariable(stmt.Name)
+		c.emitOp(OP_INHERIT, stmt.Superclass.Name)
+		class.hasSuperclass = true
+	}
+
+	// 定义方法期间类对象保持在栈顶
+	c.namedVariable(stmt.Name)
+	for _, method := range stmt.Methods {
+		kind := TypeMethod
+		if method.Name.Lexeme == "init" {
+			kind = TypeInitializer
+		}
+		c.function(method, kind)
+		c.emitOpShort(OP_METHOD, c.makeConstant(method.Name.Lexeme, method.Name), method.Name)
+	}
+	c.emitOp(OP_POP, stmt.Name)
+
+	if class.hasSuperclass {
+		c.endScope(stmt.Name)
+	}
+
+	c.currentClass = class.enclosing
+	return nil
+}
+
+// ---------- 表达式 ----------
+
+// VisitBinaryExpr 编译二元表达式
+func (c *Compiler) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	c.compileExpr(expr.Left)
+
+	// 逗号运算符丢弃左操作数的值
+	if expr.Operator.Type == token.COMMA {
+		c.emitOp(OP_POP, expr.Operator)
+		c.compileExpr(expr.Right)
+		return nil
+	}
+
+	c.compileExpr(expr.Right)
+
+	switch expr.Operator.Type {
+	case token.PLUS:
+		c.emitOp(OP_ADD, expr.Operator)
+	case token.MINUS:
+		c.emitOp(OP_SUBTRACT, expr.Operator)
+	case token.STAR:
+		c.emitOp(OP_MULTIPLY, expr.Operator)
+	case token.SLASH:
+		c.emitOp(OP_DIVIDE, expr.Operator)
+	case token.MODULO:
+		c.emitOp(OP_MODULO, expr.Operator)
+	case token.STAR_STAR:
+		c.emitOp(OP_POWER, expr.Operator)
+	case token.AMPERSAND:
+		c.emitOp(OP_BIT_AND, expr.Operator)
+	case token.PIPE:
+		c.emitOp(OP_BIT_OR, expr.Operator)
+	case token.CARET:
+		c.emitOp(OP_BIT_XOR, expr.Operator)
+	case token.LESS_LESS:
+		c.emitOp(OP_SHIFT_LEFT, expr.Operator)
+	case token.GREATER_GREATER:
+		c.emitOp(OP_SHIFT_RIGHT, expr.Operator)
+	case token.GREATER:
+		c.emitOp(OP_GREATER, expr.Operator)
+	case token.GREATER_EQUAL:
+		c.emitOp(OP_GREATER_EQUAL, expr.Operator)
+	case token.LESS:
+		c.emitOp(OP_LESS, expr.Operator)
+	case token.LESS_EQUAL:
+		c.emitOp(OP_LESS_EQUAL, expr.Operator)
+	case token.EQUAL_EQUAL:
+		c.emitOp(OP_EQUAL, expr.Operator)
+	case token.BANG_EQUAL:
+		c.emitOp(OP_NOT_EQUAL, expr.Operator)
+	default:
+		c.error(expr.Operator, "未知的二元运算符。")
+	}
+	return nil
+}
+
+// VisitGroupingExpr 编译分组表达式
+func (c *Compiler) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	c.compileExpr(expr.Expression)
+	return nil
+}
+
+// VisitLiteralExpr 编译字面量表达式
+func (c *Compiler) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	switch v := expr.Value.(type) {
+	case nil:
+		c.emitOp(OP_NIL, nil)
+	case bool:
+		if v {
+			c.emitOp(OP_TRUE, nil)
+		} else {
+			c.emitOp(OP_FALSE, nil)
+		}
+	default:
+		c.emitConstant(v, nil)
+	}
+	return nil
+}
+
+// VisitUnaryExpr 编译一元表达式
+func (c *Compiler) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	c.compileExpr(expr.Right)
+
+	switch expr.Operator.Type {
+	case token.MINUS:
+		c.emitOp(OP_NEGATE, expr.Operator)
+	case token.BANG:
+		c.emitOp(OP_NOT, expr.Operator)
+	case token.TILDE:
+		c.emitOp(OP_BIT_NOT, expr.Operator)
+	default:
+		c.error(expr.Operator, "未知的一元运算符。")
+	}
+	return nil
+}
+
+// VisitTernaryExpr 编译三元条件表达式
+func (c *Compiler) VisitTernaryExpr(expr *ast.Ternary) interface{} {
+	c.compileExpr(expr.Condition)
+
+	elseJump := c.emitJump(OP_JUMP_IF_FALSE, expr.Question)
+	c.emitOp(OP_POP, nil)
+	c.compileExpr(expr.ThenBranch)
+
+	endJump := c.emitJump(OP_JUMP, expr.Question)
+	c.patchJump(elseJump)
+	c.emitOp(OP_POP, nil)
+	c.compileExpr(expr.ElseBranch)
+
+	c.patchJump(endJump)
+	return nil
+}
+
+// VisitVariableExpr 编译变量表达式
+func (c *Compiler) VisitVariableExpr(expr *ast.Variable) interface{} {
+	c.namedVariable(expr.Name)
+	return nil
+}
+
+// VisitAssignExpr 编译赋值表达式
+func (c *Compiler) VisitAssignExpr(expr *ast.Assign) interface{} {
+	c.compileExpr(expr.Value)
+	c.assignVariable(expr.Name)
+	return nil
+}
+
+// VisitLogicalExpr 编译逻辑表达式，支持短路求值
+func (c *Compiler) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	c.compileExpr(expr.Left)
+
+	if expr.Operator.Type == token.OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE, expr.Operator)
+		endJump := c.emitJump(OP_JUMP, expr.Operator)
+
+		c.patchJump(elseJump)
+		c.emitOp(OP_POP, expr.Operator)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+		return nil
+	}
+
+	endJump := c.emitJump(OP_JUMP_IF_FALSE, expr.Operator)
+	c.emitOp(OP_POP, expr.Operator)
+	c.compileExpr(expr.Right)
+	c.patchJump(endJump)
+	return nil
+}
+
+// VisitCallExpr 编译函数调用表达式
+func (c *Compiler) VisitCallExpr(expr *ast.Call) interface{} {
+	if len(expr.Arguments) > 255 {
+		c.error(expr.Paren, "参数不能超过255个。")
+		return nil
+	}
+
+	c.compileCall(expr, false)
+	return nil
+}
+
+// compileCall 编译调用，tail为true时使用复用当前栈帧的尾调用指令
+func (c *Compiler) compileCall(expr *ast.Call, tail bool) {
+	if len(expr.Names) > 0 {
+		c.compileNamedCall(expr, tail)
+		return
+	}
+
+	call, invoke, superInvoke := OP_CALL, OP_INVOKE, OP_SUPER_INVOKE
+	if tail {
+		call, invoke, superInvoke = OP_TAIL_CALL, OP_TAIL_INVOKE, OP_TAIL_SUPER
+	}
+
+	switch callee := expr.Callee.(type) {
+	case *ast.Get:
+		// obj.method(args) 直接调用方法，避免创建绑定方法
+		c.compileExpr(callee.Object)
+		c.compileArguments(expr.Arguments)
+		c.emitOp(invoke, expr.Paren)
+		c.emitShort(c.identifierConstant(callee.Name), callee.Name)
+		c.emitByte(byte(len(expr.Arguments)), expr.Paren)
+	case *ast.Super:
+		c.namedVariable(syntheticToken("this", callee.Keyword.Line))
+		c.compileArguments(expr.Arguments)
+		c.namedVariable(callee.Keyword)
+		c.emitOp(superInvoke, expr.Paren)
+		c.emitShort(c.identifierConstant(callee.Method), callee.Method)
+		c.emitByte(byte(len(expr.Arguments)), expr.Paren)
+	default:
+		c.compileExpr(expr.Callee)
+		c.compileArguments(expr.Arguments)
+		c.emitOpByte(call, byte(len(expr.Arguments)), expr.Paren)
+	}
+}
+
+// compileNamedCall 编译带命名参数的调用，方法先取出为绑定方法再调用
+// 参数名列表作为常量，由虚拟机在调用前按被调用者的参数名重新排列参数
+func (c *Compiler) compileNamedCall(expr *ast.Call, tail bool) {
+	op := OP_CALL_NAMED
+	if tail {
+		op = OP_TAIL_NAMED
+	}
+
+	c.compileExpr(expr.Callee)
+	c.compileArguments(expr.Arguments)
+	c.emitOpShort(op, c.makeConstant(expr.Names, expr.Paren), expr.Paren)
+	c.emitByte(byte(len(expr.Arguments)), expr.Paren)
+}
+
+// compileArguments 依次编译调用参数
+func (c *Compiler) compileArguments(arguments []ast.Expr) {
+	for _, arg := range arguments {
+		c.compileExpr(arg)
+	}
+}
+
+// VisitGetExpr 编译属性访问表达式
+func (c *Compiler) VisitGetExpr(expr *ast.Get) interface{} {
+	c.compileExpr(expr.Object)
+	c.emitOpShort(OP_GET_PROPERTY, c.identifierConstant(expr.Name), expr.Name)
+	return nil
+}
+
+// VisitSetExpr 编译属性赋值表达式
+func (c *Compiler) VisitSetExpr(expr *ast.Set) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Value)
+	c.emitOpShort(OP_SET_PROPERTY, c.identifierConstant(expr.Name), expr.Name)
+	return nil
+}
+
+// VisitThisExpr 编译this表达式
+func (c *Compiler) VisitThisExpr(expr *ast.This) interface{} {
+	if c.currentClass == nil {
+		c.error(expr.Keyword, "不能在类外部使用'this'。")
+		return nil
+	}
+	c.namedVariable(expr.Keyword)
+	return nil
+}
+
+// VisitSuperExpr 编译super方法访问表达式
+func (c *Compiler) VisitSuperExpr(expr *ast.Super) interface{} {
+	if c.currentClass == nil {
+		c.error(expr.Keyword, "不能在类外部使用'super'。")
+		return nil
+	} else if !c.currentClass.hasSuperclass {
+		c.error(expr.Keyword, "不能在没有父类的类中使用'super'。")
+		return nil
+	}
+
+	c.namedVariable(syntheticToken("this", expr.Keyword.Line))
+	c.namedVariable(expr.Keyword)
+	c.emitOpShort(OP_GET_SUPER, c.identifierConstant(expr.Method), expr.Method)
+	return nil
+}
+
+// VisitListExpr 编译列表字面量表达式
+func (c *Compiler) VisitListExpr(expr *ast.List) interface{} {
+	if len(expr.Elements) > maxJump {
+		c.error(expr.Bracket, "列表字面量中的元素过多。")
+		return nil
+	}
+
+	for _, element := range expr.Elements {
+		c.compileExpr(element)
+	}
+	c.emitOpShort(OP_LIST, len(expr.Elements), expr.Bracket)
+	return nil
+}
+
+// VisitMapExpr 编译映射字面量表达式
+func (c *Compiler) VisitMapExpr(expr *ast.Map) interface{} {
+	if len(expr.Keys) > maxJump {
+		c.error(expr.Brace, "映射字面量中的元素过多。")
+		return nil
+	}
+
+	for j, key := range expr.Keys {
+		c.compileExpr(key)
+		c.compileExpr(expr.Values[j])
+	}
+	c.emitOpShort(OP_MAP, len(expr.Keys), expr.Brace)
+	return nil
+}
+
+// VisitLambdaExpr 编译匿名函数表达式，闭包留在栈顶
+func (c *Compiler) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	c.function(expr.Function, TypeFunction)
+	return nil
+}
+
+// VisitInterpolationExpr 编译插值字符串表达式
+func (c *Compiler) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	if len(expr.Parts) > maxJump {
+		c.error(expr.Quote, "插值字符串中的部分过多。")
+		return nil
+	}
+
+	for _, part := range expr.Parts {
+		c.compileExpr(part)
+	}
+	c.emitOpShort(OP_INTERPOLATE, len(expr.Parts), expr.Quote)
+	return nil
+}
+
+// VisitIndexExpr 编译下标访问表达式
+func (c *Compiler) VisitIndexExpr(expr *ast.Index) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.emitOp(OP_GET_INDEX, expr.Bracket)
+	return nil
+}
+
+// VisitIndexSetExpr 编译下标赋值表达式
+func (c *Compiler) VisitIndexSetExpr(expr *ast.IndexSet) interface{} {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.compileExpr(expr.Value)
+	c.emitOp(OP_SET_INDEX, expr.Bracket)
+	return nil
+}
+
+// VisitThrowStmt 编译throw语句
+func (c *Compiler) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	c.compileExpr(stmt.Value)
+	c.emitOp(OP_THROW, stmt.Keyword)
+	return nil
+}
+
+// VisitImportStmt 编译import语句，加载的模块像变量的初始值一样定义到名称上
+func (c *Compiler) VisitImportStmt(stmt *ast.Import) interface{} {
+	c.declareVariable(stmt.Name)
+	c.emitOpShort(OP_IMPORT, c.makeConstant(stmt.Path, stmt.Path), stmt.Path)
+	c.defineVariable(stmt.Name)
+	return nil
+}
+
+// VisitExportStmt 编译export语句，导出的名称由宿主在执行模块前收集，这里只编译声明本身
+func (c *Compiler) VisitExportStmt(stmt *ast.Export) interface{} {
+	c.compileStmt(stmt.Declaration)
+	return nil
+}
+
+// VisitTryStmt 编译try语句，生成的代码结构为:
+//
+//	OP_TRY -> 处理代码1    ; 没有catch子句时指向处理代码2
+//	try块
+//	OP_POP_TRY
+//	OP_JUMP -> 正常结束
+//	处理代码1:              ; 有catch子句时
+//	OP_CATCH               ; 栈顶的异常成为catch变量
+//	OP_TRY -> 处理代码2    ; 有finally子句时
+//	catch块
+//	OP_POP_TRY             ; 有finally子句时
+//	正常结束:
+//	finally块
+//	OP_JUMP -> 结束
+//	处理代码2:              ; 有finally子句时，从catch块进入时catch变量仍在栈上
+//	finally块
+//	OP_THROW               ; 重新抛出暂存在栈顶的异常
+//	结束:
+func (c *Compiler) VisitTryStmt(stmt *ast.Try) interface{} {
+	handler := c.emitJump(OP_TRY, stmt.Keyword)
+	c.protected(stmt.Finally, func() { c.compileStmt(stmt.Body) })
+	c.emitOp(OP_POP_TRY, stmt.Keyword)
+	normalJump := c.emitJump(OP_JUMP, stmt.Keyword)
+
+	// catch块中抛出异常时，catch变量仍在栈上
+	var catchLocal *local
+	if stmt.Catch != nil {
+		c.patchJump(handler)
+		c.beginScope()
+		c.emitOp(OP_CATCH, stmt.CatchName)
+		c.declareLocal(stmt.CatchName)
+		c.markInitialized()
+
+		compileCatch := func() {
+			for _, s := range stmt.Catch.Statements {
+				c.compileStmt(s)
+			}
+		}
+		if stmt.Finally != nil {
+			handler = c.emitJump(OP_TRY, stmt.Keyword)
+			c.protected(stmt.Finally, compileCatch)
+			c.emitOp(OP_POP_TRY, stmt.Keyword)
+			catchLocal = &local{isCaptured: c.current.locals[len(c.current.locals)-1].isCaptured}
+		} else {
+			compileCatch()
+		}
+		c.endScope(stmt.Keyword)
+	}
+	c.patchJump(normalJump)
+
+	if stmt.Finally != nil {
+		c.compileStmt(stmt.Finally)
+		endJump := c.emitJump(OP_JUMP, stmt.Keyword)
+
+		// 异常没有被捕获时，执行finally块后重新抛出
+		c.patchJump(handler)
+		locals := len(c.current.locals)
+		c.beginScope()
+		if catchLocal != nil {
+			// 保留被捕获的标记，finally块跳出时才能正确关闭上值；变量名为空，finally块中不可见
+			catchLocal.depth = c.current.scopeDepth
+			c.current.locals = append(c.current.locals, *catchLocal)
+		}
+		c.declareLocal(syntheticToken("", stmt.Keyword.Line))
+		c.markInitialized()
+		c.compileStmt(stmt.Finally)
+		c.emitOp(OP_THROW, stmt.Keyword)
+		// 重新抛出后不会继续执行，只需结束编译期的作用域
+		c.current.locals = c.current.locals[:locals]
+		c.current.scopeDepth--
+
+		c.patchJump(endJump)
+	}
+	return nil
+}
+
+// protected 在已安装异常处理器的情况下编译一段代码
+func (c *Compiler) protected(finally *ast.Block, compile func()) {
+	state := c.current
+	state.tries = append(state.tries, &tryState{loops: len(state.loops), finally: finally})
+	compile()
+	state.tries = state.tries[:len(state.tries)-1]
+}
+
+// triesOutside 返回在第loopIndex个循环之外开始的try语句数量，跳出该循环时只需离开其余的try语句
+func (c *Compiler) triesOutside(loopIndex int) int {
+	tries := c.current.tries
+	for i, t := range tries {
+		if t.loops > loopIndex {
+			return i
+		}
+	}
+	return len(tries)
+}
+
+// exitTries 为跳出try语句的return、break和continue由内向外移除异常处理器，并执行途经的finally块
+// 只保留最外层的keep个try语句
+func (c *Compiler) exitTries(keep int, tok *token.Token) {
+	state := c.current
+	tries, loops := state.tries, state.loops
+	for i := len(tries) - 1; i >= keep; i-- {
+		c.emitOp(OP_POP_TRY, tok)
+		if finally := tries[i].finally; finally != nil {
+			// finally块中的跳转只能看到try语句外层的循环和try语句
+			state.tries = append([]*tryState(nil), tries[:i]...)
+			state.loops = append([]*loop(nil), loops[:tries[i].loops]...)
+			c.compileStmt(finally)
+		}
+	}
+	state.tries, state.loops = tries, loops
+}
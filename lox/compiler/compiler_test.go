@@ -0,0 +1,195 @@
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/parser"
+	"github.com/aixiasang/goLox/lox/scanner"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// 测试错误报告器
+type TestErrorReporter struct {
+	errors []string
+}
+
+func (er *TestErrorReporter) ReportError(line int, message string) {
+	er.errors = append(er.errors, message)
+}
+
+func (er *TestErrorReporter) ReportErrorAt(span token.Span, message string) {
+	er.errors = append(er.errors, message)
+}
+
+func (er *TestErrorReporter) HasError() bool {
+	return len(er.errors) > 0
+}
+
+func (er *TestErrorReporter) ReportRuntimeError(err error.RuntimeError) {
+	er.errors = append(er.errors, err.Message)
+}
+
+func (er *TestErrorReporter) ResetError() {
+	er.errors = nil
+}
+
+func (er *TestErrorReporter) HasRuntimeError() bool {
+	return false
+}
+
+func (er *TestErrorReporter) Error(token *token.Token, line int, message string) {
+	er.errors = append(er.errors, message)
+}
+
+// compileSource 编译源代码，返回函数原型和错误报告器
+func compileSource(t *testing.T, source string) (*Function, *TestErrorReporter) {
+	t.Helper()
+
+	errors := &TestErrorReporter{}
+	statements := parser.NewParser(scanner.NewScanner(source, errors).ScanTokens(), errors).Parse()
+	if errors.HasError() {
+		t.Fatalf("解析失败: %v", errors.errors)
+	}
+
+	return NewCompiler(errors).Compile(statements), errors
+}
+
+func TestCompileInstructions(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{"全局变量", "var a = 1; print a;", []string{"OP_CONSTANT", "OP_DEFINE_GLOBAL", "OP_GET_GLOBAL", "OP_PRINT"}},
+		{"局部变量", "{ var a = 1; print a; }", []string{"OP_GET_LOCAL", "OP_POP"}},
+		{"闭包捕获", "fun f() { var a = 1; fun g() { return a; } return g; }", []string{"OP_CLOSURE", "local 1", "OP_GET_UPVALUE"}},
+		{"循环", "while (true) { break; }", []string{"OP_JUMP_IF_FALSE", "OP_JUMP", "OP_LOOP"}},
+		{"方法调用", "class A { m() {} } A().m();", []string{"OP_CLASS", "OP_METHOD", "OP_INVOKE"}},
+		{"continue", "for (var i = 0; i < 3; i = i + 1) { continue; }", []string{"OP_JUMP_IF_FALSE", "OP_JUMP", "OP_ADD", "OP_LOOP"}},
+		{"列表", "var xs = [1, 2]; xs[0] = xs[1];", []string{"OP_LIST", "OP_GET_INDEX", "OP_SET_INDEX"}},
+		{"映射", "var m = {\"a\": 1}; m[\"a\"];", []string{"OP_MAP", "OP_GET_INDEX"}},
+		{"继承", "class A { m() {} } class B < A { m() { super.m(); } }", []string{"OP_INHERIT", "OP_SUPER_INVOKE", "OP_CLOSE_UPVALUE"}},
+		{"位运算和乘方", "print ~(1 << 2 | 3 & 4 ^ 5 >> 1) ** 2;", []string{"OP_SHIFT_LEFT", "OP_BIT_AND", "OP_SHIFT_RIGHT", "OP_BIT_XOR", "OP_BIT_OR", "OP_POWER", "OP_BIT_NOT"}},
+		{"插值字符串", "var x = 1; print \"x=${x}!\";", []string{"OP_GET_GLOBAL", "OP_INTERPOLATE      3"}},
+		{"匿名函数", "fun f() { var a = 1; return () => a; } print fun (x) { return x; }(1);", []string{"<fn (anonymous)>", "OP_GET_UPVALUE", "OP_CALL"}},
+		{"默认参数", "fun f(a, b = a) { return [a, b]; }", []string{"OP_SKIP_DEFAULT  (arg 1)", "OP_SET_LOCAL"}},
+		{"异常处理", "try { throw 1; } catch (e) { print e; } finally { print 2; }", []string{"OP_TRY", "OP_THROW", "OP_POP_TRY", "OP_CATCH"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function, errors := compileSource(t, tt.source)
+			if function == nil {
+				t.Fatalf("编译失败: %v", errors.errors)
+			}
+
+			disassembly := Disassemble(function)
+			for _, expected := range tt.expected {
+				if !strings.Contains(disassembly, expected) {
+					t.Errorf("期望字节码包含 %q，实际:\n%s", expected, disassembly)
+				}
+			}
+		})
+	}
+}
+
+// 解析器已经拒绝循环外的break，这里直接构造语法树，测试编译器自己的检查
+func TestCompileErrors(t *testing.T) {
+	keyword := token.NewToken(token.BREAK, "break", nil, 1)
+	name := token.NewToken(token.IDENTIFIER, "f", nil, 1)
+	tests := []struct {
+		name       string
+		statements []ast.Stmt
+		expected   string
+	}{
+		{"循环外break", []ast.Stmt{ast.NewBreak(keyword)}, "Break语句只能在循环内部使用"},
+		{"函数内跳出外层循环", []ast.Stmt{
+			ast.NewWhile(token.NewToken(token.WHILE, "while", nil, 1), ast.NewLiteral(true), ast.NewBlock([]ast.Stmt{
+				ast.NewFunction(name, nil, []ast.Stmt{ast.NewBreak(keyword)}),
+			})),
+		}, "Break语句只能在循环内部使用"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := &TestErrorReporter{}
+			function := NewCompiler(errors).Compile(tt.statements)
+			if function != nil {
+				t.Fatalf("期望编译失败")
+			}
+			if len(errors.errors) == 0 || !strings.Contains(errors.errors[0], tt.expected) {
+				t.Errorf("期望错误 %q，实际: %v", tt.expected, errors.errors)
+			}
+		})
+	}
+}
+
+// 测试跳转距离超出限制时，错误指向对应的if、while、for关键字或'?'运算符
+// 解析得到的语法树中的占位表达式a被替换为编译后超出跳转范围的列表
+func TestCompileJumpErrors(t *testing.T) {
+	name := token.NewToken(token.IDENTIFIER, "a", nil, 3)
+	elements := make([]ast.Expr, 22000)
+	for i := range elements {
+		elements[i] = ast.NewVariable(name)
+	}
+	large := ast.NewList(token.NewToken(token.LEFT_BRACKET, "[", nil, 3), elements)
+
+	tests := []struct {
+		name    string
+		source  string
+		replace func(statements []ast.Stmt)
+		message string
+		lexeme  string
+	}{
+		{"过大的循环体", "var a = 0;\nwhile (a < 1)\n  a;", func(statements []ast.Stmt) {
+			statements[1].(*ast.While).Body = ast.NewExpression(large)
+		}, "循环体过大。", "while"},
+		{"过大的for循环体", "var a = 0;\nfor (;;)\n  a;", func(statements []ast.Stmt) {
+			statements[1].(*ast.While).Body = ast.NewExpression(large)
+		}, "循环体过大。", "for"},
+		{"过大的条件分支", "var a = 0;\nif (a)\n  a;", func(statements []ast.Stmt) {
+			statements[1].(*ast.If).ThenBranch = ast.NewExpression(large)
+		}, "跳转距离过大。", "if"},
+		{"过大的条件表达式", "var a = 0;\na ? a : nil;", func(statements []ast.Stmt) {
+			statements[1].(*ast.Expression).Expr.(*ast.Ternary).ThenBranch = large
+		}, "跳转距离过大。", "?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			errors.SetSinks()
+			statements := parser.NewParser(scanner.NewScanner(tt.source, errors).ScanTokens(), errors).Parse()
+			if errors.HasError() {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+			tt.replace(statements)
+
+			if NewCompiler(errors).Compile(statements) != nil {
+				t.Fatalf("期望编译失败")
+			}
+			diagnostics := errors.Diagnostics()
+			if len(diagnostics) == 0 || diagnostics[0].Message != tt.message || diagnostics[0].Token == nil ||
+				diagnostics[0].Token.Lexeme != tt.lexeme || diagnostics[0].Line != 2 {
+				t.Errorf("期望在第2行的 %q 处报告错误 %q，实际: %v", tt.lexeme, tt.message, diagnostics)
+			}
+		})
+	}
+}
+
+// 测试名称、字符串和数字常量在同一个函数中共享常量表的槽位
+func TestConstantReuse(t *testing.T) {
+	function, errors := compileSource(t, "var xs = [];\nxs.push(1.5);\nxs.push(1.5);\nxs.push(\"xs\");\nxs.push(1);\nxs.push(-0.0);")
+	if function == nil {
+		t.Fatalf("编译失败: %v", errors.errors)
+	}
+
+	expected := []interface{}{"xs", 1.5, "push", int64(1), 0.0}
+	if !reflect.DeepEqual(function.Chunk.Constants, expected) {
+		t.Errorf("期望常量表 %v，实际: %v", expected, function.Chunk.Constants)
+	}
+}
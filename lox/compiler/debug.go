@@ -0,0 +1,107 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Disassemble 反汇编函数及其嵌套函数的字节码
+func Disassemble(function *Function) string {
+	var sb strings.Builder
+	disassembleFunction(&sb, function)
+	return sb.String()
+}
+
+// disassembleFunction 反汇编单个函数，随后递归处理常量表中的函数
+func disassembleFunction(sb *strings.Builder, function *Function) {
+	fmt.Fprintf(sb, "== %s ==\n", function)
+
+	chunk := function.Chunk
+	for offset := 0; offset < len(chunk.Code); {
+		offset = disassembleInstruction(sb, chunk, offset)
+	}
+
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			disassembleFunction(sb, nested)
+		}
+	}
+}
+
+// disassembleInstruction 反汇编一条指令并返回下一条指令的偏移
+func disassembleInstruction(sb *strings.Builder, chunk *Chunk, offset int) int {
+	fmt.Fprintf(sb, "%04d ", offset)
+	if tok := chunk.Tokens[offset]; tok != nil && (offset == 0 || chunk.Tokens[offset-1] == nil || chunk.Tokens[offset-1].Line != tok.Line) {
+		fmt.Fprintf(sb, "%4d ", tok.Line)
+	} else {
+		sb.WriteString("   | ")
+	}
+
+	op := OpCode(chunk.Code[offset])
+	switch op {
+	case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL,
+		OP_GET_PROPERTY, OP_SET_PROPERTY, OP_GET_SUPER, OP_CLASS, OP_METHOD, OP_IMPORT:
+		index := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(sb, "%-16s %4d '%s'\n", op, index, constantString(chunk.Constants[index]))
+		return offset + 3
+	case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL, OP_TAIL_CALL:
+		fmt.Fprintf(sb, "%-16s %4d\n", op, chunk.Code[offset+1])
+		return offset + 2
+	case OP_LIST, OP_MAP, OP_INTERPOLATE:
+		fmt.Fprintf(sb, "%-16s %4d\n", op, chunk.ReadShort(offset+1))
+		return offset + 3
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_TRY:
+		jump := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(sb, "%-16s %4d -> %d\n", op, offset, offset+3+jump)
+		return offset + 3
+	case OP_SKIP_DEFAULT:
+		jump := chunk.ReadShort(offset + 2)
+		fmt.Fprintf(sb, "%-16s (arg %d) %4d -> %d\n", op, chunk.Code[offset+1], offset, offset+4+jump)
+		return offset + 4
+	case OP_LOOP:
+		jump := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(sb, "%-16s %4d -> %d\n", op, offset, offset+3-jump)
+		return offset + 3
+	case OP_INVOKE, OP_SUPER_INVOKE, OP_TAIL_INVOKE, OP_TAIL_SUPER, OP_CALL_NAMED, OP_TAIL_NAMED:
+		index := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(sb, "%-16s (%d args) %4d '%s'\n", op, chunk.Code[offset+3], index, constantString(chunk.Constants[index]))
+		return offset + 4
+	case OP_CLOSURE:
+		index := chunk.ReadShort(offset + 1)
+		function := chunk.Constants[index].(*Function)
+		fmt.Fprintf(sb, "%-16s %4d %s\n", op, index, function)
+		offset += 3
+		for i := 0; i < function.UpvalueCount; i++ {
+			kind := "upvalue"
+			if chunk.Code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(sb, "%04d    |                     %s %d\n", offset, kind, chunk.Code[offset+1])
+			offset += 2
+		}
+		return offset
+	default:
+		fmt.Fprintf(sb, "%s\n", op)
+		return offset + 1
+	}
+}
+
+// constantString 返回常量在反汇编输出中的表示
+func constantString(value interface{}) string {
+	switch v := value.(type) {
+	case *token.Token:
+		return v.Lexeme
+	case []*token.Token:
+		names := make([]string, len(v))
+		for i, name := range v {
+			names[i] = name.Lexeme
+		}
+		return strings.Join(names, ", ")
+	case nil:
+		return "nil"
+	default:
+		return fmt.Sprint(v)
+	}
+}
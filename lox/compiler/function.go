@@ -0,0 +1,34 @@
+package compiler
+
+import "fmt"
+
+// Function 编译后的函数原型
+type Function struct {
+	Name         string   // 函数名，顶层脚本为空
+	Module       string   // 模块顶层代码所属的模块文件名，其他函数为空
+	Arity        int      // 参数个数，包括有默认值的参数和剩余参数
+	MinArity     int      // 调用时至少需要传入的参数个数
+	Variadic     bool     // 最后一个参数是否为剩余参数
+	Params       []string // 剩余参数之外的参数名，用于按名称传参
+	UpvalueCount int      // 捕获的上值个数
+	Chunk        *Chunk   // 函数体字节码
+}
+
+// NewFunction 创建一个新的函数原型
+func NewFunction(name string) *Function {
+	return &Function{
+		Name:  name,
+		Chunk: NewChunk(),
+	}
+}
+
+// String 返回函数的字符串表示
+func (f *Function) String() string {
+	if f.Module != "" {
+		return "<module " + f.Module + ">"
+	}
+	if f.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
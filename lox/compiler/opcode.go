@@ -0,0 +1,164 @@
+package compiler
+
+// OpCode 字节码指令
+type OpCode byte
+
+const (
+	// 常量与字面量
+	OP_CONSTANT OpCode = iota // 压入常量，操作数: 常量索引(2字节)
+	OP_NIL                    // 压入nil
+	OP_TRUE                   // 压入true
+	OP_FALSE                  // 压入false
+	OP_POP                    // 弹出栈顶
+
+	// 变量访问
+	OP_GET_LOCAL     // 读取局部变量，操作数: 槽位(1字节)
+	OP_SET_LOCAL     // 写入局部变量，操作数: 槽位(1字节)
+	OP_GET_GLOBAL    // 读取全局变量，操作数: 名称标记常量索引(2字节)
+	OP_DEFINE_GLOBAL // 定义全局变量，操作数: 名称标记常量索引(2字节)
+	OP_SET_GLOBAL    // 写入全局变量，操作数: 名称标记常量索引(2字节)
+	OP_GET_UPVALUE   // 读取上值，操作数: 上值索引(1字节)
+	OP_SET_UPVALUE   // 写入上值，操作数: 上值索引(1字节)
+	OP_GET_PROPERTY  // 读取属性，操作数: 名称标记常量索引(2字节)
+	OP_SET_PROPERTY  // 写入属性，操作数: 名称标记常量索引(2字节)
+	OP_GET_SUPER     // 读取父类方法，操作数: 名称标记常量索引(2字节)
+	OP_GET_INDEX     // 读取下标
+	OP_SET_INDEX     // 写入下标
+
+	// 运算
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_MODULO
+	OP_POWER
+	OP_BIT_AND
+	OP_BIT_OR
+	OP_BIT_XOR
+	OP_SHIFT_LEFT
+	OP_SHIFT_RIGHT
+	OP_NOT
+	OP_NEGATE
+	OP_BIT_NOT
+
+	// 语句与控制流
+	OP_PRINT         // 打印栈顶
+	OP_JUMP          // 无条件向前跳转，操作数: 偏移(2字节)
+	OP_JUMP_IF_FALSE // 栈顶为假时向前跳转(不弹出)，操作数: 偏移(2字节)
+	OP_LOOP          // 向后跳转，操作数: 偏移(2字节)
+	OP_SKIP_DEFAULT  // 调用时传入了该参数则向前跳过计算默认值的代码，操作数: 参数序号(1字节)、偏移(2字节)
+
+	// 函数与类
+	OP_CALL          // 调用，操作数: 参数个数(1字节)
+	OP_TAIL_CALL     // 尾调用，复用当前栈帧，操作数: 参数个数(1字节)
+	OP_INVOKE        // 调用方法，操作数: 名称标记常量索引(2字节)、参数个数(1字节)
+	OP_SUPER_INVOKE  // 调用父类方法，操作数: 名称标记常量索引(2字节)、参数个数(1字节)
+	OP_TAIL_INVOKE   // 以尾调用方式调用方法，操作数同OP_INVOKE
+	OP_TAIL_SUPER    // 以尾调用方式调用父类方法，操作数同OP_SUPER_INVOKE
+	OP_CALL_NAMED    // 带命名参数的调用，操作数: 参数名列表常量索引(2字节)、参数个数(1字节)
+	OP_TAIL_NAMED    // 以尾调用方式进行带命名参数的调用，操作数同OP_CALL_NAMED
+	OP_CLOSURE       // 创建闭包，操作数: 函数常量索引(2字节)，随后每个上值2字节(isLocal, index)
+	OP_CLOSE_UPVALUE // 关闭栈顶局部变量对应的上值
+	OP_RETURN        // 从函数返回
+	OP_CLASS         // 创建类，操作数: 类名常量索引(2字节)
+	OP_INHERIT       // 继承父类方法
+	OP_METHOD        // 定义方法，操作数: 方法名常量索引(2字节)
+
+	// 异常
+	OP_THROW   // 抛出栈顶的值
+	OP_TRY     // 安装异常处理器，操作数: 到处理代码的偏移(2字节)
+	OP_POP_TRY // 移除最近安装的异常处理器
+	OP_CATCH   // 将处理器压入的异常转换为catch变量的值
+
+	// 集合
+	OP_LIST // 用栈顶的元素创建列表，操作数: 元素个数(2字节)
+	OP_MAP  // 用栈顶的键值对创建映射，操作数: 键值对个数(2字节)
+
+	// 字符串
+	OP_INTERPOLATE // 将栈顶的值转换为字符串后拼接，操作数: 值的个数(2字节)
+
+	// 模块
+	OP_IMPORT // 加载模块并压入栈顶，操作数: 路径标记常量索引(2字节)
+)
+
+// opNames 指令名称，用于反汇编
+var opNames = map[OpCode]string{
+	OP_CONSTANT:      "OP_CONSTANT",
+	OP_NIL:           "OP_NIL",
+	OP_TRUE:          "OP_TRUE",
+	OP_FALSE:         "OP_FALSE",
+	OP_POP:           "OP_POP",
+	OP_GET_LOCAL:     "OP_GET_LOCAL",
+	OP_SET_LOCAL:     "OP_SET_LOCAL",
+	OP_GET_GLOBAL:    "OP_GET_GLOBAL",
+	OP_DEFINE_GLOBAL: "OP_DEFINE_GLOBAL",
+	OP_SET_GLOBAL:    "OP_SET_GLOBAL",
+	OP_GET_UPVALUE:   "OP_GET_UPVALUE",
+	OP_SET_UPVALUE:   "OP_SET_UPVALUE",
+	OP_GET_PROPERTY:  "OP_GET_PROPERTY",
+	OP_SET_PROPERTY:  "OP_SET_PROPERTY",
+	OP_GET_SUPER:     "OP_GET_SUPER",
+	OP_GET_INDEX:     "OP_GET_INDEX",
+	OP_SET_INDEX:     "OP_SET_INDEX",
+	OP_EQUAL:         "OP_EQUAL",
+	OP_NOT_EQUAL:     "OP_NOT_EQUAL",
+	OP_GREATER:       "OP_GREATER",
+	OP_GREATER_EQUAL: "OP_GREATER_EQUAL",
+	OP_LESS:          "OP_LESS",
+	OP_LESS_EQUAL:    "OP_LESS_EQUAL",
+	OP_ADD:           "OP_ADD",
+	OP_SUBTRACT:      "OP_SUBTRACT",
+	OP_MULTIPLY:      "OP_MULTIPLY",
+	OP_DIVIDE:        "OP_DIVIDE",
+	OP_MODULO:        "OP_MODULO",
+	OP_POWER:         "OP_POWER",
+	OP_BIT_AND:       "OP_BIT_AND",
+	OP_BIT_OR:        "OP_BIT_OR",
+	OP_BIT_XOR:       "OP_BIT_XOR",
+	OP_SHIFT_LEFT:    "OP_SHIFT_LEFT",
+	OP_SHIFT_RIGHT:   "OP_SHIFT_RIGHT",
+	OP_NOT:           "OP_NOT",
+	OP_NEGATE:        "OP_NEGATE",
+	OP_BIT_NOT:       "OP_BIT_NOT",
+	OP_PRINT:         "OP_PRINT",
+	OP_JUMP:          "OP_JUMP",
+	OP_JUMP_IF_FALSE: "OP_JUMP_IF_FALSE",
+	OP_LOOP:          "OP_LOOP",
+	OP_SKIP_DEFAULT:  "OP_SKIP_DEFAULT",
+	OP_CALL:          "OP_CALL",
+	OP_TAIL_CALL:     "OP_TAIL_CALL",
+	OP_INVOKE:        "OP_INVOKE",
+	OP_SUPER_INVOKE:  "OP_SUPER_INVOKE",
+	OP_TAIL_INVOKE:   "OP_TAIL_INVOKE",
+	OP_TAIL_SUPER:    "OP_TAIL_SUPER",
+	OP_CALL_NAMED:    "OP_CALL_NAMED",
+	OP_TAIL_NAMED:    "OP_TAIL_NAMED",
+	OP_CLOSURE:       "OP_CLOSURE",
+	OP_CLOSE_UPVALUE: "OP_CLOSE_UPVALUE",
+	OP_RETURN:        "OP_RETURN",
+	OP_CLASS:         "OP_CLASS",
+	OP_INHERIT:       "OP_INHERIT",
+	OP_METHOD:        "OP_METHOD",
+	OP_THROW:         "OP_THROW",
+	OP_TRY:           "OP_TRY",
+	OP_POP_TRY:       "OP_POP_TRY",
+	OP_CATCH:         "OP_CATCH",
+	OP_LIST:          "OP_LIST",
+	OP_MAP:           "OP_MAP",
+	OP_INTERPOLATE:   "OP_INTERPOLATE",
+	OP_IMPORT:        "OP_IMPORT",
+}
+
+// String 返回指令名称
+func (op OpCode) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return "OP_UNKNOWN"
+}
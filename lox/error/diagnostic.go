@@ -0,0 +1,84 @@
+package error
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Kind 诊断信息的来源阶段
+type Kind int
+
+const (
+	KindScan    Kind = iota // 词法分析
+	KindParse               // 语法分析
+	KindResolve             // 静态检查(变量解析、字节码编译)
+	KindRuntime             // 运行时
+)
+
+// String 返回阶段名称
+func (k Kind) String() string {
+	switch k {
+	case KindScan:
+		return "scan"
+	case KindParse:
+		return "parse"
+	case KindResolve:
+		return "resolve"
+	case KindRuntime:
+		return "runtime"
+	}
+	return "unknown"
+}
+
+// Severity 诊断信息的严重程度
+type Severity int
+
+const (
+	SeverityError   Severity = iota // 错误，会中止执行
+	SeverityWarning                 // 警告，不影响执行
+)
+
+// String 返回严重程度的中文名称
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "警告"
+	}
+	return "错误"
+}
+
+// Diagnostic 一条结构化的诊断信息
+type Diagnostic struct {
+	Kind     Kind         // 来源阶段
+	Severity Severity     // 严重程度
+	Token    *token.Token // 出错位置的标记(可能为nil)
+	File     string       // 出错位置所在的文件，主脚本中为空
+	Line     int          // 行号，未知时为0
+	Column   int          // 列号，从1开始按字符计数，未知时为0
+	Span     token.Span   // 出错位置在源代码中的区间，未知时为零值
+	Message  string       // 错误信息
+	Excerpt  string       // 带^标记的源代码摘录，没有源代码时为空
+	Trace    []StackFrame // 运行时错误发生时的Lox调用栈，最外层在前
+}
+
+// String 返回诊断信息的单行文本格式，不含源代码摘录
+func (d Diagnostic) String() string {
+	where := ""
+	if d.Token != nil {
+		if d.Token.Type == token.EOF {
+			where = "在文件末尾"
+		} else {
+			where = fmt.Sprintf("在 '%s'", d.Token.Lexeme)
+		}
+	}
+
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("[%s 行 %d] %s %s: %s", d.File, d.Line, d.Severity, where, d.Message)
+	case d.File != "":
+		return fmt.Sprintf("[%s] %s %s: %s", d.File, d.Severity, where, d.Message)
+	case d.Line > 0:
+		return fmt.Sprintf("[行 %d] %s %s: %s", d.Line, d.Severity, where, d.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", d.Severity, where, d.Message)
+}
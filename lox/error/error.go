@@ -2,83 +2,173 @@ package error
 
 import (
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/aixiasang/goLox/lox/token"
 )
 
-// 错误标记，用于标识是否发生错误
-var HadError = false
-var HadRuntimeError = false
-
 // Reporter 错误报告接口
 type Reporter interface {
 	Error(tok *token.Token, line int, message string)
 	ReportError(line int, message string)
+	ReportErrorAt(span token.Span, message string)
+	ReportRuntimeError(err RuntimeError)
 	ResetError()
 	HasError() bool
 	HasRuntimeError() bool
 }
 
 // ErrorReporter 错误报告实现
-type ErrorReporter struct{}
+// 每个实例独立记录错误状态和诊断信息，并将诊断信息分发给所有Sink
+type ErrorReporter struct {
+	kind            Kind         // 当前阶段，用于标记非运行时诊断的来源
+	diagnostics     []Diagnostic // 自上次重置以来收集的诊断信息
+	hadError        bool
+	hadRuntimeError bool
+	sinks           []Sink
+	source          string // 当前执行的源代码，用于生成源代码摘录
+	file            string // 当前执行的源文件名，主脚本为空
+}
 
-// NewErrorReporter 创建一个新的错误报告器
+// NewErrorReporter 创建一个新的错误报告器，默认输出到标准错误
 func NewErrorReporter() *ErrorReporter {
-	return &ErrorReporter{}
+	return &ErrorReporter{
+		kind:  KindParse,
+		sinks: []Sink{NewStderrSink()},
+	}
+}
+
+// SetSinks 替换全部输出目标，不传参数时不输出任何内容
+func (r *ErrorReporter) SetSinks(sinks ...Sink) {
+	r.sinks = sinks
+}
+
+// SetOutput 将诊断信息改为只写入w
+func (r *ErrorReporter) SetOutput(w io.Writer) {
+	r.SetSinks(NewWriterSink(w))
+}
+
+// AddSink 添加一个输出目标
+func (r *ErrorReporter) AddSink(sink Sink) {
+	r.sinks = append(r.sinks, sink)
+}
+
+// SetKind 设置当前阶段，之后通过Error和ReportError报告的诊断都属于该阶段
+func (r *ErrorReporter) SetKind(kind Kind) {
+	r.kind = kind
+}
+
+// SetSource 设置当前执行的源代码，之后的诊断信息会附带出错位置的源代码摘录
+func (r *ErrorReporter) SetSource(source string) {
+	r.source = source
+}
+
+// SetFile 设置当前执行的源文件名，之后没有标记来源的诊断信息都属于该文件
+func (r *ErrorReporter) SetFile(file string) {
+	r.file = file
+}
+
+// Diagnostics 返回自上次重置以来收集的诊断信息
+func (r *ErrorReporter) Diagnostics() []Diagnostic {
+	return append([]Diagnostic(nil), r.diagnostics...)
 }
 
 // HasError 返回是否有错误发生
 func (r *ErrorReporter) HasError() bool {
-	return HadError
+	return r.hadError
 }
 
 // HasRuntimeError 返回是否有运行时错误发生
 func (r *ErrorReporter) HasRuntimeError() bool {
-	return HadRuntimeError
+	return r.hadRuntimeError
 }
 
 // Error 报告错误
 func (r *ErrorReporter) Error(tok *token.Token, line int, message string) {
+	d := Diagnostic{Kind: r.kind, Severity: SeverityError, Token: tok, Line: line, Message: message}
 	if tok != nil {
-		if tok.Type == token.EOF {
-			r.report(tok.Line, "在文件末尾", message)
-		} else {
-			r.report(tok.Line, fmt.Sprintf("在 '%s'", tok.Lexeme), message)
-		}
-	} else if line > 0 {
-		r.report(line, "", message)
-	} else {
-		fmt.Fprintf(os.Stderr, "[错误] %s\n", message)
-		HadError = true
+		d.Line = tok.Line
+		d.Column = tok.Column
+		d.Span = tok.Span()
 	}
+	r.Report(d)
 }
 
 // ReportError 报告一般性错误（不与特定标记关联）
 func (r *ErrorReporter) ReportError(line int, message string) {
-	r.report(line, "", message)
+	r.Error(nil, line, message)
 }
 
-// ResetError 重置错误标记
-func (r *ErrorReporter) ResetError() {
-	HadError = false
-	HadRuntimeError = false
+// ReportErrorAt 报告发生在源代码某个区间的错误（不与特定标记关联）
+func (r *ErrorReporter) ReportErrorAt(span token.Span, message string) {
+	r.Report(Diagnostic{
+		Kind:     r.kind,
+		Severity: SeverityError,
+		Line:     span.Line,
+		Column:   span.Column,
+		Span:     span,
+		Message:  message,
+	})
+}
+
+// ReportRuntimeError 报告运行时错误
+func (r *ErrorReporter) ReportRuntimeError(err RuntimeError) {
+	d := Diagnostic{Kind: KindRuntime, Severity: SeverityError, Token: err.Token, Message: err.Message, Trace: err.Trace}
+	if err.Token != nil {
+		d.Line = err.Token.Line
+		d.Column = err.Token.Column
+		d.Span = err.Token.Span()
+	}
+	r.Report(d)
 }
 
-// report 报告错误辅助方法
-func (r *ErrorReporter) report(line int, where string, message string) {
-	if line > 0 {
-		fmt.Fprintf(os.Stderr, "[行 %d] 错误 %s: %s\n", line, where, message)
-	} else {
-		fmt.Fprintf(os.Stderr, "错误 %s: %s\n", where, message)
+// Report 记录一条诊断信息并分发给所有输出目标
+func (r *ErrorReporter) Report(d Diagnostic) {
+	// 被导入模块中的标记带有自己的源文件
+	if d.Token != nil && d.Token.Source != nil {
+		if d.File == "" {
+			d.File = d.Token.Source.Name
+		}
+		if d.Excerpt == "" {
+			d.Excerpt = Excerpt(d.Token.Source.Text, d.Span, d.Token)
+		}
+	}
+	if d.File == "" {
+		d.File = r.file
+	}
+	if d.Excerpt == "" && r.source != "" {
+		d.Excerpt = Excerpt(r.source, d.Span, d.Token)
+	}
+	r.diagnostics = append(r.diagnostics, d)
+
+	if d.Severity == SeverityError {
+		if d.Kind == KindRuntime {
+			r.hadRuntimeError = true
+		} else {
+			r.hadError = true
+		}
 	}
-	HadError = true
+
+	for _, sink := range r.sinks {
+		sink.Report(d)
+	}
+}
+
+// ResetError 重置错误标记并清空诊断信息
+func (r *ErrorReporter) ResetError() {
+	r.hadError = false
+	r.hadRuntimeError = false
+	r.diagnostics = nil
 }
 
 // RuntimeError 运行时错误类型
 type RuntimeError struct {
 	Token   *token.Token
 	Message string
+	Trace   []StackFrame // 出错时的Lox调用栈，最外层在前；错误发生在顶层代码时为空
+	Value   interface{}  // throw语句抛出的值，Thrown为true时有效
+	Thrown  bool         // 是否由throw语句抛出
+	Fatal   bool         // 为true时不能被try语句捕获，例如执行被取消
 }
 
 // Error 实现error接口
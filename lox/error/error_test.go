@@ -0,0 +1,198 @@
+package error
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+func TestReportersAreIndependent(t *testing.T) {
+	first := NewErrorReporter()
+	first.SetSinks()
+	second := NewErrorReporter()
+	second.SetSinks()
+
+	first.ReportError(1, "出错了")
+	if !first.HasError() {
+		t.Errorf("第一个报告器应该记录错误")
+	}
+	if second.HasError() || len(second.Diagnostics()) != 0 {
+		t.Errorf("第二个报告器不应受到影响")
+	}
+
+	first.ResetError()
+	if first.HasError() || len(first.Diagnostics()) != 0 {
+		t.Errorf("重置后不应有错误")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	var out bytes.Buffer
+	r := NewErrorReporter()
+	r.SetSinks(NewWriterSink(&out))
+
+	name := token.NewToken(token.IDENTIFIER, "x", nil, 3)
+	r.SetKind(KindResolve)
+	r.Error(name, 0, "不能在变量初始化中引用自身。")
+	r.ReportRuntimeError(RuntimeError{Token: name, Message: "未定义的变量 'x'。"})
+
+	diagnostics := r.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("期望2条诊断信息，实际: %v", diagnostics)
+	}
+
+	if d := diagnostics[0]; d.Kind != KindResolve || d.Severity != SeverityError || d.Token != name || d.Line != 3 {
+		t.Errorf("静态错误诊断不正确: %+v", d)
+	}
+	if d := diagnostics[1]; d.Kind != KindRuntime || d.Line != 3 {
+		t.Errorf("运行时错误诊断不正确: %+v", d)
+	}
+
+	if !r.HasError() || !r.HasRuntimeError() {
+		t.Errorf("期望同时记录静态错误和运行时错误")
+	}
+
+	expected := "[行 3] 错误 在 'x': 不能在变量初始化中引用自身。\n" +
+		"[行 3] 错误 在 'x': 未定义的变量 'x'。\n"
+	if out.String() != expected {
+		t.Errorf("输出不正确。\n期望:\n%s\n实际:\n%s", expected, out.String())
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	at := func(line, column, offset, length int) token.Span {
+		return token.Span{Line: line, Column: column, Offset: offset, Length: length}
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		span     token.Span
+		tok      *token.Token
+		expected string
+	}{
+		{"单个字符", "print x + 1;", at(1, 7, 6, 1), nil, "1 | print x + 1;\n  |       ^"},
+		{"多个字符", "a;\nprint foo;", at(2, 7, 9, 3), nil, "2 | print foo;\n  |       ^^^"},
+		{"中文占两列", "var 名字 = @;", at(1, 10, 13, 1), nil, "1 | var 名字 = @;\n  |            ^"},
+		{"保留制表符", "\tprint @;", at(1, 8, 7, 1), nil, "1 | \tprint @;\n  | \t      ^"},
+		{"文件末尾", "print", at(1, 6, 5, 0), nil, "1 | print\n  |      ^"},
+		{"跨行只标出第一行", "\"ab\ncd\"", at(1, 1, 0, 7), nil, "1 | \"ab\n  | ^^^"},
+		{"未知区间", "print x;", token.Span{}, nil, ""},
+		{"超出范围", "x;", at(1, 5, 4, 1), nil, ""},
+		{"标记来自其他源代码", "print y;", at(1, 7, 6, 1), token.NewToken(token.IDENTIFIER, "x", nil, 1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Excerpt(tt.source, tt.span, tt.tok)
+			if actual != tt.expected {
+				t.Errorf("期望:\n%q\n实际:\n%q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsWithSource(t *testing.T) {
+	var out bytes.Buffer
+	r := NewErrorReporter()
+	r.SetSinks(NewWriterSink(&out))
+	r.SetSource("var a = 1;\nprint a + b;")
+
+	b := &token.Token{Type: token.IDENTIFIER, Lexeme: "b", Line: 2, Column: 11, Offset: 21, Length: 1}
+	r.ReportRuntimeError(RuntimeError{Token: b, Message: "未定义的变量 'b'。"})
+	r.SetKind(KindScan)
+	r.ReportErrorAt(token.Span{Line: 1, Column: 9, Offset: 8, Length: 1}, "示例错误。")
+
+	diagnostics := r.Diagnostics()
+	if d := diagnostics[0]; d.Column != 11 || d.Span != b.Span() {
+		t.Errorf("运行时错误的位置不正确: %+v", d)
+	}
+	if d := diagnostics[1]; d.Kind != KindScan || d.Line != 1 || d.Column != 9 {
+		t.Errorf("词法错误的位置不正确: %+v", d)
+	}
+
+	expected := "[行 2] 错误 在 'b': 未定义的变量 'b'。\n" +
+		"2 | print a + b;\n" +
+		"  |           ^\n" +
+		"[行 1] 错误 : 示例错误。\n" +
+		"1 | var a = 1;\n" +
+		"  |         ^\n"
+	if out.String() != expected {
+		t.Errorf("输出不正确。\n期望:\n%s\n实际:\n%s", expected, out.String())
+	}
+}
+
+// 测试被导入模块中的诊断信息带有模块的文件名和源代码摘录
+func TestDiagnosticsWithFile(t *testing.T) {
+	var out bytes.Buffer
+	r := NewErrorReporter()
+	r.SetSinks(NewWriterSink(&out))
+	r.SetSource("import \"m.lox\" as m;\nm.f();")
+
+	// 模块中的标记带有自己的源文件，优先于报告器的源代码
+	source := &token.Source{Name: "lib/m.lox", Text: "export fun f() {\n  return -nil;\n}"}
+	minus := &token.Token{Type: token.MINUS, Lexeme: "-", Line: 2, Column: 10, Offset: 26, Length: 1, Source: source}
+	r.ReportRuntimeError(RuntimeError{Token: minus, Message: "操作数必须是数字。", Trace: []StackFrame{
+		NewStackFrame("<script>", &token.Token{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 2}),
+		NewStackFrame("<fn f>", minus),
+	}})
+
+	// 没有标记的诊断属于报告器当前的文件
+	r.SetFile("lib/n.lox")
+	r.SetKind(KindResolve)
+	r.ReportError(0, "局部变量 'x' 已声明但从未使用")
+
+	if d := r.Diagnostics()[0]; d.File != "lib/m.lox" {
+		t.Errorf("期望文件名lib/m.lox，实际: %q", d.File)
+	}
+
+	expected := "[lib/m.lox 行 2] 错误 在 '-': 操作数必须是数字。\n" +
+		"2 |   return -nil;\n" +
+		"  |          ^\n" +
+		"调用栈(最近的调用在最后):\n" +
+		"  [行 2] <script>\n" +
+		"  [lib/m.lox 行 2] <fn f>\n" +
+		"[lib/n.lox] 错误 : 局部变量 'x' 已声明但从未使用\n"
+	if out.String() != expected {
+		t.Errorf("输出不正确。\n期望:\n%s\n实际:\n%s", expected, out.String())
+	}
+}
+
+func TestFormatTrace(t *testing.T) {
+	call := func(line int) *token.Token {
+		return token.NewToken(token.RIGHT_PAREN, ")", nil, line)
+	}
+
+	if trace := FormatTrace(nil); trace != "" {
+		t.Errorf("没有栈帧时期望空字符串，实际: %q", trace)
+	}
+
+	frames := []StackFrame{
+		NewStackFrame("<script>", call(9)),
+		NewStackFrame("<fn outer>", call(5)),
+		NewStackFrame("<fn inner>", nil),
+	}
+	expected := "调用栈(最近的调用在最后):\n" +
+		"  [行 9] <script>\n" +
+		"  [行 5] <fn outer>\n" +
+		"  <fn inner>"
+	if trace := FormatTrace(frames); trace != expected {
+		t.Errorf("调用栈不正确。\n期望:\n%s\n实际:\n%s", expected, trace)
+	}
+
+	// 连续相同的栈帧只显示前三次
+	frames = []StackFrame{NewStackFrame("<script>", call(3))}
+	for i := 0; i < 10; i++ {
+		frames = append(frames, NewStackFrame("<fn f>", call(1)))
+	}
+	expected = "调用栈(最近的调用在最后):\n" +
+		"  [行 3] <script>\n" +
+		"  [行 1] <fn f>\n" +
+		"  [行 1] <fn f>\n" +
+		"  [行 1] <fn f>\n" +
+		"  [上一行又重复了7次]"
+	if trace := FormatTrace(frames); trace != expected {
+		t.Errorf("调用栈不正确。\n期望:\n%s\n实际:\n%s", expected, trace)
+	}
+}
@@ -0,0 +1,75 @@
+package error
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Excerpt 返回区间所在行的源代码，并在下一行用^标出区间，例如:
+//
+//	3 | print x + 1;
+//	  |       ^
+//
+// 区间未知、超出源代码范围，或者与tok的词素不一致(例如标记来自另一段源代码)时返回空字符串。
+// 跨越多行的区间只标出第一行。
+func Excerpt(source string, span token.Span, tok *token.Token) string {
+	if span.IsZero() || span.Offset < 0 || span.End() > len(source) {
+		return ""
+	}
+	if tok != nil && source[span.Offset:span.End()] != tok.Lexeme {
+		return ""
+	}
+
+	lineStart := strings.LastIndexByte(source[:span.Offset], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[span.Offset:], '\n'); i >= 0 {
+		lineEnd = span.Offset + i
+	}
+	line := strings.TrimSuffix(source[lineStart:lineEnd], "\r")
+
+	end := span.End()
+	if end > lineStart+len(line) {
+		end = lineStart + len(line)
+	}
+
+	// 制表符原样保留，其他字符按显示宽度替换为空格，使^与源代码对齐
+	var marker strings.Builder
+	for _, r := range source[lineStart:span.Offset] {
+		if r == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	width := 0
+	if end > span.Offset {
+		for _, r := range source[span.Offset:end] {
+			width += runeWidth(r)
+		}
+	}
+	if width == 0 {
+		width = 1
+	}
+	marker.WriteString(strings.Repeat("^", width))
+
+	gutter := strconv.Itoa(span.Line)
+	return fmt.Sprintf("%s | %s\n%s | %s", gutter, line, strings.Repeat(" ", len(gutter)), marker.String())
+}
+
+// runeWidth 返回字符在等宽终端中占用的列数，中日韩文字和全角符号占两列
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r):
+		return 0
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+		r >= 0x3000 && r <= 0x303F,
+		r >= 0xFF01 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6:
+		return 2
+	}
+	return 1
+}
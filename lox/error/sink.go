@@ -0,0 +1,46 @@
+package error
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Sink 诊断信息的输出目标
+type Sink interface {
+	Report(d Diagnostic)
+}
+
+// SinkFunc 将普通函数适配为Sink
+type SinkFunc func(d Diagnostic)
+
+// Report 调用函数本身
+func (f SinkFunc) Report(d Diagnostic) {
+	f(d)
+}
+
+// WriterSink 将诊断信息逐行写入io.Writer
+type WriterSink struct {
+	w io.Writer
+}
+
+// NewWriterSink 创建写入指定io.Writer的Sink
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewStderrSink 创建写入标准错误的Sink
+func NewStderrSink() *WriterSink {
+	return NewWriterSink(os.Stderr)
+}
+
+// Report 写入一条诊断信息，随后依次写入源代码摘录和调用栈(如果有)
+func (s *WriterSink) Report(d Diagnostic) {
+	fmt.Fprintln(s.w, d.String())
+	if d.Excerpt != "" {
+		fmt.Fprintln(s.w, d.Excerpt)
+	}
+	if trace := FormatTrace(d.Trace); trace != "" {
+		fmt.Fprintln(s.w, trace)
+	}
+}
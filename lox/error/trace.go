@@ -0,0 +1,83 @@
+package error
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// maxRepeatedFrames 连续相同的栈帧最多显示的次数，其余的合并为一行
+const maxRepeatedFrames = 3
+
+// StackFrame Lox调用栈中的一帧
+type StackFrame struct {
+	Function string       // 函数名，例如"<fn fib>"，顶层代码为"<script>"
+	Token    *token.Token // 该帧正在执行的位置：外层帧为调用处，最内层帧为出错处(可能为nil)
+	Line     int          // 行号，未知时为0
+	File     string       // 该位置所在的文件，主脚本中为空
+}
+
+// NewStackFrame 创建栈帧，行号取自tok
+func NewStackFrame(function string, tok *token.Token) StackFrame {
+	frame := StackFrame{Function: function, Token: tok}
+	if tok != nil {
+		frame.Line = tok.Line
+		if tok.Source != nil {
+			frame.File = tok.Source.Name
+		}
+	}
+	return frame
+}
+
+// String 返回栈帧的单行文本格式
+func (f StackFrame) String() string {
+	if f.File != "" && f.Line > 0 {
+		return fmt.Sprintf("[%s 行 %d] %s", f.File, f.Line, f.Function)
+	}
+	if f.Line > 0 {
+		return fmt.Sprintf("[行 %d] %s", f.Line, f.Function)
+	}
+	return f.Function
+}
+
+// FormatTrace 返回多行的调用栈文本，最近的调用在最后，例如:
+//
+//	调用栈(最近的调用在最后):
+//	  [行 9] <script>
+//	  [行 5] <fn outer>
+//	  [行 2] <fn inner>
+//
+// 无限递归产生的连续相同栈帧只显示前几次。没有栈帧时返回空字符串。
+func FormatTrace(frames []StackFrame) string {
+	if len(frames) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("调用栈(最近的调用在最后):")
+
+	repeated := 0
+	for i, frame := range frames {
+		if i > 0 && frame.Function == frames[i-1].Function && frame.File == frames[i-1].File && frame.Line == frames[i-1].Line {
+			repeated++
+		} else {
+			writeRepeated(&sb, repeated)
+			repeated = 0
+		}
+		if repeated < maxRepeatedFrames {
+			sb.WriteString("\n  ")
+			sb.WriteString(frame.String())
+		}
+	}
+	writeRepeated(&sb, repeated)
+
+	return sb.String()
+}
+
+// writeRepeated 写入被省略的重复栈帧数量
+func writeRepeated(sb *strings.Builder, repeated int) {
+	if repeated >= maxRepeatedFrames {
+		fmt.Fprintf(sb, "\n  [上一行又重复了%d次]", repeated-maxRepeatedFrames+1)
+	}
+}
@@ -0,0 +1,95 @@
+package lox
+
+import (
+	"strings"
+
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// ScanError 词法分析阶段的错误
+type ScanError struct {
+	Diagnostics []errorp.Diagnostic
+}
+
+// Error 实现error接口
+func (e *ScanError) Error() string {
+	return joinDiagnostics(e.Diagnostics)
+}
+
+// ParseError 语法分析阶段的错误
+type ParseError struct {
+	Diagnostics []errorp.Diagnostic
+}
+
+// Error 实现error接口
+func (e *ParseError) Error() string {
+	return joinDiagnostics(e.Diagnostics)
+}
+
+// ResolveError 静态检查阶段(变量解析、字节码编译)的错误
+type ResolveError struct {
+	Diagnostics []errorp.Diagnostic
+}
+
+// Error 实现error接口
+func (e *ResolveError) Error() string {
+	return joinDiagnostics(e.Diagnostics)
+}
+
+// RuntimeError 执行阶段的错误
+type RuntimeError struct {
+	Token   *token.Token // 出错位置的标记(可能为nil)
+	File    string       // 出错位置所在的文件，错误发生在被导入的模块中时不为空
+	Line    int          // 行号，未知时为0
+	Column  int          // 列号，未知时为0
+	Message string       // 错误信息
+
+	// Trace 出错时的Lox调用栈，最外层的<script>在前，最后一帧是出错的函数；错误发生在顶层代码时为空
+	Trace []errorp.StackFrame
+}
+
+// Error 实现error接口
+func (e *RuntimeError) Error() string {
+	return errorp.Diagnostic{Token: e.Token, File: e.File, Line: e.Line, Message: e.Message}.String()
+}
+
+// Traceback 返回多行的调用栈文本，没有调用栈时返回空字符串
+func (e *RuntimeError) Traceback() string {
+	return errorp.FormatTrace(e.Trace)
+}
+
+// joinDiagnostics 将多条诊断信息拼接为多行文本
+func joinDiagnostics(diagnostics []errorp.Diagnostic) string {
+	lines := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diagnosticsError 将诊断信息转换为对应阶段的错误，没有错误时返回nil
+// 后面阶段的错误只有在前面阶段没有错误时才会出现，因此按阶段顺序查找
+func diagnosticsError(diagnostics []errorp.Diagnostic) error {
+	byKind := make(map[errorp.Kind][]errorp.Diagnostic)
+	for _, d := range diagnostics {
+		if d.Severity == errorp.SeverityError {
+			byKind[d.Kind] = append(byKind[d.Kind], d)
+		}
+	}
+
+	if ds := byKind[errorp.KindScan]; len(ds) > 0 {
+		return &ScanError{Diagnostics: ds}
+	}
+	if ds := byKind[errorp.KindParse]; len(ds) > 0 {
+		return &ParseError{Diagnostics: ds}
+	}
+	if ds := byKind[errorp.KindResolve]; len(ds) > 0 {
+		return &ResolveError{Diagnostics: ds}
+	}
+	if ds := byKind[errorp.KindRuntime]; len(ds) > 0 {
+		d := ds[0]
+		return &RuntimeError{Token: d.Token, File: d.File, Line: d.Line, Column: d.Column, Message: d.Message, Trace: d.Trace}
+	}
+	return nil
+}
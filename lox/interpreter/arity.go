@@ -0,0 +1,137 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aixiasang/goLox/lox/ast"
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// ArityRange 函数接受的参数个数范围，Max为Variadic时没有上限
+type ArityRange struct {
+	Min int
+	Max int
+}
+
+// Exactly 返回只接受n个参数的范围
+func Exactly(n int) ArityRange {
+	return ArityRange{Min: n, Max: n}
+}
+
+// FunctionArity 返回函数声明接受的参数个数范围，有默认值的参数和剩余参数可以省略
+func FunctionArity(declaration *ast.Function) ArityRange {
+	max := declaration.MaxArity()
+	if max < 0 {
+		max = Variadic
+	}
+	return ArityRange{Min: declaration.MinArity(), Max: max}
+}
+
+// Accepts 判断是否可以传入count个参数
+func (a ArityRange) Accepts(count int) bool {
+	return count >= a.Min && (a.Max == Variadic || count <= a.Max)
+}
+
+// String 返回参数个数范围的描述，例如"2个"、"1到3个"、"至少1个"
+func (a ArityRange) String() string {
+	switch {
+	case a.Max == Variadic:
+		return fmt.Sprintf("至少%d个", a.Min)
+	case a.Min == a.Max:
+		return fmt.Sprintf("%d个", a.Min)
+	default:
+		return fmt.Sprintf("%d到%d个", a.Min, a.Max)
+	}
+}
+
+// CheckArity 参数个数不在范围内时抛出位于调用位置paren的运行时错误
+func CheckArity(arity ArityRange, paren *token.Token, count int) {
+	if !arity.Accepts(count) {
+		message := fmt.Sprintf("期望%s参数，但得到%d个。", arity, count)
+		panic(errorp.RuntimeError{Token: paren, Message: message})
+	}
+}
+
+// RestArguments 将从第from个开始的参数收集为剩余参数的列表，参数不足时为空列表
+func RestArguments(arguments []Value, from int) *List {
+	var elements []Value
+	if len(arguments) > from {
+		elements = append(elements, arguments[from:]...)
+	}
+	return NewList(elements)
+}
+
+// Parameters 调用时可以按名称传入的参数
+type Parameters struct {
+	Names    []string // 剩余参数之外的参数名，按声明的顺序排列
+	Required int      // 必须传入的参数个数，即开头没有默认值的参数的个数
+}
+
+// FunctionParameters 返回函数声明中可以按名称传入的参数
+func FunctionParameters(declaration *ast.Function) Parameters {
+	names := make([]string, 0, len(declaration.Params))
+	for i, param := range declaration.Params {
+		if !declaration.IsRest(i) {
+			names = append(names, param.Lexeme)
+		}
+	}
+	return Parameters{Names: names, Required: declaration.MinArity()}
+}
+
+// NamedParameters 可以按名称传入参数的函数和类
+type NamedParameters interface {
+	// Parameters 返回可以按名称传入的参数，类返回初始化方法的参数
+	Parameters() Parameters
+}
+
+// missingArgument 没有传入的参数的占位值的类型
+type missingArgument struct{}
+
+// Missing 按名称传参时跳过的参数的占位值，被调用的函数为它计算参数的默认值
+var Missing Value = missingArgument{}
+
+// ArrangeArguments 将末尾的命名参数按参数名放到对应的位置，返回按参数顺序排列的参数
+// 跳过的有默认值的参数以Missing占位；参数名不存在、参数重复传入或缺少必须的参数时抛出运行时错误
+func ArrangeArguments(callee Value, paren *token.Token, arguments []Value, names []*token.Token) []Value {
+	function, ok := callee.(NamedParameters)
+	if !ok {
+		if _, ok := callee.(Native); ok {
+			panic(errorp.RuntimeError{Token: paren, Message: "内置函数不接受命名参数。"})
+		}
+		// 不能调用的值由调用本身报告错误
+		return arguments
+	}
+	parameters := function.Parameters()
+
+	positional := len(arguments) - len(names)
+	arranged := make([]Value, max(positional, len(parameters.Names)))
+	copy(arranged, arguments[:positional])
+	for i := positional; i < len(arranged); i++ {
+		arranged[i] = Missing
+	}
+
+	for i, name := range names {
+		index := slices.Index(parameters.Names, name.Lexeme)
+		if index < 0 {
+			panic(errorp.RuntimeError{Token: name, Message: fmt.Sprintf("没有名为 '%s' 的参数。", name.Lexeme)})
+		}
+		if arranged[index] != Missing {
+			panic(errorp.RuntimeError{Token: name, Message: fmt.Sprintf("参数 '%s' 已经按位置传入。", name.Lexeme)})
+		}
+		arranged[index] = arguments[positional+i]
+	}
+
+	for i := 0; i < parameters.Required; i++ {
+		if arranged[i] == Missing {
+			panic(errorp.RuntimeError{Token: paren, Message: fmt.Sprintf("缺少参数 '%s'。", parameters.Names[i])})
+		}
+	}
+
+	// 末尾跳过的参数不需要占位，与只传入位置参数时一样由参数个数判断
+	for len(arranged) > 0 && arranged[len(arranged)-1] == Missing {
+		arranged = arranged[:len(arranged)-1]
+	}
+	return arranged
+}
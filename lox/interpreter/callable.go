@@ -3,83 +3,99 @@ package interpreter
 import (
 	"github.com/aixiasang/goLox/lox/ast"
 	"github.com/aixiasang/goLox/lox/environment"
+	"github.com/aixiasang/goLox/lox/error"
 )
 
 // Callable 表示可调用对象的接口
 type Callable interface {
 	// Call 调用函数
 	Call(interpreter *Interpreter, arguments []interface{}) interface{}
-	// Arity 返回函数需要的参数数量
-	Arity() int
+	// Arity 返回函数接受的参数个数范围
+	Arity() ArityRange
 	// String 返回函数的字符串表示
 	String() string
 }
 
 // Function Lox语言中的函数对象
 type Function struct {
-	declaration *ast.Function            // 函数声明
-	closure     *environment.Environment // 闭包环境
+	declaration   *ast.Function            // 函数声明
+	closure       *environment.Environment // 闭包环境
+	globals       *environment.Environment // 定义函数的模块的全局环境
+	isInitializer bool                     // 是否为类的init方法
 }
 
 // NewFunction 创建一个新的函数对象
-func NewFunction(declaration *ast.Function, closure *environment.Environment) *Function {
+func NewFunction(declaration *ast.Function, closure, globals *environment.Environment, isInitializer bool) *Function {
 	return &Function{
-		declaration: declaration,
-		closure:     closure,
+		declaration:   declaration,
+		closure:       closure,
+		globals:       globals,
+		isInitializer: isInitializer,
 	}
 }
 
+// Bind 将方法绑定到实例，返回一个this指向该实例的新函数
+func (f *Function) Bind(instance *Instance) *Function {
+	env := environment.NewEnclosedEnvironment(f.closure)
+	env.Define("this", instance)
+	return NewFunction(f.declaration, env, f.globals, f.isInitializer)
+}
+
 // Call 实现Callable接口，调用函数
+// 函数体以尾调用结束时，在同一个循环中继续执行被调用的函数，尾递归因此只占用固定的Go调用栈
+// 函数中的全局变量属于定义它的模块，执行期间切换到该模块的全局环境
 func (f *Function) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	// 创建函数本地环境，包含参数
-	env := environment.NewEnclosedEnvironment(f.closure)
+	previous := interpreter.globals
+	defer func() {
+		interpreter.globals = previous
+	}()
 
-	// 绑定参数
-	for i, param := range f.declaration.Params {
-		env.Define(param.Lexeme, arguments[i])
-	}
+	function := f
+	for {
+		interpreter.globals = function.globals
 
-	// 默认返回值为nil
-	var result interface{} = nil
+		// 创建函数本地环境，包含参数
+		env := environment.NewEnclosedEnvironment(function.closure)
+		interpreter.bindParameters(function.declaration, env, arguments)
 
-	// 执行函数体并处理return语句（不使用defer）
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if returnValue, ok := r.(ReturnValue); ok {
-					// 如果是return语句，保存返回值
-					result = returnValue.Value
-				} else {
-					// 其他异常继续传递
-					panic(r)
-				}
+		// 执行函数体，return语句的返回值随执行结果一起返回
+		completion := interpreter.executeBlock(function.declaration.Body, env)
+		if completion != nil {
+			if completion.IsTailCall() {
+				function = completion.Value.(*Function)
+				arguments = completion.Arguments
+				interpreter.callStack.ReplaceTop(function)
+				continue
 			}
-		}()
+			if message := completion.OutsideLoopMessage(); message != "" {
+				panic(error.RuntimeError{Token: function.declaration.Name, Message: message})
+			}
+		}
 
-		// 执行函数体
-		interpreter.executeBlock(f.declaration.Body, env)
-	}()
+		// 初始化方法总是返回实例本身
+		if function.isInitializer {
+			return function.closure.GetAt(0, "this")
+		}
 
-	// 返回函数结果，如果没有显式返回则为nil
-	return result
+		// 返回函数结果，如果没有显式返回则为nil
+		if completion == nil {
+			return nil
+		}
+		return completion.Value
+	}
+}
+
+// Arity 返回函数接受的参数个数范围
+func (f *Function) Arity() ArityRange {
+	return FunctionArity(f.declaration)
 }
 
-// Arity 返回函数参数数量
-func (f *Function) Arity() int {
-	return len(f.declaration.Params)
+// Parameters 返回可以按名称传入的参数
+func (f *Function) Parameters() Parameters {
+	return FunctionParameters(f.declaration)
 }
 
 // String 返回函数的字符串表示
 func (f *Function) String() string {
 	return "<fn " + f.declaration.Name.Lexeme + ">"
 }
-
-// ReturnValue 用于实现return语句的控制流
-type ReturnValue struct {
-	Value interface{}
-}
-
-// Error 实现error接口
-func (r ReturnValue) Error() string {
-	return "return"
-}
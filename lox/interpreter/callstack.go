@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// DefaultMaxCallDepth 默认的最大调用深度
+// 树遍历后端每层Lox调用会占用若干层Go调用栈，这个上限远低于Go默认的栈大小限制
+const DefaultMaxCallDepth = 10000
+
+// CheckCallDepth depth超过上限时抛出栈溢出的运行时错误，
+// 避免无限递归耗尽Go调用栈使整个宿主进程崩溃。limit小于等于0时使用DefaultMaxCallDepth
+func CheckCallDepth(depth, limit int, tok *token.Token) {
+	if limit <= 0 {
+		limit = DefaultMaxCallDepth
+	}
+	if depth > limit {
+		panic(error.RuntimeError{
+			Token:   tok,
+			Message: fmt.Sprintf("栈溢出(stack overflow)：调用深度超过%d。", limit),
+		})
+	}
+}
+
+// CallFrame 树遍历解释器调用栈中的一帧
+type CallFrame struct {
+	Callee   fmt.Stringer // 被调用的函数，生成调用栈时才转换为名称
+	CallSite *token.Token // 调用处的右括号
+}
+
+// CallStack 树遍历解释器的调用栈，用于限制调用深度和生成运行时错误的调用栈
+// 调用正常返回时弹出栈帧；运行时错误的panic穿过时栈帧保持不变，
+// 由捕获错误的一方生成调用栈后再截断。零值是可用的空调用栈
+type CallStack struct {
+	limit  int
+	frames []CallFrame
+}
+
+// SetLimit 设置最大调用深度，limit小于等于0时使用DefaultMaxCallDepth
+func (s *CallStack) SetLimit(limit int) {
+	s.limit = limit
+}
+
+// Push 压入一帧，超过最大调用深度时抛出运行时错误
+func (s *CallStack) Push(callee fmt.Stringer, callSite *token.Token) {
+	CheckCallDepth(len(s.frames)+1, s.limit, callSite)
+	s.frames = append(s.frames, CallFrame{Callee: callee, CallSite: callSite})
+}
+
+// Pop 弹出最内层的一帧
+func (s *CallStack) Pop() {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// ReplaceTop 尾调用时用新的被调用者替换最内层的一帧
+func (s *CallStack) ReplaceTop(callee fmt.Stringer) {
+	s.frames[len(s.frames)-1].Callee = callee
+}
+
+// Depth 返回当前的调用深度
+func (s *CallStack) Depth() int {
+	return len(s.frames)
+}
+
+// Truncate 丢弃深度depth以内的栈帧，用于错误被捕获之后
+func (s *CallStack) Truncate(depth int) {
+	s.frames = s.frames[:depth]
+}
+
+// Trace 生成错误发生在at处时的调用栈，最外层在前
+// 每一帧的位置是它调用下一帧的位置，最内层帧的位置是at
+func (s *CallStack) Trace(at *token.Token) []error.StackFrame {
+	if len(s.frames) == 0 {
+		return nil
+	}
+
+	trace := make([]error.StackFrame, 0, len(s.frames)+1)
+	function := "<script>"
+	for _, frame := range s.frames {
+		trace = append(trace, error.NewStackFrame(function, frame.CallSite))
+		function = frame.Callee.String()
+	}
+	return append(trace, error.NewStackFrame(function, at))
+}
@@ -0,0 +1,38 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Cancellation 在循环和函数调用处检查执行是否已被取消
+// 零值表示永不取消
+type Cancellation struct {
+	ctx  context.Context
+	done <-chan struct{}
+}
+
+// Set 设置用于取消执行的上下文，传入nil表示永不取消
+func (c *Cancellation) Set(ctx context.Context) {
+	c.ctx = ctx
+	c.done = nil
+	if ctx != nil {
+		c.done = ctx.Done()
+	}
+}
+
+// Check 上下文已取消时抛出运行时错误，这个错误不能被try语句捕获
+func (c *Cancellation) Check(tok *token.Token) {
+	if c.done == nil {
+		return
+	}
+
+	select {
+	case <-c.done:
+		panic(error.RuntimeError{Token: tok, Message: fmt.Sprintf("执行已取消: %v", c.ctx.Err()), Fatal: true})
+	default:
+	}
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Class Lox语言中的类对象
+type Class struct {
+	name       string               // 类名
+	superclass *Class               // 父类(可能为nil)
+	methods    map[string]*Function // 方法表
+}
+
+// NewClass 创建一个新的类对象
+func NewClass(name string, superclass *Class, methods map[string]*Function) *Class {
+	return &Class{
+		name:       name,
+		superclass: superclass,
+		methods:    methods,
+	}
+}
+
+// FindMethod 查找方法，找不到时沿继承链向上查找
+func (c *Class) FindMethod(name string) *Function {
+	if method, ok := c.methods[name]; ok {
+		return method
+	}
+
+	if c.superclass != nil {
+		return c.superclass.FindMethod(name)
+	}
+
+	return nil
+}
+
+// Call 实现Callable接口，创建实例并调用初始化方法
+func (c *Class) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	instance := NewInstance(c)
+
+	if initializer := c.FindMethod("init"); initializer != nil {
+		initializer.Bind(instance).Call(interpreter, arguments)
+	}
+
+	return instance
+}
+
+// Arity 返回初始化方法接受的参数个数范围
+func (c *Class) Arity() ArityRange {
+	if initializer := c.FindMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return Exactly(0)
+}
+
+// Parameters 返回初始化方法中可以按名称传入的参数，没有初始化方法时没有参数
+func (c *Class) Parameters() Parameters {
+	if initializer := c.FindMethod("init"); initializer != nil {
+		return initializer.Parameters()
+	}
+	return Parameters{}
+}
+
+// String 返回类的字符串表示
+func (c *Class) String() string {
+	return c.name
+}
+
+// Instance Lox语言中的类实例
+type Instance struct {
+	class  *Class                 // 所属的类
+	fields map[string]interface{} // 字段表
+}
+
+// NewInstance 创建一个新的类实例
+func NewInstance(class *Class) *Instance {
+	return &Instance{
+		class:  class,
+		fields: make(map[string]interface{}),
+	}
+}
+
+// Get 获取属性，字段优先于方法
+func (i *Instance) Get(name *token.Token) interface{} {
+	if value, ok := i.fields[name.Lexeme]; ok {
+		return value
+	}
+
+	if method := i.class.FindMethod(name.Lexeme); method != nil {
+		return method.Bind(i)
+	}
+
+	panic(error.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
+
+// Set 设置字段
+func (i *Instance) Set(name *token.Token, value interface{}) {
+	i.fields[name.Lexeme] = value
+}
+
+// String 返回实例的字符串表示
+func (i *Instance) String() string {
+	return i.class.name + " instance"
+}
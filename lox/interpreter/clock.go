@@ -7,9 +7,9 @@ import (
 // Clock 是一个内置函数，返回自程序启动以来的秒数
 type Clock struct{}
 
-// Call 实现Callable接口，返回当前时间的秒数
-func (c *Clock) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	return float64(time.Now().Unix())
+// CallNative 实现Native接口，返回当前时间的秒数
+func (c *Clock) CallNative(arguments []Value) (Value, error) {
+	return float64(time.Now().Unix()), nil
 }
 
 // Arity 返回函数参数数量
@@ -0,0 +1,56 @@
+package interpreter
+
+// CompletionKind 语句执行结束的方式
+type CompletionKind int
+
+const (
+	CompletionNormal   CompletionKind = iota // 正常执行完毕
+	CompletionBreak                          // 遇到break语句，跳出最内层循环
+	CompletionContinue                       // 遇到continue语句，结束本次循环
+	CompletionReturn                         // 遇到return语句，结束当前函数
+	CompletionTailCall                       // return语句的返回值是尾调用，由外层函数调用负责执行
+)
+
+// Completion 语句的执行结果
+// 语句执行后把结果逐层返回给外层语句，由循环和函数调用处理break、continue和return，
+// 而不是借助panic和recover。正常执行完毕的语句返回nil，避免为每条语句分配对象。
+type Completion struct {
+	Kind      CompletionKind
+	Value     interface{}   // return语句的返回值，尾调用时为被调用的函数
+	Arguments []interface{} // 尾调用的参数
+}
+
+var (
+	// BreakCompletion break语句的执行结果，所有break共享同一个对象
+	BreakCompletion = &Completion{Kind: CompletionBreak}
+	// ContinueCompletion continue语句的执行结果，所有continue共享同一个对象
+	ContinueCompletion = &Completion{Kind: CompletionContinue}
+)
+
+// NewReturnCompletion 创建return语句的执行结果
+func NewReturnCompletion(value interface{}) *Completion {
+	return &Completion{Kind: CompletionReturn, Value: value}
+}
+
+// NewTailCallCompletion 创建尾调用的执行结果
+// 函数体不直接调用callee，而是把callee和参数交给正在执行的函数调用，
+// 由它在同一层Go调用栈上继续执行，因此尾递归不会加深调用栈
+func NewTailCallCompletion(callee interface{}, arguments []interface{}) *Completion {
+	return &Completion{Kind: CompletionTailCall, Value: callee, Arguments: arguments}
+}
+
+// IsTailCall 判断执行结果是否为尾调用
+func (c *Completion) IsTailCall() bool {
+	return c.Kind == CompletionTailCall
+}
+
+// OutsideLoopMessage 返回break或continue逃出循环时的错误信息，其他情况返回空字符串
+func (c *Completion) OutsideLoopMessage() string {
+	switch c.Kind {
+	case CompletionBreak:
+		return "Break语句只能在循环内部使用。"
+	case CompletionContinue:
+		return "Continue语句只能在循环内部使用。"
+	}
+	return ""
+}
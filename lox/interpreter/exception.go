@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// ErrorValue 被catch子句捕获的运行时错误，在Lox中通过message和line属性访问
+type ErrorValue struct {
+	Message string             // 错误信息
+	Line    int                // 出错位置的行号，未知时为0
+	err     error.RuntimeError // 原始错误，重新抛出时保留位置和调用栈
+}
+
+// String 返回错误信息
+func (e *ErrorValue) String() string {
+	return e.Message
+}
+
+// Get 返回错误值的属性
+func (e *ErrorValue) Get(name *token.Token) Value {
+	switch name.Lexeme {
+	case "message":
+		return e.Message
+	case "line":
+		return int64(e.Line)
+	}
+
+	panic(error.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
+
+// NewThrow 创建throw语句抛出的运行时错误
+// 重新抛出catch子句捕获的错误值时，返回原始错误
+func NewThrow(keyword *token.Token, value Value) error.RuntimeError {
+	if e, ok := value.(*ErrorValue); ok {
+		return e.err
+	}
+	return error.RuntimeError{
+		Token:   keyword,
+		Message: "未捕获的异常: " + Stringify(value),
+		Value:   value,
+		Thrown:  true,
+	}
+}
+
+// CaughtValue 返回catch子句绑定的值
+// throw语句抛出的值原样返回，解释器产生的运行时错误包装为ErrorValue
+func CaughtValue(err error.RuntimeError) Value {
+	if err.Thrown {
+		return err.Value
+	}
+
+	line := 0
+	if err.Token != nil {
+		line = err.Token.Line
+	}
+	return &ErrorValue{Message: err.Message, Line: line, err: err}
+}
@@ -1,8 +1,11 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/aixiasang/goLox/lox/ast"
 	"github.com/aixiasang/goLox/lox/environment"
@@ -10,53 +13,122 @@ import (
 	"github.com/aixiasang/goLox/lox/token"
 )
 
-// BreakException 表示遇到break语句的异常
-type BreakException struct{}
-
 // Interpreter 实现表达式求值和语句执行
 type Interpreter struct {
 	errorReporter error.Reporter
 	environment   *environment.Environment
 	locals        map[ast.Expr]int         // 变量的作用域深度信息
-	globals       *environment.Environment // 全局环境
+	globals       *environment.Environment // 当前执行的代码所属模块的全局环境
+	builtins      *environment.Environment // 内置函数，是所有模块全局环境的外层环境
+	importer      Importer                 // 加载import语句导入的模块
+	cancellation  Cancellation             // 执行取消检查
+	callStack     CallStack                // 调用栈
+	stdout        io.Writer                // print语句的输出目标
 }
 
 // NewInterpreter 创建一个新的解释器
 func NewInterpreter(errorReporter error.Reporter) *Interpreter {
-	globals := environment.NewEnvironment()
-
-	// 添加内置函数
-	globals.Define("clock", &Clock{})
+	// 添加内置函数，主脚本和每个模块的全局环境都以它为外层
+	builtins := environment.NewEnvironment()
+	DefineNatives(builtins)
+	globals := environment.NewEnclosedEnvironment(builtins)
 
 	interpreter := &Interpreter{
 		errorReporter: errorReporter,
 		environment:   globals,
 		globals:       globals,
+		builtins:      builtins,
 		locals:        make(map[ast.Expr]int),
+		stdout:        os.Stdout,
 	}
 
 	return interpreter
 }
 
+// SetOutput 设置print语句的输出目标
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.stdout = w
+}
+
+// SetMaxCallDepth 设置最大调用深度，limit小于等于0时使用DefaultMaxCallDepth
+func (i *Interpreter) SetMaxCallDepth(limit int) {
+	i.callStack.SetLimit(limit)
+}
+
+// DefineNative 注册一个由Go实现的内置函数，主脚本和所有模块都可以调用
+// arity为Variadic时接受任意数量的参数，fn返回的错误会成为调用位置的运行时错误
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFunc) {
+	i.builtins.Define(name, NewNativeFunction(name, arity, fn))
+}
+
+// SetImporter 设置加载import语句导入的模块的函数
+func (i *Interpreter) SetImporter(importer Importer) {
+	i.importer = importer
+}
+
+// ExecuteModule 在新的全局环境中执行模块的顶层语句，返回执行完毕的模块
+// at为导入模块的位置，模块执行期间作为调用栈中的一帧
+func (i *Interpreter) ExecuteModule(name string, statements []ast.Stmt, at *token.Token) *Module {
+	module := NewModule(name, environment.NewEnclosedEnvironment(i.builtins), ast.ExportedNames(statements))
+
+	previousEnvironment, previousGlobals := i.environment, i.globals
+	defer func() {
+		i.environment, i.globals = previousEnvironment, previousGlobals
+	}()
+	i.environment, i.globals = module.Globals(), module.Globals()
+
+	i.callStack.Push(module, at)
+	for _, stmt := range statements {
+		if completion := i.execute(stmt); completion != nil {
+			if message := completion.OutsideLoopMessage(); message != "" {
+				panic(error.RuntimeError{Message: message})
+			}
+			break
+		}
+	}
+	i.callStack.Pop()
+	return module
+}
+
 // Interpret 解释执行语句列表
-func (i *Interpreter) Interpret(statements []ast.Stmt) {
+// 如果最后一条语句是表达式语句，返回其值
+func (i *Interpreter) Interpret(statements []ast.Stmt) (result interface{}) {
 	defer i.handlePanic()
 
-	for _, stmt := range statements {
-		i.execute(stmt)
+	for index, stmt := range statements {
+		if expr, ok := stmt.(*ast.Expression); ok && index == len(statements)-1 {
+			return i.evaluate(expr.Expr)
+		}
+		if completion := i.execute(stmt); completion != nil {
+			// break或continue超出循环范围，return已经由静态检查排除
+			if message := completion.OutsideLoopMessage(); message != "" {
+				i.errorReporter.ReportRuntimeError(error.RuntimeError{Message: message})
+			}
+			return nil
+		}
 	}
+	return nil
+}
+
+// SetContext 设置用于取消执行的上下文
+func (i *Interpreter) SetContext(ctx context.Context) {
+	i.cancellation.Set(ctx)
 }
 
-// execute 执行一条语句
-func (i *Interpreter) execute(stmt ast.Stmt) {
-	stmt.Accept(i)
+// execute 执行一条语句，返回其执行结果，正常执行完毕时为nil
+func (i *Interpreter) execute(stmt ast.Stmt) *Completion {
+	if completion, ok := stmt.Accept(i).(*Completion); ok {
+		return completion
+	}
+	return nil
 }
 
 // executeBlock 在给定环境中执行语句块
-func (i *Interpreter) executeBlock(statements []ast.Stmt, env *environment.Environment) {
+// 遇到break、continue或return时立即停止，并把执行结果交给外层处理
+func (i *Interpreter) executeBlock(statements []ast.Stmt, env *environment.Environment) *Completion {
 	previous := i.environment
 
-	// 恢复原来的环境，即使panic也要确保恢复
+	// 恢复原来的环境，运行时错误的panic穿过时也要确保恢复
 	defer func() {
 		i.environment = previous
 	}()
@@ -64,14 +136,16 @@ func (i *Interpreter) executeBlock(statements []ast.Stmt, env *environment.Envir
 	i.environment = env
 
 	for _, statement := range statements {
-		i.execute(statement)
+		if completion := i.execute(statement); completion != nil {
+			return completion
+		}
 	}
+	return nil
 }
 
 // VisitBlockStmt 处理代码块语句
 func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) interface{} {
-	i.executeBlock(stmt.Statements, environment.NewEnclosedEnvironment(i.environment))
-	return nil
+	return i.executeBlock(stmt.Statements, environment.NewEnclosedEnvironment(i.environment))
 }
 
 // VisitExpressionStmt 处理表达式语句
@@ -83,9 +157,9 @@ func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) interface{} {
 // VisitIfStmt 处理条件语句
 func (i *Interpreter) VisitIfStmt(stmt *ast.If) interface{} {
 	if i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.ThenBranch)
+		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		i.execute(stmt.ElseBranch)
+		return i.execute(stmt.ElseBranch)
 	}
 	return nil
 }
@@ -93,7 +167,7 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.If) interface{} {
 // VisitPrintStmt 处理打印语句
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) interface{} {
 	value := i.evaluate(stmt.Expr)
-	fmt.Println(i.stringify(value))
+	fmt.Fprintln(i.stdout, i.stringify(value))
 	return nil
 }
 
@@ -111,61 +185,162 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.Var) interface{} {
 
 // VisitWhileStmt 处理循环语句
 func (i *Interpreter) VisitWhileStmt(stmt *ast.While) interface{} {
-	defer func() {
-		// 捕获break异常，但不做处理，仅用于跳出循环
-		if r := recover(); r != nil {
-			// 如果是BreakException，就直接吞掉
-			if _, ok := r.(BreakException); !ok {
-				// 其他类型的异常继续向上抛
-				panic(r)
+	for i.isTruthy(i.evaluate(stmt.Condition)) {
+		if completion := i.execute(stmt.Body); completion != nil {
+			if completion.Kind == CompletionBreak {
+				break
+			}
+			// return和尾调用交给外层的函数调用处理，continue继续执行更新表达式
+			if completion.Kind != CompletionContinue {
+				return completion
 			}
 		}
-	}()
-
-	for i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.Body)
+		if stmt.Increment != nil {
+			i.evaluate(stmt.Increment)
+		}
+		i.cancellation.Check(nil)
 	}
 	return nil
 }
 
 // VisitBreakStmt 处理break语句
 func (i *Interpreter) VisitBreakStmt(stmt *ast.Break) interface{} {
-	// 通过抛出异常来跳出循环
-	panic(BreakException{})
+	return BreakCompletion
+}
+
+// VisitContinueStmt 处理continue语句
+func (i *Interpreter) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	return ContinueCompletion
 }
 
 // VisitFunctionStmt 处理函数声明语句
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) interface{} {
-	function := NewFunction(stmt, i.environment)
+	function := NewFunction(stmt, i.environment, i.globals, false)
 	i.environment.Define(stmt.Name.Lexeme, function)
 	return nil
 }
 
+// VisitClassStmt 处理类声明语句
+func (i *Interpreter) VisitClassStmt(stmt *ast.Class) interface{} {
+	var superclass *Class
+	if stmt.Superclass != nil {
+		class, ok := i.evaluate(stmt.Superclass).(*Class)
+		if !ok {
+			panic(error.RuntimeError{Token: stmt.Superclass.Name, Message: "父类必须是一个类。"})
+		}
+		superclass = class
+	}
+
+	i.environment.Define(stmt.Name.Lexeme, nil)
+
+	// 有父类时，方法的闭包外层额外绑定super
+	closure := i.environment
+	if superclass != nil {
+		closure = environment.NewEnclosedEnvironment(i.environment)
+		closure.Define("super", superclass)
+	}
+
+	methods := make(map[string]*Function)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = NewFunction(method, closure, i.globals, method.Name.Lexeme == "init")
+	}
+
+	class := NewClass(stmt.Name.Lexeme, superclass, methods)
+	i.environment.Assign(stmt.Name, class)
+	return nil
+}
+
 // VisitReturnStmt 处理return语句
 func (i *Interpreter) VisitReturnStmt(stmt *ast.Return) interface{} {
+	// 尾调用Lox函数时不在这里调用，而是交给外层的Function.Call继续执行
+	if stmt.TailCall {
+		call := stmt.Value.(*ast.Call)
+		callee, arguments := i.evaluateCall(call)
+		if function, ok := callee.(*Function); ok {
+			CheckArity(function.Arity(), call.Paren, len(arguments))
+			return NewTailCallCompletion(function, arguments)
+		}
+		return NewReturnCompletion(i.call(call.Paren, callee, arguments))
+	}
+
 	var value interface{} = nil
 	if stmt.Value != nil {
 		value = i.evaluate(stmt.Value)
 	}
 
-	// 通过抛出ReturnValue异常来实现return语句
-	panic(ReturnValue{Value: value})
+	return NewReturnCompletion(value)
+}
+
+// VisitThrowStmt 处理throw语句
+func (i *Interpreter) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	panic(NewThrow(stmt.Keyword, i.evaluate(stmt.Value)))
+}
+
+// VisitTryStmt 处理try语句
+// finally块总会执行；它以break、continue或return结束时，会取代try块和catch块的结果以及未捕获的异常
+func (i *Interpreter) VisitTryStmt(stmt *ast.Try) interface{} {
+	completion, thrown := i.executeProtected(stmt.Body.Statements, environment.NewEnclosedEnvironment(i.environment))
+	if thrown != nil && stmt.Catch != nil {
+		env := environment.NewEnclosedEnvironment(i.environment)
+		env.Define(stmt.CatchName.Lexeme, CaughtValue(*thrown))
+		completion, thrown = i.executeProtected(stmt.Catch.Statements, env)
+	}
+
+	if stmt.Finally != nil {
+		if finally := i.execute(stmt.Finally); finally != nil {
+			return finally
+		}
+	}
+
+	if thrown != nil {
+		panic(*thrown)
+	}
+	return completion
+}
+
+// executeProtected 执行语句块，并捕获其中抛出的运行时错误
+// 捕获时记录调用栈，然后丢弃错误发生处到当前位置之间的栈帧
+func (i *Interpreter) executeProtected(statements []ast.Stmt, env *environment.Environment) (completion *Completion, thrown *error.RuntimeError) {
+	depth := i.callStack.Depth()
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeError, ok := r.(error.RuntimeError)
+			if !ok || runtimeError.Fatal {
+				panic(r)
+			}
+			if runtimeError.Trace == nil {
+				runtimeError.Trace = i.callStack.Trace(runtimeError.Token)
+			}
+			i.callStack.Truncate(depth)
+			thrown = &runtimeError
+		}
+	}()
+
+	return i.executeBlock(statements, env), nil
+}
+
+// VisitImportStmt 处理import语句，加载模块并绑定到变量
+func (i *Interpreter) VisitImportStmt(stmt *ast.Import) interface{} {
+	module := ImportModule(i.importer, stmt.Path)
+	i.environment.Define(stmt.Name.Lexeme, module)
+	return nil
+}
+
+// VisitExportStmt 处理export语句，被导出的声明照常执行
+func (i *Interpreter) VisitExportStmt(stmt *ast.Export) interface{} {
+	return i.execute(stmt.Declaration)
 }
 
 // handlePanic 处理解释过程中的异常
 func (i *Interpreter) handlePanic() {
 	if r := recover(); r != nil {
 		if runtimeError, ok := r.(error.RuntimeError); ok {
-			// 报告运行时错误
-			i.errorReporter.Error(runtimeError.Token, 0, runtimeError.Message)
-		} else if _, ok := r.(BreakException); ok {
-			// break语句超出循环范围
-			// 这里不应该发生，因为解析器应该检查break是否在循环内
-			i.errorReporter.ReportError(0, "Break语句只能在循环内部使用。")
-		} else if _, ok := r.(ReturnValue); ok {
-			// 返回值流动到最顶层
-			// 这里可以选择将值作为REPL的结果返回
-			return
+			// 栈帧在panic穿过时保留了下来，报告运行时错误时附带调用栈
+			if runtimeError.Trace == nil {
+				runtimeError.Trace = i.callStack.Trace(runtimeError.Token)
+			}
+			i.callStack.Truncate(0)
+			i.errorReporter.ReportRuntimeError(runtimeError)
 		} else {
 			// 重新抛出其他异常
 			panic(r)
@@ -204,77 +379,14 @@ func (i *Interpreter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
 // VisitUnaryExpr 处理一元表达式
 func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) interface{} {
 	right := i.evaluate(expr.Right)
-
-	switch expr.Operator.Type {
-	case token.MINUS:
-		i.checkNumberOperand(expr.Operator, right)
-		return -i.asNumber(right)
-	case token.BANG:
-		return !i.isTruthy(right)
-	}
-
-	// 不可达
-	return nil
+	return UnaryOp(expr.Operator, right)
 }
 
 // VisitBinaryExpr 处理二元表达式
 func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
 	left := i.evaluate(expr.Left)
 	right := i.evaluate(expr.Right)
-
-	switch expr.Operator.Type {
-	case token.MINUS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) - i.asNumber(right)
-	case token.SLASH:
-		i.checkNumberOperands(expr.Operator, left, right)
-		rightNum := i.asNumber(right)
-		if rightNum == 0 {
-			panic(error.RuntimeError{Token: expr.Operator, Message: "除数不能为零。"})
-		}
-		return i.asNumber(left) / rightNum
-	case token.STAR:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) * i.asNumber(right)
-	case token.MODULO:
-		i.checkNumberOperands(expr.Operator, left, right)
-		rightNum := i.asNumber(right)
-		if rightNum == 0 {
-			panic(error.RuntimeError{Token: expr.Operator, Message: "取模运算符的右操作数不能为零。"})
-		}
-		return float64(int(i.asNumber(left)) % int(i.asNumber(right)))
-	case token.PLUS:
-		if i.isNumber(left) && i.isNumber(right) {
-			return i.asNumber(left) + i.asNumber(right)
-		}
-		// 如果任一操作数是字符串，则将另一个操作数也转换为字符串
-		if i.isString(left) || i.isString(right) {
-			return i.stringify(left) + i.stringify(right)
-		}
-		panic(error.RuntimeError{Token: expr.Operator, Message: "'+'运算符只能用于数字或字符串。"})
-	case token.GREATER:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) > i.asNumber(right)
-	case token.GREATER_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) >= i.asNumber(right)
-	case token.LESS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) < i.asNumber(right)
-	case token.LESS_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) <= i.asNumber(right)
-	case token.BANG_EQUAL:
-		return !i.isEqual(left, right)
-	case token.EQUAL_EQUAL:
-		return i.isEqual(left, right)
-	case token.COMMA:
-		// 逗号表达式，返回右侧值
-		return right
-	}
-
-	// 不可达
-	return nil
+	return BinaryOp(expr.Operator, left, right)
 }
 
 // VisitTernaryExpr 处理三元表达式
@@ -314,6 +426,14 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) interface{} {
 
 // VisitCallExpr 处理函数调用表达式
 func (i *Interpreter) VisitCallExpr(expr *ast.Call) interface{} {
+	callee, arguments := i.evaluateCall(expr)
+	return i.call(expr.Paren, callee, arguments)
+}
+
+// evaluateCall 求值调用表达式的被调用者和参数
+func (i *Interpreter) evaluateCall(expr *ast.Call) (interface{}, []interface{}) {
+	i.cancellation.Check(expr.Paren)
+
 	callee := i.evaluate(expr.Callee)
 
 	// 收集参数
@@ -321,130 +441,189 @@ func (i *Interpreter) VisitCallExpr(expr *ast.Call) interface{} {
 	for _, argument := range expr.Arguments {
 		arguments = append(arguments, i.evaluate(argument))
 	}
+	if len(expr.Names) > 0 {
+		arguments = ArrangeArguments(callee, expr.Paren, arguments, expr.Names)
+	}
+	return callee, arguments
+}
+
+// call 以给定参数调用callee
+func (i *Interpreter) call(paren *token.Token, callee interface{}, arguments []interface{}) interface{} {
+	// 内置函数由共享的逻辑检查参数并转换错误
+	if native, ok := callee.(Native); ok {
+		return CallNative(native, paren, arguments)
+	}
 
 	// 检查callee是否可调用
 	function, ok := callee.(Callable)
 	if !ok {
-		panic(error.RuntimeError{Token: expr.Paren, Message: "只能调用函数和类。"})
+		panic(error.RuntimeError{Token: paren, Message: "只能调用函数和类。"})
 	}
 
-	// 检查参数数量是否正确
-	if len(arguments) != function.Arity() {
-		message := fmt.Sprintf("期望%d个参数，但得到%d个。", function.Arity(), len(arguments))
-		panic(error.RuntimeError{Token: expr.Paren, Message: message})
-	}
+	// 调用函数，尾调用在Function.Call内部循环执行，不增加调用深度
+	CheckArity(function.Arity(), paren, len(arguments))
+	i.callStack.Push(frameCallee(function), paren)
+	result := function.Call(i, arguments)
+	i.callStack.Pop()
+	return result
+}
 
-	// 调用函数
-	return function.Call(i, arguments)
+// frameCallee 返回调用栈中代表callee的函数，调用类时实际执行的是它的初始化方法
+func frameCallee(callee Callable) Callable {
+	if class, ok := callee.(*Class); ok {
+		if initializer := class.FindMethod("init"); initializer != nil {
+			return initializer
+		}
+	}
+	return callee
 }
 
-// 工具方法
+// bindParameters 在函数环境env中依次定义参数
+// 没有传入或以Missing占位的参数在env中对默认值求值，因此默认值可以引用它之前的参数；剩余参数收集多余的参数为列表
+func (i *Interpreter) bindParameters(declaration *ast.Function, env *environment.Environment, arguments []interface{}) {
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+	}()
+	i.environment = env
 
-// isTruthy 判断一个值是否为真
-func (i *Interpreter) isTruthy(value interface{}) bool {
-	if value == nil {
-		return false
-	}
-	if b, ok := value.(bool); ok {
-		return b
+	for j, param := range declaration.Params {
+		var value interface{}
+		switch {
+		case declaration.IsRest(j):
+			value = RestArguments(arguments, j)
+		case j < len(arguments) && arguments[j] != Missing:
+			value = arguments[j]
+		default:
+			value = i.evaluate(declaration.Default(j))
+		}
+		env.Define(param.Lexeme, value)
 	}
-	return true
 }
 
-// isEqual 判断两个值是否相等
-func (i *Interpreter) isEqual(a, b interface{}) bool {
-	if a == nil && b == nil {
-		return true
-	}
-	if a == nil {
-		return false
+// VisitGetExpr 处理属性访问表达式
+func (i *Interpreter) VisitGetExpr(expr *ast.Get) interface{} {
+	object := i.evaluate(expr.Object)
+	switch object := object.(type) {
+	case *Instance:
+		return object.Get(expr.Name)
+	case *List:
+		return object.Get(expr.Name)
+	case *Map:
+		return object.Get(expr.Name)
+	case *ErrorValue:
+		return object.Get(expr.Name)
+	case *Module:
+		return object.Get(expr.Name)
+	case string:
+		return StringMethod(object, expr.Name)
 	}
-	return a == b
-}
 
-// isNumber 判断一个值是否为数字
-func (i *Interpreter) isNumber(value interface{}) bool {
-	_, ok := value.(float64)
-	return ok
+	panic(error.RuntimeError{Token: expr.Name, Message: "只有实例才有属性。"})
 }
 
-// isString 判断一个值是否为字符串
-func (i *Interpreter) isString(value interface{}) bool {
-	_, ok := value.(string)
-	return ok
-}
+// VisitSetExpr 处理属性赋值表达式
+func (i *Interpreter) VisitSetExpr(expr *ast.Set) interface{} {
+	object := i.evaluate(expr.Object)
 
-// asNumber 转换为数字
-func (i *Interpreter) asNumber(value interface{}) float64 {
-	number, ok := value.(float64)
+	instance, ok := object.(*Instance)
 	if !ok {
-		// 应该不会到这里，因为我们已经用checkNumberOperand检查过了
-		return 0
+		panic(error.RuntimeError{Token: expr.Name, Message: "只有实例才有字段。"})
 	}
-	return number
+
+	value := i.evaluate(expr.Value)
+	instance.Set(expr.Name, value)
+	return value
 }
 
-// asString 转换为字符串
-func (i *Interpreter) asString(value interface{}) string {
-	str, ok := value.(string)
-	if !ok {
-		return ""
+// VisitListExpr 处理列表字面量表达式
+func (i *Interpreter) VisitListExpr(expr *ast.List) interface{} {
+	elements := make([]Value, len(expr.Elements))
+	for j, element := range expr.Elements {
+		elements[j] = i.evaluate(element)
 	}
-	return str
+	return NewList(elements)
 }
 
-// checkNumberOperand 检查一元运算符的操作数是否为数字
-func (i *Interpreter) checkNumberOperand(operator *token.Token, operand interface{}) {
-	if i.isNumber(operand) {
-		return
+// VisitMapExpr 处理映射字面量表达式，重复的键以后出现的值为准
+func (i *Interpreter) VisitMapExpr(expr *ast.Map) interface{} {
+	m := NewMap()
+	for j, keyExpr := range expr.Keys {
+		key := i.evaluate(keyExpr)
+		value := i.evaluate(expr.Values[j])
+		SetIndex(expr.Brace, m, key, value)
 	}
-	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+	return m
 }
 
-// checkNumberOperands 检查二元运算符的操作数是否为数字
-func (i *Interpreter) checkNumberOperands(operator *token.Token, left, right interface{}) {
-	if i.isNumber(left) && i.isNumber(right) {
-		return
+// VisitInterpolationExpr 处理插值字符串表达式，每一部分的值按print的格式转换为字符串后拼接
+func (i *Interpreter) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	var builder strings.Builder
+	for _, part := range expr.Parts {
+		builder.WriteString(i.stringify(i.evaluate(part)))
 	}
-	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+	return builder.String()
 }
 
-// stringify 将值转换为字符串
-func (i *Interpreter) stringify(value interface{}) string {
-	if value == nil {
-		return "nil"
-	}
+// VisitLambdaExpr 处理匿名函数表达式，创建捕获当前环境的闭包
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	return NewFunction(expr.Function, i.environment, i.globals, false)
+}
 
-	// 如果是浮点数
-	if num, ok := value.(float64); ok {
-		text := fmt.Sprintf("%g", num)
-		// 如果是整数，去掉小数点和小数点后的零
-		if math.Floor(num) == num {
-			text = fmt.Sprintf("%.0f", num)
-		}
-		return text
-	}
+// VisitIndexExpr 处理下标访问表达式
+func (i *Interpreter) VisitIndexExpr(expr *ast.Index) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	return GetIndex(expr.Bracket, object, index)
+}
 
-	// 如果是字符串，直接返回
-	if str, ok := value.(string); ok {
-		return str
-	}
+// VisitIndexSetExpr 处理下标赋值表达式
+func (i *Interpreter) VisitIndexSetExpr(expr *ast.IndexSet) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	value := i.evaluate(expr.Value)
+	return SetIndex(expr.Bracket, object, index, value)
+}
 
-	// 如果是布尔值
-	if b, ok := value.(bool); ok {
-		if b {
-			return "true"
-		}
-		return "false"
-	}
+// VisitThisExpr 处理this表达式
+func (i *Interpreter) VisitThisExpr(expr *ast.This) interface{} {
+	return i.lookUpVariable(expr.Keyword, expr)
+}
+
+// VisitSuperExpr 处理super表达式
+func (i *Interpreter) VisitSuperExpr(expr *ast.Super) interface{} {
+	distance := i.locals[expr]
+	superclass := i.environment.GetAt(distance, "super").(*Class)
+
+	// this总是位于super所在环境的内一层
+	object := i.environment.GetAt(distance-1, "this").(*Instance)
 
-	// 如果是可调用对象，调用其String方法
-	if callable, ok := value.(Callable); ok {
-		return callable.String()
+	method := superclass.FindMethod(expr.Method.Lexeme)
+	if method == nil {
+		panic(error.RuntimeError{
+			Token:   expr.Method,
+			Message: fmt.Sprintf("未定义的属性 '%s'。", expr.Method.Lexeme),
+		})
 	}
 
-	// 其他类型
-	return fmt.Sprintf("%v", value)
+	return method.Bind(object)
+}
+
+// 工具方法
+
+// isTruthy 判断一个值是否为真
+func (i *Interpreter) isTruthy(value interface{}) bool {
+	return IsTruthy(value)
+}
+
+// isEqual 判断两个值是否相等
+func (i *Interpreter) isEqual(a, b interface{}) bool {
+	return IsEqual(a, b)
+}
+
+// stringify 将值转换为字符串
+func (i *Interpreter) stringify(value interface{}) string {
+	return Stringify(value)
 }
 
 // Resolve 记录变量引用的作用域深度
@@ -1,9 +1,13 @@
 package interpreter
 
 import (
+	"bytes"
+	"fmt"
+	"math"
 	"testing"
 
 	"github.com/aixiasang/goLox/lox/ast"
+	errorp "github.com/aixiasang/goLox/lox/error"
 	"github.com/aixiasang/goLox/lox/token"
 )
 
@@ -20,6 +24,14 @@ func (m *MockErrorReporter) ReportError(line int, message string) {
 	m.Errors = append(m.Errors, message)
 }
 
+func (m *MockErrorReporter) ReportErrorAt(span token.Span, message string) {
+	m.Errors = append(m.Errors, message)
+}
+
+func (m *MockErrorReporter) ReportRuntimeError(err errorp.RuntimeError) {
+	m.Errors = append(m.Errors, err.Message)
+}
+
 func (m *MockErrorReporter) ResetError() {
 	m.Errors = nil
 }
@@ -134,6 +146,113 @@ func TestBinaryExpression(t *testing.T) {
 	}
 }
 
+// 测试整数与浮点数的运算规则
+func TestNumericTower(t *testing.T) {
+	op := func(tokenType token.TokenType, lexeme string) *token.Token {
+		return token.NewToken(tokenType, lexeme, nil, 1)
+	}
+	plus, minus, star := op(token.PLUS, "+"), op(token.MINUS, "-"), op(token.STAR, "*")
+	slash, modulo, less := op(token.SLASH, "/"), op(token.MODULO, "%"), op(token.LESS, "<")
+
+	tests := []struct {
+		name     string
+		operator *token.Token
+		left     Value
+		right    Value
+		expected Value
+	}{
+		{"整数加法", plus, int64(1), int64(2), int64(3)},
+		{"混合加法", plus, int64(1), 0.5, 1.5},
+		{"整数除法向零取整", slash, int64(-7), int64(2), int64(-3)},
+		{"浮点数除法", slash, 7.0, int64(2), 3.5},
+		{"整数取模", modulo, int64(-7), int64(3), int64(-1)},
+		{"浮点数取模", modulo, 5.5, int64(2), 1.5},
+		{"最小整数取模-1", modulo, int64(math.MinInt64), int64(-1), int64(0)},
+		{"加法溢出", plus, int64(math.MaxInt64), int64(1), float64(math.MaxInt64) + 1},
+		{"减法溢出", minus, int64(math.MinInt64), int64(1), float64(math.MinInt64) - 1},
+		{"乘法溢出", star, int64(1) << 62, int64(4), math.Pow(2, 64)},
+		{"除法溢出", slash, int64(math.MinInt64), int64(-1), -float64(math.MinInt64)},
+		{"混合比较", less, int64(2), 2.5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BinaryOp(tt.operator, tt.left, tt.right); got != tt.expected {
+				t.Errorf("期望%v(%T)，实际%v(%T)", tt.expected, tt.expected, got, got)
+			}
+		})
+	}
+
+	if got := UnaryOp(minus, int64(math.MinInt64)); got != -float64(math.MinInt64) {
+		t.Errorf("最小整数取负应提升为浮点数，实际%v(%T)", got, got)
+	}
+	if !IsEqual(int64(1), 1.0) || IsEqual(int64(1), 1.5) || IsEqual(int64(1), "1") {
+		t.Errorf("整数与浮点数应按数值比较相等")
+	}
+}
+
+// 测试位运算和乘方
+func TestBitwiseAndPower(t *testing.T) {
+	op := func(tokenType token.TokenType, lexeme string) *token.Token {
+		return token.NewToken(tokenType, lexeme, nil, 1)
+	}
+
+	tests := []struct {
+		name     string
+		operator *token.Token
+		left     Value
+		right    Value
+		expected Value
+	}{
+		{"按位与", op(token.AMPERSAND, "&"), int64(6), int64(3), int64(2)},
+		{"按位或", op(token.PIPE, "|"), int64(6), int64(3), int64(7)},
+		{"按位异或", op(token.CARET, "^"), int64(6), int64(3), int64(5)},
+		{"左移", op(token.LESS_LESS, "<<"), int64(1), int64(10), int64(1024)},
+		{"算术右移", op(token.GREATER_GREATER, ">>"), int64(-16), int64(2), int64(-4)},
+		{"整数乘方", op(token.STAR_STAR, "**"), int64(3), int64(4), int64(81)},
+		{"零次幂", op(token.STAR_STAR, "**"), int64(0), int64(0), int64(1)},
+		{"负指数", op(token.STAR_STAR, "**"), int64(2), int64(-2), 0.25},
+		{"浮点数乘方", op(token.STAR_STAR, "**"), 4.0, 0.5, 2.0},
+		{"乘方溢出", op(token.STAR_STAR, "**"), int64(2), int64(64), math.Pow(2, 64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BinaryOp(tt.operator, tt.left, tt.right); got != tt.expected {
+				t.Errorf("期望%v(%T)，实际%v(%T)", tt.expected, tt.expected, got, got)
+			}
+		})
+	}
+
+	if got := UnaryOp(op(token.TILDE, "~"), int64(5)); got != int64(-6) {
+		t.Errorf("~5期望-6，实际%v", got)
+	}
+
+	errorTests := []struct {
+		name     string
+		operator *token.Token
+		left     Value
+		right    Value
+		message  string
+	}{
+		{"浮点数位运算", op(token.AMPERSAND, "&"), 1.0, int64(1), "位运算的操作数必须是整数。"},
+		{"负数移位", op(token.LESS_LESS, "<<"), int64(1), int64(-1), "移位的位数不能为负数。"},
+		{"字符串乘方", op(token.STAR_STAR, "**"), "a", int64(2), "操作数必须是数字。"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(errorp.RuntimeError)
+				if !ok || err.Message != tt.message {
+					t.Errorf("期望错误%q，实际: %v", tt.message, err)
+				}
+			}()
+			BinaryOp(tt.operator, tt.left, tt.right)
+		})
+	}
+}
+
 func TestTernaryExpression(t *testing.T) {
 	errorReporter := &MockErrorReporter{}
 	interpreter := NewInterpreter(errorReporter)
@@ -143,13 +262,14 @@ func TestTernaryExpression(t *testing.T) {
 		expected interface{}
 	}{
 		// 条件为true
-		{ast.NewTernary(ast.NewLiteral(true), ast.NewLiteral(1.0), ast.NewLiteral(2.0)), 1.0},
+		{ast.NewTernary(ast.NewLiteral(true), token.NewToken(token.QUESTION, "?", nil, 1), ast.NewLiteral(1.0), ast.NewLiteral(2.0)), 1.0},
 		// 条件为false
-		{ast.NewTernary(ast.NewLiteral(false), ast.NewLiteral(1.0), ast.NewLiteral(2.0)), 2.0},
+		{ast.NewTernary(ast.NewLiteral(false), token.NewToken(token.QUESTION, "?", nil, 1), ast.NewLiteral(1.0), ast.NewLiteral(2.0)), 2.0},
 		// 嵌套三元表达式
 		{ast.NewTernary(
 			ast.NewLiteral(true),
-			ast.NewTernary(ast.NewLiteral(false), ast.NewLiteral(1.0), ast.NewLiteral(2.0)),
+			token.NewToken(token.QUESTION, "?", nil, 1),
+			ast.NewTernary(ast.NewLiteral(false), token.NewToken(token.QUESTION, "?", nil, 1), ast.NewLiteral(1.0), ast.NewLiteral(2.0)),
 			ast.NewLiteral(3.0),
 		), 2.0},
 	}
@@ -288,6 +408,7 @@ func TestIfStatement(t *testing.T) {
 
 	// 创建if语句：if (true) x = 1; else x = 2;
 	ifStmt := ast.NewIf(
+		token.NewToken(token.IF, "if", nil, 1),
 		ast.NewLiteral(true),
 		ast.NewExpression(ast.NewAssign(xToken, ast.NewLiteral(1.0))),
 		ast.NewExpression(ast.NewAssign(xToken, ast.NewLiteral(2.0))),
@@ -306,6 +427,7 @@ func TestIfStatement(t *testing.T) {
 
 	// 创建if语句：if (false) x = 3; else x = 4;
 	ifStmt2 := ast.NewIf(
+		token.NewToken(token.IF, "if", nil, 1),
 		ast.NewLiteral(false),
 		ast.NewExpression(ast.NewAssign(xToken, ast.NewLiteral(3.0))),
 		ast.NewExpression(ast.NewAssign(xToken, ast.NewLiteral(4.0))),
@@ -332,6 +454,7 @@ func TestWhileStatement(t *testing.T) {
 
 	// 创建while语句：while (i < 5) i = i + 1;
 	whileStmt := ast.NewWhile(
+		token.NewToken(token.WHILE, "while", nil, 1),
 		ast.NewBinary(
 			ast.NewVariable(iToken),
 			token.NewToken(token.LESS, "<", nil, 1),
@@ -433,8 +556,11 @@ func TestStringify(t *testing.T) {
 		expected string
 	}{
 		{nil, "nil"},
-		{123.0, "123"},
+		{int64(123), "123"},
+		{int64(-7), "-7"},
+		{123.0, "123.0"},
 		{123.45, "123.45"},
+		{1e20, "1e+20"},
 		{"hello", "hello"},
 		{true, "true"},
 		{false, "false"},
@@ -447,3 +573,367 @@ func TestStringify(t *testing.T) {
 		}
 	}
 }
+
+func TestDefineNative(t *testing.T) {
+	errorReporter := &MockErrorReporter{}
+	interpreter := NewInterpreter(errorReporter)
+
+	interpreter.DefineNative("double", 1, func(args []Value) (Value, error) {
+		return args[0].(float64) * 2, nil
+	})
+	interpreter.DefineNative("count", Variadic, func(args []Value) (Value, error) {
+		return float64(len(args)), nil
+	})
+	interpreter.DefineNative("fail", 0, func(args []Value) (Value, error) {
+		return nil, fmt.Errorf("宿主函数出错")
+	})
+
+	call := func(name string, args ...ast.Expr) ast.Expr {
+		return ast.NewCall(ast.NewVariable(token.NewToken(token.IDENTIFIER, name, nil, 1)), token.NewToken(token.RIGHT_PAREN, ")", nil, 7), args)
+	}
+
+	if result := interpreter.evaluate(call("double", ast.NewLiteral(21.0))); result != 42.0 {
+		t.Errorf("期望42，实际: %v", result)
+	}
+	if result := interpreter.evaluate(call("count")); result != 0.0 {
+		t.Errorf("期望0，实际: %v", result)
+	}
+	if result := interpreter.evaluate(call("count", ast.NewLiteral(1.0), ast.NewLiteral("a"), ast.NewLiteral(nil))); result != 3.0 {
+		t.Errorf("期望3，实际: %v", result)
+	}
+
+	// Go错误转换为调用位置的运行时错误
+	func() {
+		defer func() {
+			runtimeError, ok := recover().(errorp.RuntimeError)
+			if !ok {
+				t.Fatalf("期望运行时错误")
+			}
+			if runtimeError.Message != "宿主函数出错" || runtimeError.Token.Line != 7 {
+				t.Errorf("运行时错误不正确: %+v", runtimeError)
+			}
+		}()
+		interpreter.evaluate(call("fail"))
+	}()
+
+	// 参数数量检查
+	interpreter.Interpret([]ast.Stmt{ast.NewExpression(call("double"))})
+	if len(errorReporter.Errors) == 0 || errorReporter.Errors[0] != "期望1个参数，但得到0个。" {
+		t.Errorf("期望参数数量错误，实际: %v", errorReporter.Errors)
+	}
+}
+
+// 测试参数个数范围的检查和错误信息
+func TestArityRange(t *testing.T) {
+	tests := []struct {
+		arity    ArityRange
+		count    int
+		expected string
+	}{
+		{Exactly(2), 2, ""},
+		{Exactly(2), 1, "期望2个参数，但得到1个。"},
+		{ArityRange{Min: 1, Max: 3}, 3, ""},
+		{ArityRange{Min: 1, Max: 3}, 4, "期望1到3个参数，但得到4个。"},
+		{ArityRange{Min: 1, Max: Variadic}, 100, ""},
+		{ArityRange{Min: 1, Max: Variadic}, 0, "期望至少1个参数，但得到0个。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tt.arity, tt.count), func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.expected == "" {
+					if r != nil {
+						t.Errorf("意外的错误: %v", r)
+					}
+					return
+				}
+				runtimeError, ok := r.(errorp.RuntimeError)
+				if !ok || runtimeError.Message != tt.expected {
+					t.Errorf("期望错误 %q，实际: %v", tt.expected, r)
+				}
+			}()
+			CheckArity(tt.arity, token.NewToken(token.RIGHT_PAREN, ")", nil, 1), tt.count)
+		})
+	}
+}
+
+// namedCallee 测试用的可以按名称传参的函数
+type namedCallee Parameters
+
+func (c namedCallee) Parameters() Parameters {
+	return Parameters(c)
+}
+
+// 测试命名参数按参数名排列到对应的位置
+func TestArrangeArguments(t *testing.T) {
+	callee := namedCallee{Names: []string{"a", "b", "c"}, Required: 1}
+	tests := []struct {
+		positional []Value
+		names      []string // 命名参数的参数名，值依次为10、20...
+		expected   []Value
+		message    string
+	}{
+		{[]Value{int64(1)}, []string{"c"}, []Value{int64(1), Missing, int64(10)}, ""},
+		{nil, []string{"b", "a"}, []Value{int64(20), int64(10)}, ""},
+		{[]Value{int64(1), int64(2)}, []string{"a"}, nil, "参数 'a' 已经按位置传入。"},
+		{nil, []string{"d"}, nil, "没有名为 'd' 的参数。"},
+		{nil, []string{"c"}, nil, "缺少参数 'a'。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%v", tt.positional, tt.names), func(t *testing.T) {
+			arguments := tt.positional
+			names := make([]*token.Token, len(tt.names))
+			for i, name := range tt.names {
+				arguments = append(arguments, int64(10*(i+1)))
+				names[i] = token.NewToken(token.IDENTIFIER, name, nil, 1)
+			}
+
+			defer func() {
+				r := recover()
+				if tt.message == "" {
+					if r != nil {
+						t.Errorf("意外的错误: %v", r)
+					}
+					return
+				}
+				runtimeError, ok := r.(errorp.RuntimeError)
+				if !ok || runtimeError.Message != tt.message {
+					t.Errorf("期望错误 %q，实际: %v", tt.message, r)
+				}
+			}()
+			result := ArrangeArguments(callee, token.NewToken(token.RIGHT_PAREN, ")", nil, 1), arguments, names)
+			if fmt.Sprint(result) != fmt.Sprint(tt.expected) {
+				t.Errorf("期望参数 %v，实际 %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPrintOutput(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := NewInterpreter(&MockErrorReporter{})
+	interpreter.SetOutput(&out)
+
+	interpreter.Interpret([]ast.Stmt{
+		ast.NewPrint(ast.NewLiteral("hello")),
+		ast.NewPrint(ast.NewLiteral(int64(3))),
+	})
+
+	if out.String() != "hello\n3\n" {
+		t.Errorf("期望输出 %q，实际: %q", "hello\n3\n", out.String())
+	}
+}
+
+// 测试映射的键与IsEqual使用相同的相等规则，并保持插入顺序
+func TestMap(t *testing.T) {
+	list := NewList(nil)
+	m := NewMap()
+	for _, key := range []Value{"a", 1.0, true, nil, list} {
+		if err := m.Put(key, key); err != nil {
+			t.Fatalf("Put(%v)出错: %v", key, err)
+		}
+	}
+	m.Put(1.0, "one")
+
+	has := func(key Value) bool {
+		ok, err := m.Has(key)
+		if err != nil {
+			t.Fatalf("Has(%v)出错: %v", key, err)
+		}
+		return ok
+	}
+	remove := func(key Value) bool {
+		ok, err := m.Delete(key)
+		if err != nil {
+			t.Fatalf("Delete(%v)出错: %v", key, err)
+		}
+		return ok
+	}
+
+	if m.String() != "{a: a, 1: one, true: true, nil: nil, []: []}" {
+		t.Errorf("映射字符串表示不正确: %s", m.String())
+	}
+	for _, key := range []Value{"a", 2.0 - 1.0, int64(1), true, nil, list} {
+		if !has(key) {
+			t.Errorf("期望存在键 %v", key)
+		}
+	}
+	if has(NewList(nil)) {
+		t.Errorf("不同的列表对象不应视为相同的键")
+	}
+	if has("1") {
+		t.Errorf("字符串\"1\"与数字1不应视为相同的键")
+	}
+
+	if !remove(1.0) || remove(1.0) {
+		t.Errorf("Delete应只在键存在时返回true")
+	}
+	if m.String() != "{a: a, true: true, nil: nil, []: []}" {
+		t.Errorf("删除后映射字符串表示不正确: %s", m.String())
+	}
+
+	if err := m.Put(math.NaN(), 1.0); err == nil {
+		t.Errorf("期望NaN作为键时报错")
+	}
+
+	// 宿主程序传入的不可比较的Go值作为键时报错，而不是使Go的map崩溃
+	key := []Value{int64(1)}
+	if err := m.Put(key, 1.0); err == nil {
+		t.Errorf("期望不可比较的值作为键时报错")
+	}
+	if _, err := m.Has(key); err == nil {
+		t.Errorf("期望Has对不可比较的键报错")
+	}
+	if _, _, err := m.Lookup(key); err == nil {
+		t.Errorf("期望Lookup对不可比较的键报错")
+	}
+	if _, err := m.Delete(key); err == nil {
+		t.Errorf("期望Delete对不可比较的键报错")
+	}
+
+	bracket := token.NewToken(token.LEFT_BRACKET, "[", nil, 1)
+	for _, index := range []func(){
+		func() { GetIndex(bracket, m, key) },
+		func() { SetIndex(bracket, m, key, 1.0) },
+	} {
+		func() {
+			defer func() {
+				runtimeError, ok := recover().(errorp.RuntimeError)
+				if !ok || runtimeError.Token != bracket || runtimeError.Message != "映射的键不能是不可比较的值 '[1]'。" {
+					t.Errorf("期望在'['处报告运行时错误，实际: %v", runtimeError)
+				}
+			}()
+			index()
+		}()
+	}
+}
+
+func TestStringMethods(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		method   string
+		args     []Value
+		expected string
+	}{
+		{"中文长度", "你好, world", "len", nil, "9"},
+		{"子串按字符计算", "你好, world", "substr", []Value{int64(0), int64(2)}, "你好"},
+		{"空子串", "abc", "substr", []Value{int64(3), int64(3)}, ""},
+		{"查找位置", "你好, world", "indexOf", []Value{"world"}, "4"},
+		{"查找不到", "abc", "indexOf", []Value{"x"}, "-1"},
+		{"拆分", "a,b,,c", "split", []Value{","}, "[a, b, , c]"},
+		{"拆分为字符", "中文", "split", []Value{""}, "[中, 文]"},
+		{"连接", ", ", "join", []Value{NewList([]Value{int64(1), "a", nil})}, "1, a, nil"},
+		{"替换全部", "a.b.c", "replace", []Value{".", "/"}, "a/b/c"},
+		{"大写", "Hello", "upper", nil, "HELLO"},
+		{"小写", "Hello", "lower", nil, "hello"},
+		{"去除空白", " \t hi\n", "trim", nil, "hi"},
+		{"前缀", "你好", "startsWith", []Value{"你"}, "true"},
+		{"后缀", "你好", "endsWith", []Value{"你"}, "false"},
+		{"重复", "好", "repeat", []Value{int64(3)}, "好好好"},
+		{"重复零次", "ab", "repeat", []Value{0.0}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := StringMethod(tt.s, token.NewToken(token.IDENTIFIER, tt.method, nil, 1))
+			result, err := method.(*NativeFunction).CallNative(tt.args)
+			if err != nil {
+				t.Fatalf("调用出错: %v", err)
+			}
+			if Stringify(result) != tt.expected {
+				t.Errorf("期望 %q，实际 %q", tt.expected, Stringify(result))
+			}
+		})
+	}
+}
+
+// 测试字符串方法对非法参数返回错误
+func TestStringMethodErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		method   string
+		args     []Value
+		expected string
+	}{
+		{"子串下标越界", "你好", "substr", []Value{int64(0), int64(3)}, "字符串下标越界。"},
+		{"非整数下标", "abc", "substr", []Value{0.5, int64(1)}, "字符串下标必须是整数。"},
+		{"子串起止颠倒", "abc", "substr", []Value{int64(2), int64(1)}, "子串的起始位置不能大于结束位置。"},
+		{"参数不是字符串", "abc", "split", []Value{int64(1)}, "'split'的参数必须是字符串。"},
+		{"连接非列表", ", ", "join", []Value{"ab"}, "'join'的参数必须是列表。"},
+		{"负数重复次数", "abc", "repeat", []Value{int64(-1)}, "重复次数必须是非负整数。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := StringMethod(tt.s, token.NewToken(token.IDENTIFIER, tt.method, nil, 1))
+			_, err := method.(*NativeFunction).CallNative(tt.args)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("期望错误 %q，实际: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		args     []Value
+		expected Value
+	}{
+		{"整数平方根", "sqrt", []Value{int64(16)}, 4.0},
+		{"整数乘方", "pow", []Value{int64(2), int64(10)}, int64(1024)},
+		{"负指数", "pow", []Value{int64(2), int64(-1)}, 0.5},
+		{"整数绝对值", "abs", []Value{int64(-3)}, int64(3)},
+		{"最小整数的绝对值", "abs", []Value{int64(math.MinInt64)}, -float64(math.MinInt64)},
+		{"浮点数绝对值", "abs", []Value{-2.5}, 2.5},
+		{"向下取整", "floor", []Value{-2.5}, int64(-3)},
+		{"向上取整", "ceil", []Value{2.1}, int64(3)},
+		{"四舍五入", "round", []Value{2.5}, int64(3)},
+		{"整数取整", "round", []Value{int64(7)}, int64(7)},
+		{"超出整数范围的取整", "floor", []Value{1e300}, 1e300},
+		{"最小值保持类型", "min", []Value{int64(3), 1.5, int64(2)}, 1.5},
+		{"最大值保持类型", "max", []Value{int64(3), 1.5, int64(2)}, int64(3)},
+		{"自然对数", "log", []Value{1.0}, 0.0},
+		{"指数", "exp", []Value{int64(0)}, 1.0},
+		{"正弦", "sin", []Value{0.0}, 0.0},
+		{"余弦", "cos", []Value{int64(0)}, 1.0},
+		{"正切", "tan", []Value{0.0}, 0.0},
+	}
+
+	module := NewMathModule()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function := module.Get(token.NewToken(token.IDENTIFIER, tt.function, nil, 1))
+			result, err := function.(*NativeFunction).CallNative(tt.args)
+			if err != nil {
+				t.Fatalf("调用出错: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("期望 %v(%T)，实际 %v(%T)", tt.expected, tt.expected, result, result)
+			}
+		})
+	}
+
+	// 设置相同的种子后得到相同的随机数序列
+	random := module.Get(token.NewToken(token.IDENTIFIER, "random", nil, 1)).(*NativeFunction)
+	seed := module.Get(token.NewToken(token.IDENTIFIER, "seed", nil, 1)).(*NativeFunction)
+	sequence := func() []Value {
+		seed.CallNative([]Value{int64(7)})
+		values := make([]Value, 3)
+		for i := range values {
+			values[i], _ = random.CallNative(nil)
+			if f := values[i].(float64); f < 0 || f >= 1 {
+				t.Errorf("随机数超出[0, 1)范围: %v", f)
+			}
+		}
+		return values
+	}
+	if first, second := sequence(), sequence(); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("相同种子的随机数序列不一致: %v %v", first, second)
+	}
+}
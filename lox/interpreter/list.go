@@ -0,0 +1,152 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// List 列表对象，所有执行后端共享同一实现
+type List struct {
+	Elements []Value
+}
+
+// NewList 创建一个包含给定元素的列表
+func NewList(elements []Value) *List {
+	return &List{Elements: elements}
+}
+
+// String 返回列表的字符串表示
+func (l *List) String() string {
+	parts := make([]string, len(l.Elements))
+	for i, element := range l.Elements {
+		parts[i] = Stringify(element)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// Get 返回绑定到该列表的内置方法
+func (l *List) Get(name *token.Token) Value {
+	switch name.Lexeme {
+	case "push":
+		return NewNativeFunction("push", 1, func(args []Value) (Value, error) {
+			l.Elements = append(l.Elements, args[0])
+			return nil, nil
+		})
+	case "pop":
+		return NewNativeFunction("pop", 0, func(args []Value) (Value, error) {
+			if len(l.Elements) == 0 {
+				return nil, errors.New("不能从空列表中弹出元素。")
+			}
+			last := l.Elements[len(l.Elements)-1]
+			l.Elements = l.Elements[:len(l.Elements)-1]
+			return last, nil
+		})
+	case "len":
+		return NewNativeFunction("len", 0, func(args []Value) (Value, error) {
+			return int64(len(l.Elements)), nil
+		})
+	case "slice":
+		return NewNativeFunction("slice", 2, func(args []Value) (Value, error) {
+			start, err := listIndex(args[0], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := listIndex(args[1], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, errors.New("切片的起始位置不能大于结束位置。")
+			}
+			return NewList(append([]Value(nil), l.Elements[start:end]...)), nil
+		})
+	case "insert":
+		return NewNativeFunction("insert", 2, func(args []Value) (Value, error) {
+			index, err := listIndex(args[0], len(l.Elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			l.Elements = append(l.Elements, nil)
+			copy(l.Elements[index+1:], l.Elements[index:])
+			l.Elements[index] = args[1]
+			return nil, nil
+		})
+	case "remove":
+		return NewNativeFunction("remove", 1, func(args []Value) (Value, error) {
+			index, err := listIndex(args[0], len(l.Elements))
+			if err != nil {
+				return nil, err
+			}
+			removed := l.Elements[index]
+			l.Elements = append(l.Elements[:index], l.Elements[index+1:]...)
+			return removed, nil
+		})
+	}
+
+	panic(errorp.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
+
+// listIndex 将下标转换为[0, limit)范围内的整数，整数值的浮点数也可以作为下标
+func listIndex(index Value, limit int) (int, error) {
+	n, ok := ToInt(index)
+	if !ok {
+		return 0, errors.New("列表下标必须是整数。")
+	}
+	if n < 0 || n >= int64(limit) {
+		return 0, errors.New("列表下标越界。")
+	}
+	return int(n), nil
+}
+
+// GetIndex 计算object[index]，object可以是列表或映射
+func GetIndex(bracket *token.Token, object, index Value) Value {
+	switch object := object.(type) {
+	case *List:
+		i, err := listIndex(index, len(object.Elements))
+		if err != nil {
+			panic(errorp.RuntimeError{Token: bracket, Message: err.Error()})
+		}
+		return object.Elements[i]
+	case *Map:
+		value, ok, err := object.Lookup(index)
+		if err != nil {
+			panic(errorp.RuntimeError{Token: bracket, Message: err.Error()})
+		}
+		if !ok {
+			panic(errorp.RuntimeError{
+				Token:   bracket,
+				Message: fmt.Sprintf("映射中不存在键 '%s'。", Stringify(index)),
+			})
+		}
+		return value
+	}
+
+	panic(errorp.RuntimeError{Token: bracket, Message: "只能对列表或映射使用下标。"})
+}
+
+// SetIndex 执行object[index] = value并返回value
+func SetIndex(bracket *token.Token, object, index, value Value) Value {
+	switch object := object.(type) {
+	case *List:
+		i, err := listIndex(index, len(object.Elements))
+		if err != nil {
+			panic(errorp.RuntimeError{Token: bracket, Message: err.Error()})
+		}
+		object.Elements[i] = value
+		return value
+	case *Map:
+		if err := object.Put(index, value); err != nil {
+			panic(errorp.RuntimeError{Token: bracket, Message: err.Error()})
+		}
+		return value
+	}
+
+	panic(errorp.RuntimeError{Token: bracket, Message: "只能对列表或映射使用下标。"})
+}
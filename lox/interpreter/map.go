@@ -0,0 +1,130 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Map 映射对象，所有执行后端共享同一实现
+//
+// 键的相等规则与IsEqual一致：数字、字符串、布尔值和nil按值比较，
+// 列表、映射、函数、实例等对象按引用比较。整数值的浮点数键以整数保存，因此1和1.0是同一个键。
+// 遍历顺序为键的插入顺序。
+type Map struct {
+	keys    []Value
+	entries map[Value]Value
+}
+
+// NewMap 创建一个空映射
+func NewMap() *Map {
+	return &Map{entries: make(map[Value]Value)}
+}
+
+// String 返回映射的字符串表示
+func (m *Map) String() string {
+	parts := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		parts[i] = Stringify(key) + ": " + Stringify(m.entries[key])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Len 返回映射中键的个数
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Has 判断映射中是否存在给定的键，键不能作为映射的键时返回错误
+func (m *Map) Has(key Value) (bool, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := m.entries[key]
+	return ok, nil
+}
+
+// Lookup 返回键对应的值，键不能作为映射的键时返回错误
+func (m *Map) Lookup(key Value) (Value, bool, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := m.entries[key]
+	return value, ok, nil
+}
+
+// Put 设置键对应的值，新键追加到遍历顺序的末尾
+func (m *Map) Put(key, value Value) error {
+	if n, ok := key.(float64); ok && math.IsNaN(n) {
+		return errors.New("映射的键不能是NaN。")
+	}
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+	return nil
+}
+
+// Delete 删除给定的键，返回键是否存在；键不能作为映射的键时返回错误
+func (m *Map) Delete(key Value) (bool, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := m.entries[key]; !ok {
+		return false, nil
+	}
+	delete(m.entries, key)
+	for i, k := range m.keys {
+		if IsEqual(k, key) {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+// Get 返回绑定到该映射的内置方法
+func (m *Map) Get(name *token.Token) Value {
+	switch name.Lexeme {
+	case "keys":
+		return NewNativeFunction("keys", 0, func(args []Value) (Value, error) {
+			return NewList(append([]Value(nil), m.keys...)), nil
+		})
+	case "values":
+		return NewNativeFunction("values", 0, func(args []Value) (Value, error) {
+			values := make([]Value, len(m.keys))
+			for i, key := range m.keys {
+				values[i] = m.entries[key]
+			}
+			return NewList(values), nil
+		})
+	case "has":
+		return NewNativeFunction("has", 1, func(args []Value) (Value, error) {
+			return m.Has(args[0])
+		})
+	case "delete":
+		return NewNativeFunction("delete", 1, func(args []Value) (Value, error) {
+			return m.Delete(args[0])
+		})
+	case "len":
+		return NewNativeFunction("len", 0, func(args []Value) (Value, error) {
+			return int64(m.Len()), nil
+		})
+	}
+
+	panic(errorp.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
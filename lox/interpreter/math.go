@@ -0,0 +1,139 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/aixiasang/goLox/lox/environment"
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// NewMathModule 创建内置的math模块，在Lox中通过math.sqrt(2)、math.PI等访问
+// 每个模块有自己的随机数生成器，默认以当前时间为种子，可以用math.seed(n)设置种子得到可重复的序列
+func NewMathModule() *Module {
+	globals := environment.NewEnvironment()
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	globals.Define("PI", math.Pi)
+	globals.Define("E", math.E)
+
+	unary := map[string]func(float64) float64{
+		"sqrt": math.Sqrt,
+		"sin":  math.Sin,
+		"cos":  math.Cos,
+		"tan":  math.Tan,
+		"log":  math.Log,
+		"exp":  math.Exp,
+	}
+	for name, fn := range unary {
+		globals.Define(name, NewNativeFunction(name, 1, func(args []Value) (Value, error) {
+			x, err := floatArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return fn(x), nil
+		}))
+	}
+
+	// 取整函数的结果可以用整数表示时返回整数
+	rounding := map[string]func(float64) float64{
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+	}
+	for name, fn := range rounding {
+		globals.Define(name, NewNativeFunction(name, 1, func(args []Value) (Value, error) {
+			if n, ok := args[0].(int64); ok {
+				return n, nil
+			}
+			x, err := floatArgument(name, args[0])
+			if err != nil {
+				return nil, err
+			}
+			if n, ok := ToInt(fn(x)); ok {
+				return n, nil
+			}
+			return fn(x), nil
+		}))
+	}
+
+	globals.Define("abs", NewNativeFunction("abs", 1, func(args []Value) (Value, error) {
+		if n, ok := args[0].(int64); ok && n != math.MinInt64 {
+			if n < 0 {
+				return -n, nil
+			}
+			return n, nil
+		}
+		x, err := floatArgument("abs", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return math.Abs(x), nil
+	}))
+
+	globals.Define("pow", NewNativeFunction("pow", 2, func(args []Value) (Value, error) {
+		for _, arg := range args {
+			if _, err := floatArgument("pow", arg); err != nil {
+				return nil, err
+			}
+		}
+		return power(args[0], args[1]), nil
+	}))
+
+	globals.Define("min", NewNativeFunction("min", Variadic, func(args []Value) (Value, error) {
+		return extremum("min", token.LESS, args)
+	}))
+	globals.Define("max", NewNativeFunction("max", Variadic, func(args []Value) (Value, error) {
+		return extremum("max", token.GREATER, args)
+	}))
+
+	globals.Define("random", NewNativeFunction("random", 0, func(args []Value) (Value, error) {
+		return random.Float64(), nil
+	}))
+	globals.Define("seed", NewNativeFunction("seed", 1, func(args []Value) (Value, error) {
+		seed, ok := ToInt(args[0])
+		if !ok {
+			return nil, errorp.RuntimeError{Message: "'seed'的参数必须是整数。"}
+		}
+		random.Seed(seed)
+		return nil, nil
+	}))
+
+	exports := []string{"PI", "E", "abs", "pow", "min", "max", "random", "seed"}
+	for name := range unary {
+		exports = append(exports, name)
+	}
+	for name := range rounding {
+		exports = append(exports, name)
+	}
+	return NewModule("math", globals, exports)
+}
+
+// extremum 返回参数中的最小值或最大值，operator为比较参数时使用的运算符，结果保持参数原来的类型
+func extremum(name string, operator token.TokenType, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, errorp.RuntimeError{Message: fmt.Sprintf("'%s'至少需要一个参数。", name)}
+	}
+	result := args[0]
+	for _, arg := range args {
+		if _, err := floatArgument(name, arg); err != nil {
+			return nil, err
+		}
+		if compare(operator, arg, result) {
+			result = arg
+		}
+	}
+	return result, nil
+}
+
+// floatArgument 检查函数name的参数是否为数字并转换为float64
+func floatArgument(name string, arg Value) (float64, error) {
+	x, ok := ToFloat(arg)
+	if !ok {
+		return 0, errorp.RuntimeError{Message: fmt.Sprintf("'%s'的参数必须是数字。", name)}
+	}
+	return x, nil
+}
@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/environment"
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Module 被导入的模块，在Lox中通过属性访问读取模块导出的名称
+// 模块的顶层代码在自己的全局环境中执行，只执行一次，之后的导入共享同一个模块
+type Module struct {
+	name    string                   // 模块的文件名
+	globals *environment.Environment // 模块的全局环境
+	exports map[string]bool          // 导出的名称
+}
+
+// NewModule 创建一个模块，globals为执行模块顶层代码时使用的全局环境
+func NewModule(name string, globals *environment.Environment, exports []string) *Module {
+	module := &Module{
+		name:    name,
+		globals: globals,
+		exports: make(map[string]bool, len(exports)),
+	}
+	for _, export := range exports {
+		module.exports[export] = true
+	}
+	return module
+}
+
+// Globals 返回模块的全局环境
+func (m *Module) Globals() *environment.Environment {
+	return m.globals
+}
+
+// Get 读取模块导出的名称，读取的总是当前的值
+func (m *Module) Get(name *token.Token) Value {
+	if !m.exports[name.Lexeme] {
+		panic(error.RuntimeError{
+			Token:   name,
+			Message: fmt.Sprintf("模块 '%s' 没有导出 '%s'。", m.name, name.Lexeme),
+		})
+	}
+	return m.globals.Get(name)
+}
+
+// String 返回模块的字符串表示
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+// Importer 加载path指向的模块并返回，由宿主程序按文件缓存模块
+// 找不到模块、循环导入或模块执行出错时抛出运行时错误
+type Importer func(path *token.Token) *Module
+
+// ImportModule 使用importer加载import语句中的模块，没有设置importer时抛出运行时错误
+func ImportModule(importer Importer, path *token.Token) *Module {
+	if importer == nil {
+		panic(error.RuntimeError{Token: path, Message: "当前环境不支持导入模块。"})
+	}
+	return importer(path)
+}
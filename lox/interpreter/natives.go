@@ -0,0 +1,161 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/aixiasang/goLox/lox/environment"
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// Value Lox运行时的值: nil、int64、float64、string、bool，或函数、类、实例等对象
+type Value = interface{}
+
+// Variadic 作为参数数量时表示内置函数接受任意数量的参数
+const Variadic = -1
+
+// Native 由Go实现的内置函数
+// 内置函数不依赖具体的解释器状态，因此可以在不同的执行后端之间共享
+type Native interface {
+	// CallNative 使用给定参数调用内置函数
+	CallNative(arguments []Value) (Value, error)
+	// Arity 返回函数需要的参数数量，Variadic表示任意数量
+	Arity() int
+	// String 返回函数的字符串表示
+	String() string
+}
+
+// NativeFunc 宿主程序提供的Go函数
+type NativeFunc func(args []Value) (Value, error)
+
+// NativeFunction 由宿主程序注册的内置函数
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    NativeFunc
+}
+
+// NewNativeFunction 创建一个内置函数，arity为Variadic时接受任意数量的参数
+func NewNativeFunction(name string, arity int, fn NativeFunc) *NativeFunction {
+	return &NativeFunction{
+		name:  name,
+		arity: arity,
+		fn:    fn,
+	}
+}
+
+// CallNative 调用Go函数
+func (n *NativeFunction) CallNative(arguments []Value) (Value, error) {
+	return n.fn(arguments)
+}
+
+// Arity 返回函数参数数量
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+// String 返回函数的字符串表示
+func (n *NativeFunction) String() string {
+	return fmt.Sprintf("<native fn: %s>", n.name)
+}
+
+// CallNative 在调用位置paren处调用内置函数
+// 参数数量不符或Go函数返回错误时抛出带调用位置的运行时错误
+func CallNative(native Native, paren *token.Token, arguments []Value) Value {
+	if arity := native.Arity(); arity != Variadic {
+		CheckArity(Exactly(arity), paren, len(arguments))
+	}
+
+	result, err := native.CallNative(arguments)
+	if err != nil {
+		runtimeError := errorp.RuntimeError{Token: paren, Message: err.Error()}
+		// 内置函数自己给出的运行时错误保留其信息，缺少位置时使用调用位置
+		var nativeError errorp.RuntimeError
+		if errors.As(err, &nativeError) {
+			runtimeError.Message = nativeError.Message
+			if nativeError.Token != nil {
+				runtimeError.Token = nativeError.Token
+			}
+		}
+		panic(runtimeError)
+	}
+
+	value, ok := nativeValue(result)
+	if !ok {
+		panic(errorp.RuntimeError{
+			Token:   paren,
+			Message: fmt.Sprintf("内置函数返回了Lox不支持的类型 '%T'。", result),
+		})
+	}
+	return value
+}
+
+// objectPackages Lox对象所属的包的路径前缀，函数、类、实例、列表等对象都是这些包中类型的指针
+var objectPackages = path.Dir(reflect.TypeOf(List{}).PkgPath()) + "/"
+
+// nativeValue 将宿主Go函数返回的值转换为Lox的值
+// Go的各种整数转换为int64，超出int64范围的无符号整数和float32转换为float64，[]Value转换为列表
+// 不是Lox的值也无法转换时返回false
+func nativeValue(result Value) (Value, bool) {
+	switch v := result.(type) {
+	case nil, int64, float64, string, bool:
+		return v, true
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		return unsignedValue(uint64(v)), true
+	case uint64:
+		return unsignedValue(v), true
+	case uintptr:
+		return unsignedValue(uint64(v)), true
+	case float32:
+		return float64(v), true
+	case []Value:
+		elements := make([]Value, len(v))
+		for i, element := range v {
+			value, ok := nativeValue(element)
+			if !ok {
+				return nil, false
+			}
+			elements[i] = value
+		}
+		return NewList(elements), true
+	}
+
+	t := reflect.TypeOf(result)
+	if t.Kind() == reflect.Pointer && strings.HasPrefix(t.Elem().PkgPath(), objectPackages) {
+		return result, true
+	}
+	return nil, false
+}
+
+// unsignedValue 将无符号整数转换为int64，超出范围时与整数运算溢出一样转换为float64
+func unsignedValue(n uint64) Value {
+	if n > math.MaxInt64 {
+		return float64(n)
+	}
+	return int64(n)
+}
+
+// DefineNatives 将所有内置函数和内置模块定义到全局环境中
+func DefineNatives(globals *environment.Environment) {
+	globals.Define("clock", &Clock{})
+	globals.Define("math", NewMathModule())
+}
@@ -0,0 +1,114 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Lox有两种数字：整数(int64)和浮点数(float64)。
+//
+// 两个整数运算的结果仍为整数，整数除法向零取整；加、减、乘、取负溢出时结果提升为浮点数。
+// 整数与浮点数混合运算时整数先转换为浮点数。比较和相等判断按数值进行，因此1 == 1.0。
+
+// maxExactFloat float64可以精确表示的整数范围的上界(2^63)，范围内的整数值浮点数可以转换为int64
+const maxExactFloat = 1 << 63
+
+// ToFloat 将数字转换为float64，value不是数字时ok为false
+func ToFloat(value Value) (f float64, ok bool) {
+	switch n := value.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// ToInt 将整数或整数值的浮点数转换为int64，其他值ok为false
+func ToInt(value Value) (i int64, ok bool) {
+	switch n := value.(type) {
+	case int64:
+		return n, true
+	case float64:
+		if n == math.Trunc(n) && n >= -maxExactFloat && n < maxExactFloat {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// AddInt 计算整数加法，溢出时ok为false
+func AddInt(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (a^c)&(b^c) >= 0
+}
+
+// SubtractInt 计算整数减法，溢出时ok为false
+func SubtractInt(a, b int64) (int64, bool) {
+	c := a - b
+	return c, (a^b)&(a^c) >= 0
+}
+
+// MultiplyInt 计算整数乘法，溢出时ok为false
+func MultiplyInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return c, false
+	}
+	return c, true
+}
+
+// FormatFloat 返回浮点数的字符串表示，整数值的浮点数保留".0"以便与整数区分
+func FormatFloat(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e16 {
+		return strconv.FormatFloat(f, 'f', 1, 64)
+	}
+	return fmt.Sprintf("%g", f)
+}
+
+// isNumber 判断一个值是否为数字
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+// numbersEqual 按数值判断两个数字是否相等
+func numbersEqual(a, b Value) bool {
+	x, xIsInt := a.(int64)
+	y, yIsInt := b.(int64)
+	if xIsInt && yIsInt {
+		return x == y
+	}
+	// 整数与浮点数比较时，浮点数必须恰好是该整数值
+	if xIsInt {
+		n, ok := ToInt(b)
+		return ok && n == x
+	}
+	if yIsInt {
+		n, ok := ToInt(a)
+		return ok && n == y
+	}
+	return a.(float64) == b.(float64)
+}
+
+// normalizeKey 将整数值的浮点数键转换为整数，使1和1.0是映射中的同一个键
+// 宿主程序传入的不可比较的Go值不能作为键，返回错误而不是使Go的map崩溃
+func normalizeKey(key Value) (Value, error) {
+	if f, ok := key.(float64); ok {
+		if n, ok := ToInt(f); ok {
+			return n, nil
+		}
+	}
+	if key != nil && !reflect.TypeOf(key).Comparable() {
+		return nil, fmt.Errorf("映射的键不能是不可比较的值 '%s'。", Stringify(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,326 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// 运算符的语义在这里集中定义，所有执行后端共享同一套规则，
+// 保证不同后端对同一段脚本给出相同的结果和错误信息。
+
+// IsTruthy 判断一个值是否为真，只有nil和false为假
+func IsTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// IsEqual 判断两个值是否相等
+func IsEqual(a, b interface{}) bool {
+	if a == nil && b == nil {
+		return true
+	}
+	if a == nil {
+		return false
+	}
+	if isNumber(a) && isNumber(b) {
+		return numbersEqual(a, b)
+	}
+	return a == b
+}
+
+// Stringify 将值转换为字符串
+func Stringify(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+
+	// 整数和浮点数，整数值的浮点数带有".0"
+	switch num := value.(type) {
+	case int64:
+		return strconv.FormatInt(num, 10)
+	case float64:
+		return FormatFloat(num)
+	}
+
+	// 如果是字符串，直接返回
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	// 如果是布尔值
+	if b, ok := value.(bool); ok {
+		if b {
+			return "true"
+		}
+		return "false"
+	}
+
+	// 函数、类、实例等对象使用其String方法
+	if stringer, ok := value.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	// 其他类型
+	return fmt.Sprintf("%v", value)
+}
+
+// UnaryOp 计算一元运算
+func UnaryOp(operator *token.Token, right interface{}) interface{} {
+	switch operator.Type {
+	case token.MINUS:
+		checkNumberOperand(operator, right)
+		if n, ok := right.(int64); ok {
+			if n == math.MinInt64 {
+				return -float64(n)
+			}
+			return -n
+		}
+		return -right.(float64)
+	case token.TILDE:
+		checkIntegerOperand(operator, right)
+		return ^right.(int64)
+	case token.BANG:
+		return !IsTruthy(right)
+	}
+
+	// 不可达
+	return nil
+}
+
+// BinaryOp 计算二元运算(不包括短路求值的逻辑运算)
+func BinaryOp(operator *token.Token, left, right interface{}) interface{} {
+	switch operator.Type {
+	case token.MINUS, token.SLASH, token.STAR, token.MODULO:
+		checkNumberOperands(operator, left, right)
+		return arithmetic(operator, left, right)
+	case token.STAR_STAR:
+		checkNumberOperands(operator, left, right)
+		return power(left, right)
+	case token.AMPERSAND, token.PIPE, token.CARET, token.LESS_LESS, token.GREATER_GREATER:
+		checkIntegerOperands(operator, left, right)
+		return bitwise(operator, left.(int64), right.(int64))
+	case token.PLUS:
+		if isNumber(left) && isNumber(right) {
+			return arithmetic(operator, left, right)
+		}
+		// 如果任一操作数是字符串，则将另一个操作数也转换为字符串
+		if isString(left) || isString(right) {
+			return Stringify(left) + Stringify(right)
+		}
+		panic(error.RuntimeError{Token: operator, Message: "'+'运算符只能用于数字或字符串。"})
+	case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		checkNumberOperands(operator, left, right)
+		return compare(operator.Type, left, right)
+	case token.BANG_EQUAL:
+		return !IsEqual(left, right)
+	case token.EQUAL_EQUAL:
+		return IsEqual(left, right)
+	case token.COMMA:
+		// 逗号表达式，返回右侧值
+		return right
+	}
+
+	// 不可达
+	return nil
+}
+
+// arithmetic 计算两个数字的算术运算
+// 两个整数的运算结果为整数，除法向零取整，取模结果的符号与被除数相同；溢出时改用浮点数计算
+func arithmetic(operator *token.Token, left, right Value) Value {
+	x, xIsInt := left.(int64)
+	y, yIsInt := right.(int64)
+	if xIsInt && yIsInt {
+		switch operator.Type {
+		case token.PLUS:
+			if result, ok := AddInt(x, y); ok {
+				return result
+			}
+		case token.MINUS:
+			if result, ok := SubtractInt(x, y); ok {
+				return result
+			}
+		case token.STAR:
+			if result, ok := MultiplyInt(x, y); ok {
+				return result
+			}
+		case token.SLASH:
+			if y == 0 {
+				panic(error.RuntimeError{Token: operator, Message: "除数不能为零。"})
+			}
+			// math.MinInt64 / -1 溢出
+			if y != -1 || x != math.MinInt64 {
+				return x / y
+			}
+		case token.MODULO:
+			if y == 0 {
+				panic(error.RuntimeError{Token: operator, Message: "取模运算符的右操作数不能为零。"})
+			}
+			if y == -1 {
+				return int64(0)
+			}
+			return x % y
+		}
+	}
+
+	a, _ := ToFloat(left)
+	b, _ := ToFloat(right)
+	switch operator.Type {
+	case token.PLUS:
+		return a + b
+	case token.MINUS:
+		return a - b
+	case token.STAR:
+		return a * b
+	case token.SLASH:
+		if b == 0 {
+			panic(error.RuntimeError{Token: operator, Message: "除数不能为零。"})
+		}
+		return a / b
+	case token.MODULO:
+		if b == 0 {
+			panic(error.RuntimeError{Token: operator, Message: "取模运算符的右操作数不能为零。"})
+		}
+		return math.Mod(a, b)
+	}
+
+	// 不可达
+	return nil
+}
+
+// power 计算乘方，整数的非负整数次幂为整数(溢出时提升为浮点数)，其余情况为浮点数
+func power(base, exponent Value) Value {
+	x, xIsInt := base.(int64)
+	n, nIsInt := exponent.(int64)
+	if xIsInt && nIsInt && n >= 0 {
+		if result, ok := powerInt(x, n); ok {
+			return result
+		}
+	}
+
+	a, _ := ToFloat(base)
+	b, _ := ToFloat(exponent)
+	return math.Pow(a, b)
+}
+
+// powerInt 用快速幂计算整数的非负整数次幂，溢出时ok为false
+func powerInt(x, n int64) (int64, bool) {
+	result := int64(1)
+	for n > 0 {
+		var ok bool
+		if n&1 == 1 {
+			if result, ok = MultiplyInt(result, x); !ok {
+				return 0, false
+			}
+		}
+		n >>= 1
+		if n > 0 {
+			if x, ok = MultiplyInt(x, x); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// bitwise 计算两个整数的位运算，右移为算术右移
+func bitwise(operator *token.Token, x, y int64) Value {
+	switch operator.Type {
+	case token.AMPERSAND:
+		return x & y
+	case token.PIPE:
+		return x | y
+	case token.CARET:
+		return x ^ y
+	}
+
+	if y < 0 {
+		panic(error.RuntimeError{Token: operator, Message: "移位的位数不能为负数。"})
+	}
+	if operator.Type == token.LESS_LESS {
+		return x << uint64(y)
+	}
+	return x >> uint64(y)
+}
+
+// compare 按数值比较两个数字
+func compare(operator token.TokenType, left, right Value) bool {
+	x, xIsInt := left.(int64)
+	y, yIsInt := right.(int64)
+	if xIsInt && yIsInt {
+		switch operator {
+		case token.GREATER:
+			return x > y
+		case token.GREATER_EQUAL:
+			return x >= y
+		case token.LESS:
+			return x < y
+		case token.LESS_EQUAL:
+			return x <= y
+		}
+	}
+
+	a, _ := ToFloat(left)
+	b, _ := ToFloat(right)
+	switch operator {
+	case token.GREATER:
+		return a > b
+	case token.GREATER_EQUAL:
+		return a >= b
+	case token.LESS:
+		return a < b
+	case token.LESS_EQUAL:
+		return a <= b
+	}
+
+	// 不可达
+	return false
+}
+
+// isString 判断一个值是否为字符串
+func isString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+// checkNumberOperand 检查一元运算符的操作数是否为数字
+func checkNumberOperand(operator *token.Token, operand interface{}) {
+	if isNumber(operand) {
+		return
+	}
+	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+}
+
+// checkIntegerOperand 检查位运算的操作数是否为整数
+func checkIntegerOperand(operator *token.Token, operand interface{}) {
+	if _, ok := operand.(int64); ok {
+		return
+	}
+	panic(error.RuntimeError{Token: operator, Message: "位运算的操作数必须是整数。"})
+}
+
+// checkIntegerOperands 检查位运算的两个操作数是否为整数
+func checkIntegerOperands(operator *token.Token, left, right interface{}) {
+	_, leftIsInt := left.(int64)
+	_, rightIsInt := right.(int64)
+	if leftIsInt && rightIsInt {
+		return
+	}
+	panic(error.RuntimeError{Token: operator, Message: "位运算的操作数必须是整数。"})
+}
+
+// checkNumberOperands 检查二元运算符的操作数是否为数字
+func checkNumberOperands(operator *token.Token, left, right interface{}) {
+	if isNumber(left) && isNumber(right) {
+		return
+	}
+	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+}
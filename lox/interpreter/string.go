@@ -0,0 +1,157 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// StringMethod 返回绑定到字符串s的内置方法，所有执行后端共享同一实现
+// 长度和下标都按Unicode字符计算，一个汉字算作一个字符
+func StringMethod(s string, name *token.Token) Value {
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction("len", 0, func(args []Value) (Value, error) {
+			return int64(utf8.RuneCountInString(s)), nil
+		})
+	case "substr":
+		return NewNativeFunction("substr", 2, func(args []Value) (Value, error) {
+			runes := []rune(s)
+			start, err := stringIndex(args[0], len(runes)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := stringIndex(args[1], len(runes)+1)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, errors.New("子串的起始位置不能大于结束位置。")
+			}
+			return string(runes[start:end]), nil
+		})
+	case "indexOf":
+		return NewNativeFunction("indexOf", 1, func(args []Value) (Value, error) {
+			sub, err := stringArgument("indexOf", args[0])
+			if err != nil {
+				return nil, err
+			}
+			index := strings.Index(s, sub)
+			if index < 0 {
+				return int64(-1), nil
+			}
+			return int64(utf8.RuneCountInString(s[:index])), nil
+		})
+	case "split":
+		return NewNativeFunction("split", 1, func(args []Value) (Value, error) {
+			separator, err := stringArgument("split", args[0])
+			if err != nil {
+				return nil, err
+			}
+			// 分隔符为空字符串时拆分为单个字符
+			parts := strings.Split(s, separator)
+			elements := make([]Value, len(parts))
+			for i, part := range parts {
+				elements[i] = part
+			}
+			return NewList(elements), nil
+		})
+	case "join":
+		return NewNativeFunction("join", 1, func(args []Value) (Value, error) {
+			list, ok := args[0].(*List)
+			if !ok {
+				return nil, errors.New("'join'的参数必须是列表。")
+			}
+			// 列表元素按print的格式转换为字符串
+			parts := make([]string, len(list.Elements))
+			for i, element := range list.Elements {
+				parts[i] = Stringify(element)
+			}
+			return strings.Join(parts, s), nil
+		})
+	case "replace":
+		return NewNativeFunction("replace", 2, func(args []Value) (Value, error) {
+			old, err := stringArgument("replace", args[0])
+			if err != nil {
+				return nil, err
+			}
+			replacement, err := stringArgument("replace", args[1])
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, old, replacement), nil
+		})
+	case "upper":
+		return NewNativeFunction("upper", 0, func(args []Value) (Value, error) {
+			return strings.ToUpper(s), nil
+		})
+	case "lower":
+		return NewNativeFunction("lower", 0, func(args []Value) (Value, error) {
+			return strings.ToLower(s), nil
+		})
+	case "trim":
+		return NewNativeFunction("trim", 0, func(args []Value) (Value, error) {
+			return strings.TrimSpace(s), nil
+		})
+	case "startsWith":
+		return NewNativeFunction("startsWith", 1, func(args []Value) (Value, error) {
+			prefix, err := stringArgument("startsWith", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return strings.HasPrefix(s, prefix), nil
+		})
+	case "endsWith":
+		return NewNativeFunction("endsWith", 1, func(args []Value) (Value, error) {
+			suffix, err := stringArgument("endsWith", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return strings.HasSuffix(s, suffix), nil
+		})
+	case "repeat":
+		return NewNativeFunction("repeat", 1, func(args []Value) (Value, error) {
+			count, ok := ToInt(args[0])
+			if !ok || count < 0 {
+				return nil, errors.New("重复次数必须是非负整数。")
+			}
+			if count > 0 && int64(len(s))*count > maxStringLength {
+				return nil, errors.New("重复后的字符串过长。")
+			}
+			return strings.Repeat(s, int(count)), nil
+		})
+	}
+
+	panic(errorp.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
+
+// maxStringLength repeat生成的字符串的最大字节数，避免脚本耗尽宿主进程的内存
+const maxStringLength = 1 << 30
+
+// stringIndex 将下标转换为[0, limit)范围内的整数，整数值的浮点数也可以作为下标
+func stringIndex(index Value, limit int) (int, error) {
+	n, ok := ToInt(index)
+	if !ok {
+		return 0, errors.New("字符串下标必须是整数。")
+	}
+	if n < 0 || n >= int64(limit) {
+		return 0, errors.New("字符串下标越界。")
+	}
+	return int(n), nil
+}
+
+// stringArgument 检查方法method的参数是否为字符串
+func stringArgument(method string, arg Value) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("'%s'的参数必须是字符串。", method)
+	}
+	return s, nil
+}
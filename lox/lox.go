@@ -2,33 +2,124 @@ package lox
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/compiler"
 	errorp "github.com/aixiasang/goLox/lox/error"
 	"github.com/aixiasang/goLox/lox/interpreter"
 	"github.com/aixiasang/goLox/lox/parser"
 	"github.com/aixiasang/goLox/lox/resolver"
 	"github.com/aixiasang/goLox/lox/scanner"
+	"github.com/aixiasang/goLox/lox/vm"
 )
 
+// Backend 执行后端类型
+type Backend string
+
+const (
+	// BackendTree 基于名称查找环境的树遍历解释器
+	BackendTree Backend = "tree"
+	// BackendIndexed 基于数组索引环境的树遍历解释器
+	BackendIndexed Backend = "indexed"
+	// BackendVM 字节码编译器和栈式虚拟机
+	BackendVM Backend = "vm"
+)
+
+// ParseBackend 将字符串解析为执行后端
+func ParseBackend(name string) (Backend, error) {
+	switch Backend(name) {
+	case BackendTree, BackendIndexed, BackendVM:
+		return Backend(name), nil
+	}
+	return "", fmt.Errorf("未知的执行后端: %s", name)
+}
+
+// Options Lox解释器的配置选项
+type Options struct {
+	Backend Backend // 执行后端，为空时使用BackendTree
+	Debug   bool    // 调试模式标志
+
+	// MaxCallDepth 最大调用深度，超过时抛出栈溢出的运行时错误，为0时使用interpreter.DefaultMaxCallDepth
+	MaxCallDepth int
+
+	Stdin  io.Reader // REPL的输入，为nil时使用os.Stdin
+	Stdout io.Writer // print语句和REPL提示的输出，为nil时使用os.Stdout
+	Stderr io.Writer // 诊断信息的输出，为nil时使用os.Stderr
+}
+
 // Lox 解释器的主结构
 type Lox struct {
-	errorReporter errorp.Reporter
-	interpreter   *interpreter.Interpreter
-	debug         bool // 调试模式标志
+	errorReporter *errorp.ErrorReporter
+	backend       Backend                      // 当前使用的执行后端
+	interpreter   *interpreter.Interpreter     // 树遍历解释器
+	indexed       *resolver.IndexedInterpreter // 索引优化解释器
+	vm            *vm.VM                       // 字节码虚拟机
+	debug         bool                         // 调试模式标志
+	stdin         io.Reader                    // REPL的输入
+	stdout        io.Writer                    // 标准输出
+
+	dir     string                         // 主脚本所在的目录，导入的模块相对于它显示文件名
+	loading []string                       // 主脚本和正在加载的模块的绝对路径，用于检测循环导入
+	modules map[string]*interpreter.Module // 已执行的模块，键为绝对路径
 }
 
-// NewLox 创建一个新的Lox解释器实例
+// NewLox 使用默认选项创建一个新的Lox解释器实例
 func NewLox() *Lox {
+	return New(Options{})
+}
+
+// New 根据选项创建一个新的Lox解释器实例
+func New(opts Options) *Lox {
 	errorReporter := errorp.NewErrorReporter()
-	interpreter := interpreter.NewInterpreter(errorReporter)
 
-	return &Lox{
+	backend := opts.Backend
+	if backend == "" {
+		backend = BackendTree
+	}
+
+	stdin, stdout, stderr := opts.Stdin, opts.Stdout, opts.Stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr != nil {
+		errorReporter.SetOutput(stderr)
+	}
+
+	l := &Lox{
 		errorReporter: errorReporter,
-		interpreter:   interpreter,
-		debug:         false, // 默认关闭调试模式
+		backend:       backend,
+		debug:         opts.Debug,
+		stdin:         stdin,
+		stdout:        stdout,
+		modules:       make(map[string]*interpreter.Module),
+	}
+
+	switch backend {
+	case BackendIndexed:
+		l.indexed = resolver.NewIndexedInterpreter(errorReporter)
+		l.indexed.SetOutput(stdout)
+		l.indexed.SetMaxCallDepth(opts.MaxCallDepth)
+		l.indexed.SetImporter(l.importModule)
+	case BackendVM:
+		l.vm = vm.NewVM(errorReporter)
+		l.vm.SetOutput(stdout)
+		l.vm.SetMaxCallDepth(opts.MaxCallDepth)
+		l.vm.SetImporter(l.importModule)
+	default:
+		l.interpreter = interpreter.NewInterpreter(errorReporter)
+		l.interpreter.SetOutput(stdout)
+		l.interpreter.SetMaxCallDepth(opts.MaxCallDepth)
+		l.interpreter.SetImporter(l.importModule)
 	}
+
+	return l
 }
 
 // SetDebug 设置调试模式
@@ -36,73 +127,185 @@ func (l *Lox) SetDebug(debug bool) {
 	l.debug = debug
 }
 
-// Run 执行给定的源代码
+// Value Lox运行时的值
+type Value = interpreter.Value
+
+// Run 执行给定的源代码，错误会报告给诊断信息的输出目标
 func (l *Lox) Run(source string) {
+	l.run(source)
+}
+
+// Eval 执行给定的源代码，返回最后一条表达式语句的值
+// 出错时返回*ScanError、*ParseError、*ResolveError或*RuntimeError；
+// ctx被取消时，正在执行的脚本会在下一次循环或函数调用处以RuntimeError终止；
+// 没有通过RunFile执行脚本文件时，导入的模块相对于当前目录查找
+func (l *Lox) Eval(ctx context.Context, source string) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	l.setContext(ctx)
+	defer l.setContext(nil)
+
+	value := l.run(source)
+	if err := diagnosticsError(l.errorReporter.Diagnostics()); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// ToFloat 将Lox数字(int64或float64)转换为float64，value不是数字时ok为false
+// Go函数可以用它接受任意类型的数字参数
+func ToFloat(value Value) (f float64, ok bool) {
+	return interpreter.ToFloat(value)
+}
+
+// Variadic 作为DefineNative的参数数量时表示接受任意数量的参数
+const Variadic = interpreter.Variadic
+
+// DefineNative 注册一个可在脚本中调用的Go函数
+// fn返回的错误会成为调用位置的运行时错误；返回的Go整数、float32和[]Value转换为Lox的整数、浮点数和列表，其他非Lox类型的值也是运行时错误
+func (l *Lox) DefineNative(name string, arity int, fn func(args []Value) (Value, error)) {
+	switch l.backend {
+	case BackendIndexed:
+		l.indexed.DefineNative(name, arity, fn)
+	case BackendVM:
+		l.vm.DefineNative(name, arity, fn)
+	default:
+		l.interpreter.DefineNative(name, arity, fn)
+	}
+}
+
+// setContext 为当前执行后端设置用于取消执行的上下文
+func (l *Lox) setContext(ctx context.Context) {
+	switch l.backend {
+	case BackendIndexed:
+		l.indexed.SetContext(ctx)
+	case BackendVM:
+		l.vm.SetContext(ctx)
+	default:
+		l.interpreter.SetContext(ctx)
+	}
+}
+
+// run 扫描、解析并执行源代码，返回最后一条表达式语句的值
+func (l *Lox) run(source string) Value {
 	// 重置错误状态
 	l.errorReporter.ResetError()
 
 	// 扫描标记
+	l.errorReporter.SetKind(errorp.KindScan)
+	l.errorReporter.SetSource(source)
 	s := scanner.NewScanner(source, l.errorReporter)
 	// 设置scanner的调试模式
 	s.SetDebug(l.debug)
+	s.SetDebugOutput(l.stdout)
 	tokens := s.ScanTokens()
 
 	// 解析语句
+	l.errorReporter.SetKind(errorp.KindParse)
 	p := parser.NewParser(tokens, l.errorReporter)
 	// 设置解析器的调试模式
 	p.SetDebug(l.debug)
+	p.SetDebugOutput(l.stdout)
 	statements := p.Parse()
 
 	// 如果有语法错误,停止解释
 	if l.errorReporter.HasError() {
-		return
+		return nil
 	}
 
+	l.errorReporter.SetKind(errorp.KindResolve)
+	switch l.backend {
+	case BackendIndexed:
+		return l.runIndexed(statements)
+	case BackendVM:
+		return l.runVM(statements)
+	default:
+		return l.runTree(statements)
+	}
+}
+
+// runTree 使用树遍历解释器执行语句
+func (l *Lox) runTree(statements []ast.Stmt) Value {
 	// 变量解析
 	r := resolver.NewResolver(l.interpreter, l.errorReporter)
 	r.Resolve(statements)
 
 	// 如果解析过程中有错误,停止解释
 	if l.errorReporter.HasError() {
-		return
+		return nil
 	}
 
 	// 解释执行语句
-	l.interpreter.Interpret(statements)
+	return l.interpreter.Interpret(statements)
 }
 
-// RunFile 从文件中读取并执行源代码
-func (l *Lox) RunFile(path string) error {
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return err
+// runIndexed 使用索引优化解释器执行语句
+func (l *Lox) runIndexed(statements []ast.Stmt) Value {
+	// 变量解析，计算每个局部变量的(深度，索引)
+	r := resolver.NewOptimizedResolver(l.errorReporter)
+	locations := r.ResolveStatements(statements)
+
+	// 如果解析过程中有错误,停止解释
+	if l.errorReporter.HasError() {
+		return nil
 	}
-	l.Run(string(bytes))
 
-	// 如果有语法错误，返回错误状态
+	// 解释执行语句
+	l.indexed.SetLocations(locations)
+	return l.indexed.Interpret(statements)
+}
+
+// runVM 将语句编译为字节码并在虚拟机中执行
+func (l *Lox) runVM(statements []ast.Stmt) Value {
+	// 静态检查与其他后端保持一致，局部变量位置由编译器自行计算
+	r := resolver.NewOptimizedResolver(l.errorReporter)
+	r.ResolveStatements(statements)
+
+	// 如果解析过程中有错误,停止解释
 	if l.errorReporter.HasError() {
-		os.Exit(65)
+		return nil
 	}
 
-	// 如果有运行时错误,返回运行时错误状态
-	if l.errorReporter.HasRuntimeError() {
-		os.Exit(70)
+	function := compiler.NewCompiler(l.errorReporter).Compile(statements)
+	if function == nil {
+		return nil
 	}
 
-	return nil
+	if l.debug {
+		fmt.Fprint(l.stdout, compiler.Disassemble(function))
+	}
+
+	return l.vm.Interpret(function)
+}
+
+// RunFile 从文件中读取并执行源代码，脚本中导入的模块相对于脚本所在的目录查找
+// 脚本出错时返回与Eval相同类型的错误，由调用者决定如何退出进程
+func (l *Lox) RunFile(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := l.setMainFile(path); err != nil {
+		return err
+	}
+
+	_, err = l.Eval(context.Background(), string(bytes))
+	return err
 }
 
 // RunPrompt 提供一个交互式的REPL环境
 func (l *Lox) RunPrompt() error {
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(l.stdin)
 
 	for {
-		fmt.Print("> ")
+		fmt.Fprint(l.stdout, "> ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			// 如果是EOF,优雅退出
 			if err.Error() == "EOF" {
-				fmt.Println("再见!")
+				fmt.Fprintln(l.stdout, "再见!")
 				return nil
 			}
 			return err
@@ -121,6 +324,16 @@ func (l *Lox) RunPrompt() error {
 	return nil
 }
 
+// Diagnostics 返回最近一次Run产生的诊断信息
+func (l *Lox) Diagnostics() []errorp.Diagnostic {
+	return l.errorReporter.Diagnostics()
+}
+
+// SetDiagnosticSinks 替换诊断信息的输出目标，不传参数时不输出任何内容
+func (l *Lox) SetDiagnosticSinks(sinks ...errorp.Sink) {
+	l.errorReporter.SetSinks(sinks...)
+}
+
 // Error 报告错误
 func (l *Lox) Error(line int, message string) {
 	l.errorReporter.ReportError(line, message)
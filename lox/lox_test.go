@@ -0,0 +1,1039 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	errorp "github.com/aixiasang/goLox/lox/error"
+)
+
+var update = flag.Bool("update", false, "用当前输出更新golden文件")
+
+// runWithBackend 使用指定后端执行脚本文件并返回全部输出，标准输出和诊断信息按写入顺序交错
+// 脚本中导入的模块相对于脚本所在的目录查找
+func runWithBackend(t *testing.T, backend Backend, file string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	l := New(Options{Backend: backend, Stdout: &out, Stderr: &out})
+	// 固定clock的返回值，使输出可以比较
+	l.DefineNative("clock", 0, func(args []Value) (Value, error) {
+		return 1700000000.0, nil
+	})
+	// 脚本中的错误已经写入输出
+	_ = l.RunFile(file)
+	return out.String()
+}
+
+// exampleScripts 返回所有示例脚本
+func exampleScripts(t *testing.T) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("..", "example", "*.lox"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("未找到示例脚本: %v", err)
+	}
+	return files
+}
+
+// 测试所有示例脚本在不同后端下输出一致
+func TestBackendsProduceSameOutput(t *testing.T) {
+	for _, file := range exampleScripts(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			expected := runWithBackend(t, BackendTree, file)
+			for _, backend := range []Backend{BackendIndexed, BackendVM} {
+				actual := runWithBackend(t, backend, file)
+				if actual != expected {
+					t.Errorf("%s后端输出与tree后端不一致。\n期望:\n%s\n实际:\n%s", backend, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+// 测试示例脚本的输出与testdata中的golden文件一致
+// 使用 go test ./lox -run TestGolden -update 更新golden文件
+func TestGolden(t *testing.T) {
+	for _, file := range exampleScripts(t) {
+		name := strings.TrimSuffix(filepath.Base(file), ".lox")
+		t.Run(name, func(t *testing.T) {
+			actual := runWithBackend(t, BackendTree, file)
+			golden := filepath.Join("testdata", name+".golden")
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(actual), 0644); err != nil {
+					t.Fatalf("写入golden文件失败: %v", err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("读取golden文件失败: %v", err)
+			}
+			if actual != string(expected) {
+				t.Errorf("输出与%s不一致。\n期望:\n%s\n实际:\n%s", golden, expected, actual)
+			}
+		})
+	}
+}
+
+// 测试REPL从配置的输入读取并写入配置的输出
+func TestRunPromptStreams(t *testing.T) {
+	var out bytes.Buffer
+	l := New(Options{
+		Stdin:  strings.NewReader("var a = 1;\nprint a + 1;\nprint b;\nprint a;\n"),
+		Stdout: &out,
+		Stderr: &out,
+	})
+
+	if err := l.RunPrompt(); err != nil {
+		t.Fatalf("REPL出错: %v", err)
+	}
+
+	expected := "> > 2\n> [行 1] 错误 在 'b': 未定义的变量 'b'。\n1 | print b;\n  |       ^\n> 1\n> 再见!\n"
+	if out.String() != expected {
+		t.Errorf("期望输出:\n%q\n实际:\n%q", expected, out.String())
+	}
+}
+
+// 测试调试模式下扫描器、解析器的跟踪信息和字节码反汇编都写入配置的输出
+func TestDebugOutput(t *testing.T) {
+	var out bytes.Buffer
+	l := New(Options{Backend: BackendVM, Debug: true, Stdout: &out})
+	if _, err := l.Eval(context.Background(), "fun max(a, b) { return a > b ? a : b; }\nprint max(1, 2);"); err != nil {
+		t.Fatalf("执行出错: %v", err)
+	}
+
+	for _, expected := range []string{"发现逗号标记", "参数解析完成，共 2 个参数", "== <script> ==", "2\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("输出中缺少 %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	for _, name := range []string{"tree", "indexed", "vm"} {
+		if backend, err := ParseBackend(name); err != nil || string(backend) != name {
+			t.Errorf("ParseBackend(%q) = %q, %v", name, backend, err)
+		}
+	}
+
+	if _, err := ParseBackend("jit"); err == nil {
+		t.Errorf("期望未知后端报错")
+	}
+}
+
+// 测试多个实例的错误状态互不影响，且诊断信息可在Run之后获取
+func TestDiagnosticsPerInstance(t *testing.T) {
+	failing := New(Options{})
+	failing.SetDiagnosticSinks()
+	healthy := New(Options{})
+	healthy.SetDiagnosticSinks()
+
+	failing.Run("print 1 +;")
+	healthy.Run("1;")
+
+	diagnostics := failing.Diagnostics()
+	if len(diagnostics) == 0 || diagnostics[0].Kind != errorp.KindParse {
+		t.Errorf("期望语法错误诊断，实际: %v", diagnostics)
+	}
+	if len(healthy.Diagnostics()) != 0 {
+		t.Errorf("正常实例不应有诊断信息，实际: %v", healthy.Diagnostics())
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		l := New(Options{Backend: backend})
+		l.SetDiagnosticSinks()
+		l.Run("print nope;")
+
+		diagnostics := l.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Kind != errorp.KindRuntime || diagnostics[0].Line != 1 {
+			t.Errorf("%s后端期望一条运行时错误诊断，实际: %v", backend, diagnostics)
+		}
+	}
+}
+
+// 测试Eval返回最后一条表达式语句的值和类型化的错误
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected Value
+		err      interface{}
+	}{
+		{"表达式的值", "var a = 1; a + 2;", int64(3), nil},
+		{"函数调用的值", "fun f(x) { return x * 2; } f(21);", int64(42), nil},
+		{"浮点数", "7 / 2.0;", 3.5, nil},
+		{"整数除法", "7 / 2;", int64(3), nil},
+		{"字符串", `"go" + "lox";`, "golox", nil},
+		{"最后一条不是表达式", "var a = 1;", nil, nil},
+		{"词法错误", "var a = @;", nil, &ScanError{}},
+		{"语法错误", "var a = ;", nil, &ParseError{}},
+		{"静态检查错误", "return 1;", nil, &ResolveError{}},
+		{"运行时错误", "1 - \"a\";", nil, &RuntimeError{}},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+
+				value, err := l.Eval(context.Background(), tt.source)
+				if tt.err == nil {
+					if err != nil {
+						t.Fatalf("意外的错误: %v", err)
+					}
+					if value != tt.expected {
+						t.Errorf("期望值 %v，实际: %v", tt.expected, value)
+					}
+					return
+				}
+
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+					t.Errorf("期望错误类型 %T，实际: %T (%v)", tt.err, err, err)
+				}
+			})
+		}
+	}
+}
+
+// 测试上下文取消可以终止死循环
+func TestEvalCancellation(t *testing.T) {
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		t.Run(string(backend), func(t *testing.T) {
+			l := New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := l.Eval(ctx, "while (true) {}")
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("期望运行时错误，实际: %v", err)
+			}
+
+			// 取消后实例仍然可用
+			if value, err := l.Eval(context.Background(), "1 + 1;"); err != nil || value != int64(2) {
+				t.Errorf("期望2，实际: %v, %v", value, err)
+			}
+		})
+	}
+}
+
+// 测试宿主注册的Go函数在所有后端中行为一致，返回值转换为Lox的值
+func TestDefineNative(t *testing.T) {
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		t.Run(string(backend), func(t *testing.T) {
+			l := New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+
+			l.DefineNative("sum", Variadic, func(args []Value) (Value, error) {
+				total := 0.0
+				for _, arg := range args {
+					n, ok := ToFloat(arg)
+					if !ok {
+						return nil, fmt.Errorf("sum只接受数字")
+					}
+					total += n
+				}
+				return total, nil
+			})
+
+			value, err := l.Eval(context.Background(), "sum(1, 2, 3) + sum();")
+			if err != nil || value != 6.0 {
+				t.Fatalf("期望6，实际: %v, %v", value, err)
+			}
+
+			_, err = l.Eval(context.Background(), "\nsum(1, \"a\");")
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("期望运行时错误，实际: %v", err)
+			}
+			if runtimeError.Message != "sum只接受数字" || runtimeError.Line != 2 || runtimeError.Column != 11 || runtimeError.Token.Lexeme != ")" {
+				t.Errorf("运行时错误不正确: %+v", runtimeError)
+			}
+		})
+	}
+
+	// Go函数返回的值转换为Lox的值，无法转换的类型报告为运行时错误
+	results := map[string]Value{
+		"goInt":     5,
+		"goInt32":   int32(-3),
+		"goUint":    uint(7),
+		"goUint64":  uint64(math.MaxUint64),
+		"goFloat32": float32(1.5),
+		"goSlice":   []Value{1, "a", []Value{uint8(2)}},
+		"goStruct":  struct{}{},
+		"goMap":     map[string]int{},
+		"goNested":  []Value{1, []int{2}},
+	}
+	tests := []struct {
+		source   string
+		expected Value
+		message  string
+	}{
+		{"goInt() + 1;", int64(6), ""},
+		{"goInt() == 5;", true, ""},
+		{"goInt32() * 2;", int64(-6), ""},
+		{"goUint() % 4;", int64(3), ""},
+		{"goUint64() > 0;", true, ""},
+		{"goFloat32() * 2;", 3.0, ""},
+		{"goSlice().len();", int64(3), ""},
+		{"goSlice()[2][0] + goSlice()[0];", int64(3), ""},
+		{"var m = {}; m[goSlice()[1]] = 1; m.len();", int64(1), ""},
+		{"\ngoStruct();", nil, "内置函数返回了Lox不支持的类型 'struct {}'。"},
+		{"var m = {};\nm[goMap()] = 1;", nil, "内置函数返回了Lox不支持的类型 'map[string]int'。"},
+		{"\ngoNested();", nil, "内置函数返回了Lox不支持的类型 '[]interface {}'。"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.source, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+				for name, result := range results {
+					l.DefineNative(name, 0, func(args []Value) (Value, error) {
+						return result, nil
+					})
+				}
+
+				value, err := l.Eval(context.Background(), tt.source)
+				if tt.message == "" {
+					if err != nil || value != tt.expected {
+						t.Errorf("期望 %v，实际: %v, %v", tt.expected, value, err)
+					}
+					return
+				}
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) {
+					t.Fatalf("期望运行时错误，实际: %v", err)
+				}
+				if runtimeError.Message != tt.message || runtimeError.Line != 2 || runtimeError.Token.Lexeme != ")" {
+					t.Errorf("运行时错误不正确: %+v", runtimeError)
+				}
+			})
+		}
+	}
+}
+
+// expectRuntimeError 在后端backend中执行source，检查它在第2行的lexeme处抛出消息为message的运行时错误
+func expectRuntimeError(t *testing.T, backend Backend, source, message, lexeme string) {
+	t.Helper()
+	l := New(Options{Backend: backend})
+	l.SetDiagnosticSinks()
+
+	_, err := l.Eval(context.Background(), source)
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("期望运行时错误，实际: %v", err)
+	}
+	if runtimeError.Message != message || runtimeError.Line != 2 || runtimeError.Token.Lexeme != lexeme {
+		t.Errorf("运行时错误不正确: %+v", runtimeError)
+	}
+}
+
+// 测试列表和映射的运行时错误在所有后端中都指向正确的标记
+func TestCollectionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		lexeme  string
+	}{
+		{"下标越界", "var xs = [1];\nxs[1];", "列表下标越界。", "["},
+		{"负数下标", "var xs = [1];\nxs[-1] = 2;", "列表下标越界。", "["},
+		{"非整数下标", "var xs = [1];\nxs[0.5];", "列表下标必须是整数。", "["},
+		{"非列表", "var s = \"abc\";\ns[0];", "只能对列表或映射使用下标。", "["},
+		{"空列表弹出", "var xs = [];\nxs.pop();", "不能从空列表中弹出元素。", ")"},
+		{"未定义的方法", "var xs = [];\nxs.nope();", "未定义的属性 'nope'。", "nope"},
+		{"映射中不存在的键", "var m = {\"a\": 1};\nm[\"b\"];", "映射中不存在键 'b'。", "["},
+		{"映射的未定义方法", "var m = {};\nm.push(1);", "未定义的属性 'push'。", "push"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				expectRuntimeError(t, backend, tt.source, tt.message, tt.lexeme)
+			})
+		}
+	}
+}
+
+// 测试字符串方法的运行时错误在所有后端中都指向调用的右括号或方法名
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		lexeme  string
+	}{
+		{"子串下标越界", "var s = \"你好\";\ns.substr(0, 3);", "字符串下标越界。", ")"},
+		{"子串起止颠倒", "var s = \"abc\";\ns.substr(2, 1);", "子串的起始位置不能大于结束位置。", ")"},
+		{"字符串方法的参数类型", "var s = \"abc\";\ns.split(1);", "'split'的参数必须是字符串。", ")"},
+		{"负数重复次数", "var s = \"abc\";\ns.repeat(-1);", "重复次数必须是非负整数。", ")"},
+		{"字符串的未定义方法", "var s = \"abc\";\ns.push(1);", "未定义的属性 'push'。", "push"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				expectRuntimeError(t, backend, tt.source, tt.message, tt.lexeme)
+			})
+		}
+	}
+}
+
+// 测试同名的引用共享常量后，运行时错误仍指向出错的那一处引用而不是第一次出现的位置
+func TestRepeatedNameErrors(t *testing.T) {
+	const classes = "class A { f() {} } var a = A(); a.x = 1; a.x; a.f(); var n = nil;\n"
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		lexeme  string
+	}{
+		{"读取属性", classes + "n.x;", "只有实例才有属性。", "x"},
+		{"设置字段", classes + "n.x = 2;", "只有实例才有字段。", "x"},
+		{"调用方法", classes + "n.f();", "只有实例才有属性。", "f"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				expectRuntimeError(t, backend, tt.source, tt.message, tt.lexeme)
+			})
+		}
+	}
+}
+
+// 测试math模块的函数对非数字参数抛出可以捕获的运行时错误
+func TestMathErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		lexeme  string
+	}{
+		{"非数字参数", "var x = \"4\";\nmath.sqrt(x);", "'sqrt'的参数必须是数字。", ")"},
+		{"第二个参数不是数字", "var x = nil;\nmath.pow(2, x);", "'pow'的参数必须是数字。", ")"},
+		{"取整的参数", "var x = true;\nmath.floor(x);", "'floor'的参数必须是数字。", ")"},
+		{"最大值的参数", "var x = [1];\nmath.max(1, x);", "'max'的参数必须是数字。", ")"},
+		{"没有参数的最小值", "var x = 1;\nmath.min();", "'min'至少需要一个参数。", ")"},
+		{"非整数种子", "var x = 1.5;\nmath.seed(x);", "'seed'的参数必须是整数。", ")"},
+		{"未导出的名称", "var x = 1;\nmath.nope(x);", "模块 'math' 没有导出 'nope'。", "nope"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				expectRuntimeError(t, backend, tt.source, tt.message, tt.lexeme)
+			})
+		}
+	}
+}
+
+// 测试参数个数错误在所有后端中给出相同的可接受范围
+func TestArityErrors(t *testing.T) {
+	const source = `
+fun fixed(a, b) { return a + b; }
+fun optional(a, b = 1, c = 2) { return a + b + c; }
+fun variadic(a, ...rest) { return [a, rest]; }
+class Point { init(x, y = 0) { this.x = x; this.y = y; } }
+class Empty {}
+`
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"fixed(1);", "期望2个参数，但得到1个。"},
+		{"optional();", "期望1到3个参数，但得到0个。"},
+		{"optional(1, 2, 3, 4);", "期望1到3个参数，但得到4个。"},
+		{"variadic();", "期望至少1个参数，但得到0个。"},
+		{"Point(1, 2, 3);", "期望1到2个参数，但得到3个。"},
+		{"Empty(1);", "期望0个参数，但得到1个。"},
+		{"var f = (x = 1) => x; f(1, 2);", "期望0到1个参数，但得到2个。"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.source, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+				if _, err := l.Eval(context.Background(), source); err != nil {
+					t.Fatalf("意外的错误: %v", err)
+				}
+
+				_, err := l.Eval(context.Background(), tt.source)
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) {
+					t.Fatalf("期望运行时错误，实际: %v", err)
+				}
+				if runtimeError.Message != tt.message || runtimeError.Token.Lexeme != ")" {
+					t.Errorf("运行时错误不正确: %+v", runtimeError)
+				}
+			})
+		}
+	}
+}
+
+// 测试调用处的命名参数在所有后端中按参数名绑定
+func TestNamedArguments(t *testing.T) {
+	const source = `
+fun area(width, height = 1, scale = 1) { return width * height * scale; }
+fun tail(first, ...rest) { return [first, rest]; }
+class Point {
+  init(x, y = 0) { this.x = x; this.y = y; }
+  move(dx = 0, dy = 0) { return Point(y: this.y + dy, x: this.x + dx); }
+}
+class Empty {}
+`
+	tests := []struct {
+		source   string
+		expected Value
+		message  string
+		lexeme   string // 出错位置的词素
+	}{
+		{"area(2, scale: 3);", int64(6), "", ""},
+		{"area(height: 4, width: 2);", int64(8), "", ""},
+		{"tail(first: 1)[1].len();", int64(0), "", ""},
+		{"Point(y: 2, x: 1).y;", int64(2), "", ""},
+		{"Point(1).move(dy: 5).y;", int64(5), "", ""},
+		{"var f = (a, b = 2) => a - b; f(b: 1, a: 3);", int64(2), "", ""},
+		{"area(depth: 1);", nil, "没有名为 'depth' 的参数。", "depth"},
+		{"area(2, width: 3);", nil, "参数 'width' 已经按位置传入。", "width"},
+		{"area(height: 2);", nil, "缺少参数 'width'。", ")"},
+		{"tail(1, rest: 2);", nil, "没有名为 'rest' 的参数。", "rest"},
+		{"Empty(x: 1);", nil, "没有名为 'x' 的参数。", "x"},
+		{"clock(x: 1);", nil, "内置函数不接受命名参数。", ")"},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.source, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+				if _, err := l.Eval(context.Background(), source); err != nil {
+					t.Fatalf("意外的错误: %v", err)
+				}
+
+				value, err := l.Eval(context.Background(), tt.source)
+				if tt.message == "" {
+					if err != nil {
+						t.Fatalf("意外的错误: %v", err)
+					}
+					if value != tt.expected {
+						t.Errorf("期望值 %v，实际: %v", tt.expected, value)
+					}
+					return
+				}
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) {
+					t.Fatalf("期望运行时错误，实际: %v", err)
+				}
+				if runtimeError.Message != tt.message || runtimeError.Token.Lexeme != tt.lexeme {
+					t.Errorf("运行时错误不正确: %+v", runtimeError)
+				}
+			})
+		}
+	}
+}
+
+// 测试尾调用在所有后端中只占用固定的栈空间
+// 限制Go调用栈的大小后，没有尾调用优化的百万层递归会使进程崩溃
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected Value
+	}{
+		{"尾递归", `
+fun count(n, acc) {
+  if (n == 0) return acc;
+  return count(n - 1, acc + 1);
+}
+count(1000000, 0);`, int64(1000000)},
+		{"相互尾调用", `
+fun isEven(n) {
+  if (n == 0) return true;
+  return isOdd(n - 1);
+}
+fun isOdd(n) {
+  if (n == 0) return false;
+  return isEven(n - 1);
+}
+isEven(300001);`, false},
+		{"循环中的尾调用", `
+fun loop(n) {
+  while (true) {
+    if (n == 0) return "done";
+    return loop(n - 1);
+  }
+}
+loop(300000);`, "done"},
+		{"闭包捕获参数", `
+fun make(n, f) {
+  if (n == 0) return f;
+  fun g() { return n + f(); }
+  return make(n - 1, g);
+}
+make(3, clock)() > 6;`, true},
+		{"尾调用内置函数和类", `
+class Point { init(x) { this.x = x; } }
+fun build(x) { return Point(x); }
+fun size(xs) { return xs.len(); }
+build(size([1, 2, 3])).x;`, int64(3)},
+		{"方法尾递归", `
+class C {
+  m(n) {
+    if (n == 0) return "done";
+    return this.m(n - 1);
+  }
+}
+C().m(300000);`, "done"},
+		{"父类方法尾调用", `
+class A {
+  count(n) {
+    if (n == 0) return "done";
+    return this.count(n - 1);
+  }
+}
+class B < A {
+  count(n) { return super.count(n); }
+  down(n) {
+    if (n == 0) return this.count(300000);
+    return this.down(n - 1);
+  }
+}
+B().down(300000);`, "done"},
+		{"字段和绑定方法的尾调用", `
+class Counter {
+  init() {
+    this.step = (n) => {
+      if (n == 0) return "done";
+      return this.next(n);
+    };
+  }
+  next(n) {
+    var again = this.again;
+    return again(n - 1);
+  }
+  again(n) { return this.step(n); }
+}
+Counter().step(300000);`, "done"},
+		{"命名参数的尾调用", `
+fun count(n, acc = 0) {
+  if (n == 0) return acc;
+  return count(acc: acc + 1, n: n - 1);
+}
+count(n: 300000);`, int64(300000)},
+	}
+
+	previous := debug.SetMaxStack(16 << 20)
+	defer debug.SetMaxStack(previous)
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+
+				value, err := l.Eval(context.Background(), tt.source)
+				if err != nil {
+					t.Fatalf("意外的错误: %v", err)
+				}
+				if value != tt.expected {
+					t.Errorf("期望值 %v，实际: %v", tt.expected, value)
+				}
+			})
+		}
+	}
+}
+
+// 测试无限递归报告为栈溢出的运行时错误，而不是使宿主进程崩溃
+func TestCallDepthLimit(t *testing.T) {
+	const source = `
+fun depth(n) {
+  if (n == 0) return 0;
+  return 1 + depth(n - 1);
+}
+`
+	// 默认上限下的无限递归也不能耗尽Go调用栈
+	previous := debug.SetMaxStack(256 << 20)
+	defer debug.SetMaxStack(previous)
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		t.Run(string(backend), func(t *testing.T) {
+			l := New(Options{Backend: backend, MaxCallDepth: 50})
+			l.SetDiagnosticSinks()
+			if _, err := l.Eval(context.Background(), source); err != nil {
+				t.Fatalf("意外的错误: %v", err)
+			}
+
+			var runtimeError *RuntimeError
+			_, err := l.Eval(context.Background(), "depth(50);")
+			if !errors.As(err, &runtimeError) || !strings.Contains(runtimeError.Message, "stack overflow") {
+				t.Fatalf("期望栈溢出错误，实际: %v", err)
+			}
+			if runtimeError.Line != 4 {
+				t.Errorf("期望错误位于第4行，实际: %d", runtimeError.Line)
+			}
+
+			// 出错后调用深度恢复，实例仍然可用
+			if value, err := l.Eval(context.Background(), "depth(49);"); err != nil || value != int64(49) {
+				t.Errorf("期望49，实际: %v, %v", value, err)
+			}
+
+			l = New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+			_, err = l.Eval(context.Background(), "fun forever() { return 1 + forever(); } forever();")
+			if !errors.As(err, &runtimeError) || !strings.Contains(runtimeError.Message, "stack overflow") {
+				t.Errorf("期望栈溢出错误，实际: %v", err)
+			}
+		})
+	}
+}
+
+// 测试运行时错误在所有后端中附带相同的Lox调用栈
+func TestRuntimeErrorTrace(t *testing.T) {
+	const source = `
+fun inner(x) {
+  return x - "a";
+}
+fun outer(x) {
+  var y = inner(x);
+  return y;
+}
+class Box {
+  init(v) { this.v = outer(v); }
+}
+fun loop(n) {
+  if (n == 0) return outer(n);
+  return loop(n - 1);
+}
+`
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{"顶层错误", `1 - "a";`, nil},
+		{"嵌套调用", "Box(1);", []string{"[行 1] <script>", "[行 10] <fn init>", "[行 6] <fn outer>", "[行 3] <fn inner>"}},
+		{"匿名函数", "var f = (x) => {\n  var y = outer(x);\n  return y;\n};\nf(1);", []string{"[行 5] <script>", "[行 2] <fn (anonymous)>", "[行 6] <fn outer>", "[行 3] <fn inner>"}},
+		// 尾调用复用调用者的栈帧，loop不出现在调用栈中
+		{"尾调用", "loop(3);", []string{"[行 1] <script>", "[行 6] <fn outer>", "[行 3] <fn inner>"}},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				l := New(Options{Backend: backend})
+				l.SetDiagnosticSinks()
+				if _, err := l.Eval(context.Background(), source); err != nil {
+					t.Fatalf("意外的错误: %v", err)
+				}
+
+				_, err := l.Eval(context.Background(), tt.source)
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) {
+					t.Fatalf("期望运行时错误，实际: %v", err)
+				}
+
+				var frames []string
+				for _, frame := range runtimeError.Trace {
+					frames = append(frames, frame.String())
+				}
+				if !reflect.DeepEqual(frames, tt.expected) {
+					t.Errorf("期望调用栈 %v，实际: %v", tt.expected, frames)
+				}
+				if tt.expected != nil && !strings.HasSuffix(runtimeError.Traceback(), "[行 3] <fn inner>") {
+					t.Errorf("调用栈文本不正确: %s", runtimeError.Traceback())
+				}
+
+				// 出错后调用栈被清空
+				if _, err := l.Eval(context.Background(), `1 - "a";`); !errors.As(err, &runtimeError) || runtimeError.Trace != nil {
+					t.Errorf("期望不带调用栈的运行时错误，实际: %v", err)
+				}
+			})
+		}
+	}
+}
+
+// 测试异常处理在所有后端中行为一致
+func TestTryCatch(t *testing.T) {
+	const source = `
+fun inner(x) {
+  return x - "a";
+}
+fun outer(x) {
+  var y = inner(x);
+  return y;
+}
+fun forever() { return 1 + forever(); }
+fun caught() {
+  try {
+    return outer(1);
+  } catch (e) {
+    return e;
+  }
+}
+`
+	tests := []struct {
+		name     string
+		source   string
+		expected Value
+	}{
+		{"错误信息", "caught().message;", "操作数必须是数字。"},
+		{"错误行号", "caught().line;", int64(3)},
+		{"抛出的值", "var v; try { throw {\"k\": 1}; } catch (e) { v = e; } v[\"k\"];", int64(1)},
+		{"栈溢出可以捕获", "var r; try { forever(); } catch (e) { r = e.message; } r;", "栈溢出(stack overflow)：调用深度超过100。"},
+		{"finally覆盖返回值", "fun f() { try { return 1; } finally { return 2; } } f();", int64(2)},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				l := New(Options{Backend: backend, MaxCallDepth: 100})
+				l.SetDiagnosticSinks()
+				if _, err := l.Eval(context.Background(), source); err != nil {
+					t.Fatalf("意外的错误: %v", err)
+				}
+
+				value, err := l.Eval(context.Background(), tt.source)
+				if err != nil || value != tt.expected {
+					t.Fatalf("期望%v，实际: %v, %v", tt.expected, value, err)
+				}
+
+				// 捕获错误后调用栈恢复到try语句所在的深度
+				_, err = l.Eval(context.Background(), "outer(1);")
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) || len(runtimeError.Trace) != 3 {
+					t.Errorf("期望包含3帧调用栈的运行时错误，实际: %v", err)
+				}
+			})
+		}
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		t.Run(string(backend)+"/未捕获的异常", func(t *testing.T) {
+			l := New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+
+			_, err := l.Eval(context.Background(), "fun f() {\n  throw \"boom\";\n}\nf();")
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("期望运行时错误，实际: %v", err)
+			}
+			if runtimeError.Message != "未捕获的异常: boom" || runtimeError.Line != 2 || len(runtimeError.Trace) != 2 {
+				t.Errorf("运行时错误不正确: %+v", runtimeError)
+			}
+		})
+
+		t.Run(string(backend)+"/取消不能被捕获", func(t *testing.T) {
+			l := New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := l.Eval(ctx, "var caught = false; while (true) { try { while (true) {} } catch (e) { caught = true; } }")
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("期望运行时错误，实际: %v", err)
+			}
+			if value, err := l.Eval(context.Background(), "caught;"); err != nil || value != false {
+				t.Errorf("取消不应被catch捕获，实际: %v, %v", value, err)
+			}
+		})
+	}
+}
+
+// 测试模块的导入、导出、缓存和错误在所有后端中行为一致
+func TestModules(t *testing.T) {
+	modules := map[string]string{
+		"lib/math.lox": `
+import "util.lox" as util;
+print "loading math";
+var counter = 0;
+export fun square(x) { counter = counter + 1; return util.twice(x) * x / 2; }
+export fun calls() { return counter; }
+export class Point {
+  init(x, y) { this.x = x; this.y = y; }
+  norm() { return square(this.x) + square(this.y); }
+}
+fun hidden() {}
+`,
+		"lib/util.lox": "export fun twice(x) { return x * 2; }\nexport fun fail() { return 1 - \"a\"; }\n",
+		"lib/a.lox":    "import \"b.lox\" as b;\nexport var x = 1;\n",
+		"lib/b.lox":    "import \"a.lox\" as a;\n",
+		"lib/bad.lox":  "var x = ;\n",
+	}
+	dir := t.TempDir()
+	for name, source := range modules {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		output  string
+		message string // 期望的错误信息，为空时期望执行成功
+		file    string // 期望出错的文件
+	}{
+		{
+			name: "导出与缓存",
+			source: `import "lib/math.lox" as m;
+import "lib/math.lox" as again;
+var counter = 100;
+print m.square(3);
+print again.calls();
+print m.Point(3, 4).norm();
+print m;`,
+			output: "loading math\n9\n1\n25\n<module lib/math.lox>\n",
+		},
+		{name: "未导出的名称", source: `import "lib/math.lox" as m; print m.hidden;`, output: "loading math\n", message: "模块 'lib/math.lox' 没有导出 'hidden'。"},
+		{name: "模块中的运行时错误", source: `import "lib/util.lox" as u; u.fail();`, message: "操作数必须是数字。", file: "lib/util.lox"},
+		{name: "找不到模块", source: `import "missing.lox" as m; print m;`, message: "找不到模块 'missing.lox'。"},
+		{name: "循环导入", source: `import "lib/a.lox" as a; print a;`, message: "检测到循环导入: lib/a.lox -> lib/b.lox -> lib/a.lox。", file: "lib/b.lox"},
+		{name: "导入主脚本", source: `import "main.lox" as self; print self;`, message: "检测到循环导入: main.lox -> main.lox。"},
+		{name: "模块中的语法错误", source: `import "lib/bad.lox" as bad; print bad;`, message: "期望表达式", file: "lib/bad.lox"},
+		{
+			name: "捕获导入错误",
+			source: `try { import "lib/a.lox" as a; print a; } catch (e) { print e.message; }
+import "lib/util.lox" as u;
+print u.twice(21);`,
+			output: "检测到循环导入: lib/a.lox -> lib/b.lox -> lib/a.lox。\n42\n",
+		},
+	}
+
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				script := filepath.Join(dir, "main.lox")
+				if err := os.WriteFile(script, []byte(tt.source), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				var out bytes.Buffer
+				l := New(Options{Backend: backend, Stdout: &out})
+				l.SetDiagnosticSinks()
+				err := l.RunFile(script)
+
+				if out.String() != tt.output {
+					t.Errorf("期望输出 %q，实际: %q", tt.output, out.String())
+				}
+				if tt.message == "" {
+					if err != nil {
+						t.Fatalf("意外的错误: %v", err)
+					}
+					return
+				}
+
+				var message, file string
+				var runtimeError *RuntimeError
+				var parseError *ParseError
+				switch {
+				case errors.As(err, &runtimeError):
+					message, file = runtimeError.Message, runtimeError.File
+				case errors.As(err, &parseError):
+					message, file = parseError.Diagnostics[0].Message, parseError.Diagnostics[0].File
+				default:
+					t.Fatalf("期望错误 %q，实际: %v", tt.message, err)
+				}
+				if message != tt.message || file != tt.file {
+					t.Errorf("期望%q中的错误 %q，实际%q中的 %q", tt.file, tt.message, file, message)
+				}
+			})
+		}
+	}
+}
+
+// deepRecursionSource 递归深度较大且每层都经过return的脚本，用于衡量函数调用和控制流的开销
+const deepRecursionSource = `
+fun fibonacci(n) {
+  if (n <= 1) return n;
+  return fibonacci(n - 1) + fibonacci(n - 2);
+}
+
+fun sum(n) {
+  if (n == 0) return 0;
+  return n + sum(n - 1);
+}
+
+fun loop(n) {
+  var total = 0;
+  for (var i = 0; i < n; i = i + 1) {
+    if (i % 2 == 0) continue;
+    total = total + sum(50);
+  }
+  return total;
+}
+
+fibonacci(18) + sum(3000) + loop(200);
+`
+
+// 衡量树遍历解释器在深递归上的函数调用和控制流开销，return、break和continue以完成值传递而不经过panic/recover
+// 运行 go test ./lox -run '^$' -bench DeepRecursion
+func BenchmarkDeepRecursion(b *testing.B) {
+	source, err := os.ReadFile(filepath.Join("..", "example", "advanced_recursion.lox"))
+	if err != nil {
+		b.Fatalf("读取示例脚本出错: %v", err)
+	}
+	scripts := []struct {
+		name   string
+		source string
+	}{
+		{"advanced_recursion.lox", string(source)},
+		{"deep_recursion", deepRecursionSource},
+	}
+
+	for _, script := range scripts {
+		b.Run(script.name, func(b *testing.B) {
+			l := New(Options{Backend: BackendTree, Stdout: io.Discard})
+			l.SetDiagnosticSinks()
+
+			for n := 0; n < b.N; n++ {
+				if _, err := l.Eval(context.Background(), script.source); err != nil {
+					b.Fatalf("执行出错: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// 比较各后端在深递归上的性能
+// 运行 go test ./lox -run '^$' -bench Backends
+func BenchmarkBackends(b *testing.B) {
+	for _, backend := range []Backend{BackendTree, BackendIndexed, BackendVM} {
+		b.Run(string(backend), func(b *testing.B) {
+			l := New(Options{Backend: backend})
+			l.SetDiagnosticSinks()
+
+			for n := 0; n < b.N; n++ {
+				if _, err := l.Eval(context.Background(), deepRecursionSource); err != nil {
+					b.Fatalf("执行出错: %v", err)
+				}
+			}
+		})
+	}
+}
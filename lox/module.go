@@ -0,0 +1,173 @@
+package lox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/compiler"
+	errorp "github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/interpreter"
+	"github.com/aixiasang/goLox/lox/parser"
+	"github.com/aixiasang/goLox/lox/resolver"
+	"github.com/aixiasang/goLox/lox/scanner"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// 模块在导入它的Lox实例的执行后端中执行，但使用自己的全局环境。
+// 每个文件只执行一次，之后的导入共享缓存的模块；模块的文件名相对于主脚本所在的目录显示。
+
+// setMainFile 记录主脚本文件，之后导入的模块相对于它所在的目录查找
+func (l *Lox) setMainFile(path string) error {
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	l.dir = filepath.Dir(file)
+	l.loading = []string{file}
+	return nil
+}
+
+// moduleDir 返回主脚本所在的目录，没有主脚本文件时为当前目录
+func (l *Lox) moduleDir() string {
+	if l.dir == "" {
+		if dir, err := os.Getwd(); err == nil {
+			l.dir = dir
+		}
+	}
+	return l.dir
+}
+
+// moduleFile 返回import语句中的路径对应的文件的绝对路径，相对路径相对于导入它的文件
+func (l *Lox) moduleFile(path *token.Token) string {
+	file := path.Literal.(string)
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+
+	dir := l.moduleDir()
+	if path.Source != nil {
+		dir = filepath.Dir(l.absModuleFile(path.Source.Name))
+	}
+	return filepath.Join(dir, file)
+}
+
+// moduleName 返回模块在错误信息和调用栈中显示的文件名
+func (l *Lox) moduleName(file string) string {
+	if name, err := filepath.Rel(l.moduleDir(), file); err == nil {
+		return filepath.ToSlash(name)
+	}
+	return file
+}
+
+// absModuleFile 将moduleName返回的文件名还原为绝对路径
+func (l *Lox) absModuleFile(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(l.moduleDir(), filepath.FromSlash(name))
+}
+
+// importModule 加载import语句导入的模块，作为各执行后端的interpreter.Importer
+// 找不到文件、循环导入和模块执行中的错误都是导入位置可以捕获的运行时错误；
+// 模块中的语法和静态检查错误照常报告，随后以不能捕获的运行时错误结束执行
+func (l *Lox) importModule(path *token.Token) *interpreter.Module {
+	file := l.moduleFile(path)
+	name := l.moduleName(file)
+
+	if module, ok := l.modules[file]; ok {
+		return module
+	}
+
+	for i, loading := range l.loading {
+		if loading == file {
+			cycle := make([]string, 0, len(l.loading)-i+1)
+			for _, f := range l.loading[i:] {
+				cycle = append(cycle, l.moduleName(f))
+			}
+			cycle = append(cycle, name)
+			panic(errorp.RuntimeError{
+				Token:   path,
+				Message: fmt.Sprintf("检测到循环导入: %s。", strings.Join(cycle, " -> ")),
+			})
+		}
+	}
+
+	source, err := os.ReadFile(file)
+	if err != nil {
+		message := fmt.Sprintf("无法读取模块 '%s'。", name)
+		if os.IsNotExist(err) {
+			message = fmt.Sprintf("找不到模块 '%s'。", name)
+		}
+		panic(errorp.RuntimeError{Token: path, Message: message})
+	}
+
+	l.loading = append(l.loading, file)
+	defer func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}()
+
+	module := l.runModule(name, string(source), path)
+	if module == nil {
+		panic(errorp.RuntimeError{
+			Token:   path,
+			Message: fmt.Sprintf("导入模块 '%s' 失败。", name),
+			Fatal:   true,
+		})
+	}
+	l.modules[file] = module
+	return module
+}
+
+// runModule 扫描、解析、检查并执行模块的源代码，有语法或静态检查错误时返回nil
+// 模块的诊断信息带有模块的文件名和源代码摘录，转发给Lox实例的错误报告器
+func (l *Lox) runModule(name, source string, at *token.Token) *interpreter.Module {
+	reporter := errorp.NewErrorReporter()
+	reporter.SetSinks(errorp.SinkFunc(l.errorReporter.Report))
+	reporter.SetSource(source)
+	reporter.SetFile(name)
+
+	reporter.SetKind(errorp.KindScan)
+	s := scanner.NewScanner(source, reporter)
+	s.SetFile(name)
+	tokens := s.ScanTokens()
+
+	reporter.SetKind(errorp.KindParse)
+	statements := parser.NewParser(tokens, reporter).Parse()
+	if reporter.HasError() {
+		return nil
+	}
+
+	reporter.SetKind(errorp.KindResolve)
+	switch l.backend {
+	case BackendIndexed:
+		locations := resolver.NewOptimizedResolver(reporter).ResolveStatements(statements)
+		if reporter.HasError() {
+			return nil
+		}
+		l.indexed.SetLocations(locations)
+		return l.indexed.ExecuteModule(name, statements, at)
+	case BackendVM:
+		resolver.NewOptimizedResolver(reporter).ResolveStatements(statements)
+		if reporter.HasError() {
+			return nil
+		}
+		function := compiler.NewCompiler(reporter).Compile(statements)
+		if function == nil {
+			return nil
+		}
+		function.Module = name
+		if l.debug {
+			fmt.Fprint(l.stdout, compiler.Disassemble(function))
+		}
+		return l.vm.ExecuteModule(name, function, ast.ExportedNames(statements), at)
+	default:
+		resolver.NewResolver(l.interpreter, reporter).Resolve(statements)
+		if reporter.HasError() {
+			return nil
+		}
+		return l.interpreter.ExecuteModule(name, statements, at)
+	}
+}
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/aixiasang/goLox/lox/ast"
 	"github.com/aixiasang/goLox/lox/error"
@@ -14,6 +16,9 @@ type Parser struct {
 	current       int            // 当前标记索引
 	errorReporter error.Reporter // 错误报告器
 	debug         bool           // 调试模式标志
+	debugOutput   io.Writer      // 调试信息的输出目标
+	loopDepth     int            // 当前函数内循环的嵌套深度，用于检查break和continue语句
+	noComma       bool           // 是否在解析箭头函数的表达式体，此时逗号不是逗号运算符
 }
 
 // NewParser 创建一个新的解析器
@@ -23,6 +28,7 @@ func NewParser(tokens []*token.Token, errorReporter error.Reporter) *Parser {
 		current:       0,
 		errorReporter: errorReporter,
 		debug:         false, // 默认不启用调试模式
+		debugOutput:   os.Stdout,
 	}
 }
 
@@ -31,17 +37,22 @@ func (p *Parser) SetDebug(debug bool) {
 	p.debug = debug
 }
 
+// SetDebugOutput 设置调试信息的输出目标
+func (p *Parser) SetDebugOutput(w io.Writer) {
+	p.debugOutput = w
+}
+
 // 调试输出辅助函数
 func (p *Parser) debugPrintf(format string, args ...interface{}) {
 	if p.debug {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(p.debugOutput, format, args...)
 	}
 }
 
 // debugPrintln 调试输出一行信息
 func (p *Parser) debugPrintln(args ...interface{}) {
 	if p.debug {
-		fmt.Println(args...)
+		fmt.Fprintln(p.debugOutput, args...)
 	}
 }
 
@@ -82,31 +93,144 @@ func (p *Parser) declaration() ast.Stmt {
 		}
 	}()
 
-	if p.match(token.FUN) {
-		return p.function("函数")
+	if p.match(token.CLASS) {
+		return p.classDeclaration()
+	}
+
+	// fun之后不是函数名时是匿名函数表达式，作为表达式语句解析
+	if p.check(token.FUN) && p.checkNext(token.IDENTIFIER) {
+		return p.functionDeclaration()
 	}
 
 	if p.match(token.VAR) {
 		return p.varDeclaration()
 	}
 
+	if p.match(token.IMPORT) {
+		return p.importDeclaration()
+	}
+
+	if p.match(token.EXPORT) {
+		return p.exportDeclaration()
+	}
+
 	return p.statement()
 }
 
+// functionDeclaration 解析以fun开头的具名函数声明
+func (p *Parser) functionDeclaration() ast.Stmt {
+	start := p.advance()
+	function := p.function("函数")
+	function.SetSpan(p.spanFrom(start))
+	return function
+}
+
+// importDeclaration 解析导入模块语句: import "path" as name;
+func (p *Parser) importDeclaration() ast.Stmt {
+	keyword := p.previous()
+	path := p.consume(token.STRING, "期望在 'import' 后有模块路径字符串。")
+
+	// as只在这里有特殊含义，不是保留字
+	if !p.check(token.IDENTIFIER) || p.peek().Lexeme != "as" {
+		p.error(p.peek(), "期望在模块路径后有 'as'。")
+	}
+	p.advance()
+	name := p.consume(token.IDENTIFIER, "期望在 'as' 后有模块名称。")
+
+	p.consume(token.SEMICOLON, "期望在import语句后有 ';'")
+	return p.finishStmt(keyword, ast.NewImport(keyword, path, name))
+}
+
+// exportDeclaration 解析导出声明语句，export之后必须是变量、函数或类声明
+func (p *Parser) exportDeclaration() ast.Stmt {
+	keyword := p.previous()
+
+	var declaration ast.Stmt
+	switch {
+	case p.match(token.CLASS):
+		declaration = p.classDeclaration()
+	case p.check(token.FUN) && p.checkNext(token.IDENTIFIER):
+		declaration = p.functionDeclaration()
+	case p.match(token.VAR):
+		declaration = p.varDeclaration()
+	default:
+		p.error(p.peek(), "期望在 'export' 后有变量、函数或类声明。")
+	}
+
+	return p.finishStmt(keyword, ast.NewExport(keyword, declaration))
+}
+
+// classDeclaration 解析类声明
+func (p *Parser) classDeclaration() ast.Stmt {
+	start := p.previous()
+	name := p.consume(token.IDENTIFIER, "期望类名称。")
+
+	var superclass *ast.Variable
+	if p.match(token.LESS) {
+		p.consume(token.IDENTIFIER, "期望父类名称。")
+		superclass = ast.NewVariable(p.previous())
+		superclass.SetSpan(p.previous().Span())
+	}
+
+	p.consume(token.LEFT_BRACE, "期望类体开始有'{'。")
+
+	var methods []*ast.Function
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		methods = append(methods, p.function("方法"))
+	}
+
+	p.consume(token.RIGHT_BRACE, "期望类体结束有'}'。")
+
+	return p.finishStmt(start, ast.NewClass(name, superclass, methods))
+}
+
 // function 解析函数声明
-func (p *Parser) function(kind string) ast.Stmt {
+func (p *Parser) function(kind string) *ast.Function {
 	name := p.consume(token.IDENTIFIER, "期望"+kind+"名称。")
 
 	p.consume(token.LEFT_PAREN, "期望"+kind+"名称后有'('。")
+	parameters, defaults, rest := p.parameters()
+
+	p.consume(token.LEFT_BRACE, "期望"+kind+"体开始有'{'。")
+	body := p.functionBody()
 
-	var parameters []*token.Token
+	function := ast.NewFunction(name, parameters, body)
+	function.Defaults, function.Rest = defaults, rest
+	function.SetSpan(p.spanFrom(name))
+	return function
+}
+
+// parameters 解析参数列表直到右括号，左括号已被消费
+// 参数可以用 = 指定默认值，之后的参数也必须有默认值；最后一个参数可以是以...开头的剩余参数
+func (p *Parser) parameters() (parameters []*token.Token, defaults []ast.Expr, rest bool) {
+	hasDefault := false
 	if !p.check(token.RIGHT_PAREN) {
 		for {
 			if len(parameters) >= 255 {
 				p.error(p.peek(), "参数不能超过255个。")
 			}
 
-			parameters = append(parameters, p.consume(token.IDENTIFIER, "期望参数名称。"))
+			if p.match(token.ELLIPSIS) {
+				parameters = append(parameters, p.consume(token.IDENTIFIER, "期望'...'后有剩余参数名称。"))
+				defaults = append(defaults, nil)
+				rest = true
+				if !p.check(token.RIGHT_PAREN) {
+					p.error(p.peek(), "剩余参数必须是最后一个参数，且不能有默认值。")
+				}
+				break
+			}
+
+			name := p.consume(token.IDENTIFIER, "期望参数名称。")
+			parameters = append(parameters, name)
+
+			var value ast.Expr
+			if p.match(token.EQUAL) {
+				value = p.noCommaExpression()
+				hasDefault = true
+			} else if hasDefault {
+				p.error(name, "有默认值的参数之后的参数也必须有默认值。")
+			}
+			defaults = append(defaults, value)
 
 			if !p.match(token.COMMA) {
 				break
@@ -115,15 +239,24 @@ func (p *Parser) function(kind string) ast.Stmt {
 	}
 
 	p.consume(token.RIGHT_PAREN, "期望参数列表后有')'。")
+	if !hasDefault {
+		defaults = nil
+	}
+	return parameters, defaults, rest
+}
 
-	p.consume(token.LEFT_BRACE, "期望"+kind+"体开始有'{'。")
-	body := p.block()
+// functionBody 解析函数体，函数体内不能break或continue外层的循环
+func (p *Parser) functionBody() []ast.Stmt {
+	enclosingLoopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = enclosingLoopDepth }()
 
-	return ast.NewFunction(name, parameters, body)
+	return p.block()
 }
 
 // varDeclaration 解析变量声明
 func (p *Parser) varDeclaration() ast.Stmt {
+	start := p.previous()
 	name := p.consume(token.IDENTIFIER, "期望变量名")
 
 	var initializer ast.Expr
@@ -132,7 +265,7 @@ func (p *Parser) varDeclaration() ast.Stmt {
 	}
 
 	p.consume(token.SEMICOLON, "期望在变量声明后有 ';'")
-	return ast.NewVar(name, initializer)
+	return p.finishStmt(start, ast.NewVar(name, initializer))
 }
 
 // statement 解析语句
@@ -142,7 +275,8 @@ func (p *Parser) statement() ast.Stmt {
 	}
 
 	if p.match(token.LEFT_BRACE) {
-		return ast.NewBlock(p.block())
+		start := p.previous()
+		return p.finishStmt(start, ast.NewBlock(p.block()))
 	}
 
 	if p.match(token.IF) {
@@ -161,10 +295,22 @@ func (p *Parser) statement() ast.Stmt {
 		return p.breakStatement()
 	}
 
+	if p.match(token.CONTINUE) {
+		return p.continueStatement()
+	}
+
 	if p.match(token.RETURN) {
 		return p.returnStatement()
 	}
 
+	if p.match(token.THROW) {
+		return p.throwStatement()
+	}
+
+	if p.match(token.TRY) {
+		return p.tryStatement()
+	}
+
 	return p.expressionStatement()
 }
 
@@ -182,6 +328,7 @@ func (p *Parser) block() []ast.Stmt {
 
 // ifStatement 解析if语句
 func (p *Parser) ifStatement() ast.Stmt {
+	start := p.previous()
 	p.consume(token.LEFT_PAREN, "期望在 'if' 后有 '('")
 	condition := p.expression()
 	p.consume(token.RIGHT_PAREN, "期望在条件后有 ')'")
@@ -193,22 +340,24 @@ func (p *Parser) ifStatement() ast.Stmt {
 		elseBranch = p.statement()
 	}
 
-	return ast.NewIf(condition, thenBranch, elseBranch)
+	return p.finishStmt(start, ast.NewIf(start, condition, thenBranch, elseBranch))
 }
 
 // whileStatement 解析while语句
 func (p *Parser) whileStatement() ast.Stmt {
+	start := p.previous()
 	p.consume(token.LEFT_PAREN, "期望在 'while' 后有 '('")
 	condition := p.expression()
 	p.consume(token.RIGHT_PAREN, "期望在条件后有 ')'")
 
-	body := p.statement()
+	body := p.loopBody()
 
-	return ast.NewWhile(condition, body)
+	return p.finishStmt(start, ast.NewWhile(start, condition, body))
 }
 
 // forStatement 解析for语句
 func (p *Parser) forStatement() ast.Stmt {
+	start := p.previous()
 	p.consume(token.LEFT_PAREN, "for语句后需要'('。")
 
 	// 初始化部分
@@ -236,49 +385,66 @@ func (p *Parser) forStatement() ast.Stmt {
 	p.consume(token.RIGHT_PAREN, "for循环的闭合需要')'。")
 
 	// 循环体
-	body := p.statement()
-
-	// 重构为while循环
-	// 如果有更新表达式，将其附加到循环体后面
-	if increment != nil {
-		body = ast.NewBlock([]ast.Stmt{
-			body,
-			ast.NewExpression(increment),
-		})
-	}
+	body := p.loopBody()
 
+	// 重构为while循环，展开生成的节点都使用整个for语句的区间
 	// 如果没有条件，默认为true
 	if condition == nil {
 		condition = ast.NewLiteral(true)
 	}
 
-	// 创建while语句
-	body = ast.NewWhile(condition, body)
+	// 更新表达式不放进循环体，这样continue跳过循环体剩余部分后仍会执行它
+	loop := ast.NewWhile(start, condition, body)
+	loop.Increment = increment
+	body = p.finishStmt(start, loop)
 
 	// 如果有初始化语句，将其放在前面
 	if initializer != nil {
-		body = ast.NewBlock([]ast.Stmt{initializer, body})
+		body = p.finishStmt(start, ast.NewBlock([]ast.Stmt{initializer, body}))
 	}
 
 	return body
 }
 
+// loopBody 解析循环体，并记录循环的嵌套深度
+func (p *Parser) loopBody() ast.Stmt {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.statement()
+}
+
 // printStatement 解析打印语句
 func (p *Parser) printStatement() ast.Stmt {
+	start := p.previous()
 	value := p.expression()
 	p.consume(token.SEMICOLON, "期望在语句后有 ';'")
-	return ast.NewPrint(value)
+	return p.finishStmt(start, ast.NewPrint(value))
 }
 
 // expressionStatement 解析表达式语句
 func (p *Parser) expressionStatement() ast.Stmt {
+	start := p.peek()
 	expr := p.expression()
 	p.consume(token.SEMICOLON, "期望在语句后有 ';'")
-	return ast.NewExpression(expr)
+	return p.finishStmt(start, ast.NewExpression(expr))
 }
 
 // expression 解析表达式
 func (p *Parser) expression() ast.Expr {
+	enclosingNoComma := p.noComma
+	p.noComma = false
+	defer func() { p.noComma = enclosingNoComma }()
+
+	return p.assignment()
+}
+
+// noCommaExpression 解析其中的逗号不是逗号运算符的表达式，用于箭头函数的表达式体和参数的默认值
+func (p *Parser) noCommaExpression() ast.Expr {
+	enclosingNoComma := p.noComma
+	p.noComma = true
+	defer func() { p.noComma = enclosingNoComma }()
+
 	return p.assignment()
 }
 
@@ -290,6 +456,7 @@ func (p *Parser) funcCallArgExpression() ast.Expr {
 
 // assignment 解析赋值表达式
 func (p *Parser) assignment() ast.Expr {
+	start := p.peek()
 	expr := p.or()
 
 	if p.match(token.EQUAL) {
@@ -298,7 +465,15 @@ func (p *Parser) assignment() ast.Expr {
 
 		if variable, ok := expr.(*ast.Variable); ok {
 			name := variable.Name
-			return ast.NewAssign(name, value)
+			return p.finishExpr(start, ast.NewAssign(name, value))
+		}
+
+		if get, ok := expr.(*ast.Get); ok {
+			return p.finishExpr(start, ast.NewSet(get.Object, get.Name, value))
+		}
+
+		if index, ok := expr.(*ast.Index); ok {
+			return p.finishExpr(start, ast.NewIndexSet(index.Object, index.Bracket, index.Index, value))
 		}
 
 		p.error(equals, "无效的赋值目标")
@@ -309,12 +484,13 @@ func (p *Parser) assignment() ast.Expr {
 
 // or 解析逻辑OR表达式
 func (p *Parser) or() ast.Expr {
+	start := p.peek()
 	expr := p.and()
 
 	for p.match(token.OR) {
 		operator := p.previous()
 		right := p.and()
-		expr = ast.NewLogical(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewLogical(expr, operator, right))
 	}
 
 	return expr
@@ -322,12 +498,13 @@ func (p *Parser) or() ast.Expr {
 
 // and 解析逻辑AND表达式
 func (p *Parser) and() ast.Expr {
+	start := p.peek()
 	expr := p.comma()
 
 	for p.match(token.AND) {
 		operator := p.previous()
 		right := p.comma()
-		expr = ast.NewLogical(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewLogical(expr, operator, right))
 	}
 
 	return expr
@@ -338,7 +515,7 @@ func (p *Parser) comma() ast.Expr {
 	var exprs []ast.Expr
 	exprs = append(exprs, p.conditional())
 
-	for p.match(token.COMMA) {
+	for !p.noComma && p.match(token.COMMA) {
 		exprs = append(exprs, p.conditional())
 	}
 
@@ -350,12 +527,15 @@ func (p *Parser) comma() ast.Expr {
 	// 从右到左构建二叉树
 	expr := exprs[len(exprs)-1]
 	for i := len(exprs) - 2; i >= 0; i-- {
+		span := exprs[i].Span().Union(expr.Span())
 		expr = ast.NewBinary(exprs[i], &token.Token{
 			Type:    token.COMMA,
 			Lexeme:  ",",
 			Literal: nil,
 			Line:    p.previous().Line,
+			Source:  p.previous().Source,
 		}, expr)
+		expr.SetSpan(span)
 	}
 
 	return expr
@@ -363,13 +543,15 @@ func (p *Parser) comma() ast.Expr {
 
 // conditional 解析条件表达式（三元运算符）
 func (p *Parser) conditional() ast.Expr {
+	start := p.peek()
 	expr := p.equality()
 
 	if p.match(token.QUESTION) {
+		question := p.previous()
 		thenBranch := p.expression()
 		p.consume(token.COLON, "期望在条件表达式中的 '?' 后有 ':'")
 		elseBranch := p.conditional()
-		expr = ast.NewTernary(expr, thenBranch, elseBranch)
+		expr = p.finishExpr(start, ast.NewTernary(expr, question, thenBranch, elseBranch))
 	}
 
 	return expr
@@ -377,12 +559,13 @@ func (p *Parser) conditional() ast.Expr {
 
 // equality 解析相等性表达式
 func (p *Parser) equality() ast.Expr {
+	start := p.peek()
 	// 处理缺少左操作数的情况
 	if p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
 		operator := p.previous()
 		p.error(operator, "二元运算符缺少左操作数")
 		right := p.comparison()
-		return ast.NewBinary(ast.NewLiteral(nil), operator, right)
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
 	}
 
 	expr := p.comparison()
@@ -390,7 +573,7 @@ func (p *Parser) equality() ast.Expr {
 	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
 		operator := p.previous()
 		right := p.comparison()
-		expr = ast.NewBinary(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
 	}
 
 	return expr
@@ -398,20 +581,110 @@ func (p *Parser) equality() ast.Expr {
 
 // comparison 解析比较表达式
 func (p *Parser) comparison() ast.Expr {
+	start := p.peek()
 	// 处理缺少左操作数的情况
 	if p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		operator := p.previous()
+		p.error(operator, "二元运算符缺少左操作数")
+		right := p.bitOr()
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
+	}
+
+	expr := p.bitOr()
+
+	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		operator := p.previous()
+		right := p.bitOr()
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+// bitOr 解析按位或表达式
+// 位运算的优先级高于比较，因此flags & MASK == 0 等价于 (flags & MASK) == 0
+func (p *Parser) bitOr() ast.Expr {
+	start := p.peek()
+	// 处理缺少左操作数的情况
+	if p.match(token.PIPE) {
+		operator := p.previous()
+		p.error(operator, "二元运算符缺少左操作数")
+		right := p.bitXor()
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
+	}
+
+	expr := p.bitXor()
+
+	for p.match(token.PIPE) {
+		operator := p.previous()
+		right := p.bitXor()
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+// bitXor 解析按位异或表达式
+func (p *Parser) bitXor() ast.Expr {
+	start := p.peek()
+	// 处理缺少左操作数的情况
+	if p.match(token.CARET) {
+		operator := p.previous()
+		p.error(operator, "二元运算符缺少左操作数")
+		right := p.bitAnd()
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
+	}
+
+	expr := p.bitAnd()
+
+	for p.match(token.CARET) {
+		operator := p.previous()
+		right := p.bitAnd()
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+// bitAnd 解析按位与表达式
+func (p *Parser) bitAnd() ast.Expr {
+	start := p.peek()
+	// 处理缺少左操作数的情况
+	if p.match(token.AMPERSAND) {
+		operator := p.previous()
+		p.error(operator, "二元运算符缺少左操作数")
+		right := p.shift()
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
+	}
+
+	expr := p.shift()
+
+	for p.match(token.AMPERSAND) {
+		operator := p.previous()
+		right := p.shift()
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+// shift 解析移位表达式
+func (p *Parser) shift() ast.Expr {
+	start := p.peek()
+	// 处理缺少左操作数的情况
+	if p.match(token.LESS_LESS, token.GREATER_GREATER) {
 		operator := p.previous()
 		p.error(operator, "二元运算符缺少左操作数")
 		right := p.term()
-		return ast.NewBinary(ast.NewLiteral(nil), operator, right)
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
 	}
 
 	expr := p.term()
 
-	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+	for p.match(token.LESS_LESS, token.GREATER_GREATER) {
 		operator := p.previous()
 		right := p.term()
-		expr = ast.NewBinary(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
 	}
 
 	return expr
@@ -419,12 +692,13 @@ func (p *Parser) comparison() ast.Expr {
 
 // term 解析项表达式
 func (p *Parser) term() ast.Expr {
+	start := p.peek()
 	// 处理缺少左操作数的情况
 	if p.match(token.PLUS) {
 		operator := p.previous()
 		p.error(operator, "二元运算符缺少左操作数")
 		right := p.factor()
-		return ast.NewBinary(ast.NewLiteral(nil), operator, right)
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
 	}
 
 	expr := p.factor()
@@ -432,7 +706,7 @@ func (p *Parser) term() ast.Expr {
 	for p.match(token.MINUS, token.PLUS) {
 		operator := p.previous()
 		right := p.factor()
-		expr = ast.NewBinary(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
 	}
 
 	return expr
@@ -440,12 +714,13 @@ func (p *Parser) term() ast.Expr {
 
 // factor 解析因子表达式
 func (p *Parser) factor() ast.Expr {
+	start := p.peek()
 	// 处理缺少左操作数的情况
-	if p.match(token.SLASH, token.STAR, token.MODULO) {
+	if p.match(token.SLASH, token.STAR, token.MODULO, token.STAR_STAR) {
 		operator := p.previous()
 		p.error(operator, "二元运算符缺少左操作数")
 		right := p.unary()
-		return ast.NewBinary(ast.NewLiteral(nil), operator, right)
+		return p.finishExpr(start, ast.NewBinary(ast.NewLiteral(nil), operator, right))
 	}
 
 	expr := p.unary()
@@ -453,7 +728,7 @@ func (p *Parser) factor() ast.Expr {
 	for p.match(token.SLASH, token.STAR, token.MODULO) {
 		operator := p.previous()
 		right := p.unary()
-		expr = ast.NewBinary(expr, operator, right)
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
 	}
 
 	return expr
@@ -461,22 +736,47 @@ func (p *Parser) factor() ast.Expr {
 
 // unary 解析一元表达式
 func (p *Parser) unary() ast.Expr {
-	if p.match(token.BANG, token.MINUS) {
+	start := p.peek()
+	if p.match(token.BANG, token.MINUS, token.TILDE) {
 		operator := p.previous()
 		right := p.unary()
-		return ast.NewUnary(operator, right)
+		return p.finishExpr(start, ast.NewUnary(operator, right))
 	}
 
-	return p.call()
+	return p.power()
+}
+
+// power 解析乘方表达式
+// 乘方是右结合的，并且比左侧的一元运算符结合得更紧：2 ** 3 ** 2 等价于 2 ** (3 ** 2)，-2 ** 2 等价于 -(2 ** 2)
+func (p *Parser) power() ast.Expr {
+	start := p.peek()
+	expr := p.call()
+
+	if p.match(token.STAR_STAR) {
+		operator := p.previous()
+		right := p.unary()
+		expr = p.finishExpr(start, ast.NewBinary(expr, operator, right))
+	}
+
+	return expr
 }
 
 // call 解析函数调用
 func (p *Parser) call() ast.Expr {
+	start := p.peek()
 	expr := p.primary()
 
 	for {
 		if p.match(token.LEFT_PAREN) {
-			expr = p.finishCall(expr)
+			expr = p.finishExpr(start, p.finishCall(expr))
+		} else if p.match(token.DOT) {
+			name := p.consume(token.IDENTIFIER, "期望'.'后有属性名称。")
+			expr = p.finishExpr(start, ast.NewGet(expr, name))
+		} else if p.match(token.LEFT_BRACKET) {
+			bracket := p.previous()
+			index := p.expression()
+			p.consume(token.RIGHT_BRACKET, "期望下标后有']'。")
+			expr = p.finishExpr(start, ast.NewIndex(expr, bracket, index))
 		} else {
 			break
 		}
@@ -487,14 +787,29 @@ func (p *Parser) call() ast.Expr {
 
 // finishCall 完成函数调用的解析
 func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
-	// 创建一个空的参数列表
+	// 创建一个空的参数列表，命名参数位于位置参数之后
 	var arguments []ast.Expr
+	var names []*token.Token
 
 	p.debugPrintln("解析函数调用参数：开始")
 
 	// 如果不是右括号，则解析参数列表
 	if !p.check(token.RIGHT_PAREN) {
 		for {
+			// 名称后跟':'的是命名参数
+			if p.check(token.IDENTIFIER) && p.checkNext(token.COLON) {
+				name := p.advance()
+				p.advance()
+				for _, previous := range names {
+					if previous.Lexeme == name.Lexeme {
+						p.errorReporter.Error(name, 0, fmt.Sprintf("重复的命名参数 '%s'。", name.Lexeme))
+					}
+				}
+				names = append(names, name)
+			} else if len(names) > 0 {
+				p.errorReporter.Error(p.peek(), 0, "命名参数之后不能有位置参数。")
+			}
+
 			// 解析参数 - 使用funcCallArgExpression而不是expression
 			arg := p.funcCallArgExpression()
 			arguments = append(arguments, arg)
@@ -520,33 +835,69 @@ func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
 	p.debugPrintf("参数解析完成，共 %d 个参数\n", len(arguments))
 
 	// 创建并返回调用表达式
-	return ast.NewCall(callee, paren, arguments)
+	call := ast.NewCall(callee, paren, arguments)
+	call.Names = names
+	return call
 }
 
 // primary 解析基本表达式
 func (p *Parser) primary() ast.Expr {
+	start := p.peek()
 	if p.match(token.FALSE) {
-		return ast.NewLiteral(false)
+		return p.finishExpr(start, ast.NewLiteral(false))
 	}
 	if p.match(token.TRUE) {
-		return ast.NewLiteral(true)
+		return p.finishExpr(start, ast.NewLiteral(true))
 	}
 	if p.match(token.NIL) {
-		return ast.NewLiteral(nil)
+		return p.finishExpr(start, ast.NewLiteral(nil))
 	}
 
 	if p.match(token.NUMBER, token.STRING) {
-		return ast.NewLiteral(p.previous().Literal)
+		return p.finishExpr(start, ast.NewLiteral(p.previous().Literal))
+	}
+
+	if p.match(token.INTERPOLATION) {
+		return p.interpolation()
+	}
+
+	if p.match(token.THIS) {
+		return p.finishExpr(start, ast.NewThis(p.previous()))
+	}
+
+	if p.match(token.SUPER) {
+		keyword := p.previous()
+		p.consume(token.DOT, "期望'super'后有'.'。")
+		method := p.consume(token.IDENTIFIER, "期望父类方法名称。")
+		return p.finishExpr(start, ast.NewSuper(keyword, method))
+	}
+
+	if p.match(token.FUN) {
+		return p.lambda()
 	}
 
 	if p.match(token.IDENTIFIER) {
-		return ast.NewVariable(p.previous())
+		if p.check(token.ARROW) {
+			return p.arrowFunction(start, ast.NewFunction(nil, []*token.Token{p.previous()}, nil))
+		}
+		return p.finishExpr(start, ast.NewVariable(p.previous()))
 	}
 
 	if p.match(token.LEFT_PAREN) {
+		if p.isArrowParameters() {
+			return p.arrowFunction(start, p.lambdaParameters())
+		}
 		expr := p.expression()
 		p.consume(token.RIGHT_PAREN, "期望在表达式后有 ')'")
-		return ast.NewGrouping(expr)
+		return p.finishExpr(start, ast.NewGrouping(expr))
+	}
+
+	if p.match(token.LEFT_BRACKET) {
+		return p.listLiteral()
+	}
+
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
 	}
 
 	// 遇到错误，尝试同步恢复
@@ -556,6 +907,171 @@ func (p *Parser) primary() ast.Expr {
 
 // 辅助方法
 
+// lambda 解析匿名函数表达式 fun (a, b) { ... }，fun关键字已被消费
+func (p *Parser) lambda() ast.Expr {
+	keyword := p.previous()
+	p.consume(token.LEFT_PAREN, "期望匿名函数的'fun'后有'('。")
+	function := p.lambdaParameters()
+
+	p.consume(token.LEFT_BRACE, "期望函数体开始有'{'。")
+	body := p.functionBody()
+
+	return p.finishLambda(keyword, keyword, function, body)
+}
+
+// lambdaParameters 解析匿名函数的参数列表，返回只有参数的函数声明，左括号已被消费
+func (p *Parser) lambdaParameters() *ast.Function {
+	parameters, defaults, rest := p.parameters()
+	function := ast.NewFunction(nil, parameters, nil)
+	function.Defaults, function.Rest = defaults, rest
+	return function
+}
+
+// isArrowParameters 判断'('之后是否为箭头函数的参数列表，即与之匹配的')'之后是'=>'，左括号已被消费
+func (p *Parser) isArrowParameters() bool {
+	depth := 1
+	for i := p.current; p.tokens[i].Type != token.EOF; i++ {
+		switch p.tokens[i].Type {
+		case token.LEFT_PAREN:
+			depth++
+		case token.RIGHT_PAREN:
+			depth--
+			if depth == 0 {
+				// 右括号不是最后一个标记(EOF)，因此i+1不会越界
+				return p.tokens[i+1].Type == token.ARROW
+			}
+		}
+	}
+	return false
+}
+
+// arrowFunction 解析箭头函数 (a, b) => a + b 或 x => { ... }，参数列表已被消费
+// 表达式函数体相当于只有一条return语句的函数体，其中的逗号不是逗号运算符，
+// 因此箭头函数可以直接作为函数参数或列表元素
+func (p *Parser) arrowFunction(start *token.Token, function *ast.Function) ast.Expr {
+	arrow := p.consume(token.ARROW, "期望参数列表后有'=>'。")
+
+	if p.match(token.LEFT_BRACE) {
+		return p.finishLambda(start, arrow, function, p.functionBody())
+	}
+
+	value := p.noCommaExpression()
+	ret := ast.NewReturn(arrow, value)
+	ret.SetSpan(value.Span())
+	return p.finishLambda(start, arrow, function, []ast.Stmt{ret})
+}
+
+// finishLambda 补全只有参数列表的函数声明并创建匿名函数表达式，函数名为ast.AnonymousName，位于at处
+func (p *Parser) finishLambda(start, at *token.Token, function *ast.Function, body []ast.Stmt) ast.Expr {
+	function.Name = &token.Token{
+		Type:   token.IDENTIFIER,
+		Lexeme: ast.AnonymousName,
+		Line:   at.Line,
+		Column: at.Column,
+		Offset: at.Offset,
+		Source: at.Source,
+	}
+	function.Body = body
+	function.SetSpan(p.spanFrom(start))
+	return p.finishExpr(start, ast.NewLambda(function))
+}
+
+// listLiteral 解析列表字面量，左方括号已被消费
+func (p *Parser) listLiteral() ast.Expr {
+	start := p.previous()
+	bracket := p.previous()
+	var elements []ast.Expr
+
+	if !p.check(token.RIGHT_BRACKET) {
+		for {
+			// 与函数参数一样，元素之间的逗号不是逗号运算符
+			elements = append(elements, p.funcCallArgExpression())
+			if !p.match(token.COMMA) {
+				break
+			}
+			// 允许末尾多余的逗号
+			if p.check(token.RIGHT_BRACKET) {
+				break
+			}
+		}
+	}
+
+	p.consume(token.RIGHT_BRACKET, "期望列表元素后有']'。")
+	return p.finishExpr(start, ast.NewList(bracket, elements))
+}
+
+// interpolation 解析插值字符串，第一个INTERPOLATION标记已被消费
+// 扫描器将"a${x}b${y}c"拆分为 INTERPOLATION("a") x INTERPOLATION_MID("b") y INTERPOLATION_END("c")
+func (p *Parser) interpolation() ast.Expr {
+	start := p.previous()
+	var parts []ast.Expr
+
+	for {
+		if text := p.previous().Literal.(string); text != "" {
+			parts = append(parts, p.finishExpr(p.previous(), ast.NewLiteral(text)))
+		}
+		if p.check(token.INTERPOLATION_MID) || p.check(token.INTERPOLATION_END) {
+			p.error(p.peek(), "插值表达式不能为空。")
+		}
+		parts = append(parts, p.expression())
+		if p.match(token.INTERPOLATION_MID) {
+			continue
+		}
+
+		p.consume(token.INTERPOLATION_END, "期望插值表达式后有'}'。")
+		if text := p.previous().Literal.(string); text != "" {
+			parts = append(parts, p.finishExpr(p.previous(), ast.NewLiteral(text)))
+		}
+		break
+	}
+
+	return p.finishExpr(start, ast.NewInterpolation(start, parts))
+}
+
+// mapLiteral 解析映射字面量，左花括号已被消费
+// 语句开头的'{'总是被解析为代码块，因此映射字面量只出现在表达式中间
+func (p *Parser) mapLiteral() ast.Expr {
+	start := p.previous()
+	brace := p.previous()
+	var keys []ast.Expr
+	var values []ast.Expr
+
+	if !p.check(token.RIGHT_BRACE) {
+		for {
+			keys = append(keys, p.funcCallArgExpression())
+			p.consume(token.COLON, "期望映射的键后有':'。")
+			values = append(values, p.funcCallArgExpression())
+			if !p.match(token.COMMA) {
+				break
+			}
+			// 允许末尾多余的逗号
+			if p.check(token.RIGHT_BRACE) {
+				break
+			}
+		}
+	}
+
+	p.consume(token.RIGHT_BRACE, "期望映射元素后有'}'。")
+	return p.finishExpr(start, ast.NewMap(brace, keys, values))
+}
+
+// spanFrom 返回从start到上一个已消费标记的源代码区间
+func (p *Parser) spanFrom(start *token.Token) token.Span {
+	return start.Span().Union(p.previous().Span())
+}
+
+// finishExpr 将表达式的区间设置为从start到上一个已消费的标记
+func (p *Parser) finishExpr(start *token.Token, expr ast.Expr) ast.Expr {
+	expr.SetSpan(p.spanFrom(start))
+	return expr
+}
+
+// finishStmt 将语句的区间设置为从start到上一个已消费的标记
+func (p *Parser) finishStmt(start *token.Token, stmt ast.Stmt) ast.Stmt {
+	stmt.SetSpan(p.spanFrom(start))
+	return stmt
+}
+
 // match 检查当前标记是否匹配任何给定类型，如果匹配则消费
 func (p *Parser) match(types ...token.TokenType) bool {
 	for _, t := range types {
@@ -584,6 +1100,14 @@ func (p *Parser) check(types ...token.TokenType) bool {
 	return false
 }
 
+// checkNext 检查当前标记之后的下一个标记是否为给定类型
+func (p *Parser) checkNext(tokenType token.TokenType) bool {
+	if p.isAtEnd() {
+		return false
+	}
+	return p.tokens[p.current+1].Type == tokenType
+}
+
 // advance 消费当前标记并返回
 func (p *Parser) advance() *token.Token {
 	if !p.isAtEnd() {
@@ -634,7 +1158,7 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF, token.WHILE, token.PRINT, token.RETURN:
+		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF, token.WHILE, token.PRINT, token.RETURN, token.THROW, token.TRY, token.IMPORT, token.EXPORT:
 			return
 		}
 
@@ -645,12 +1169,28 @@ func (p *Parser) synchronize() {
 // breakStatement 解析break语句
 func (p *Parser) breakStatement() ast.Stmt {
 	keyword := p.previous()
+	if p.loopDepth == 0 {
+		// 只报告错误，不需要同步
+		p.errorReporter.Error(keyword, 0, "Break语句只能在循环内部使用。")
+	}
 	p.consume(token.SEMICOLON, "break语句后需要';'。")
-	return ast.NewBreak(keyword)
+	return p.finishStmt(keyword, ast.NewBreak(keyword))
+}
+
+// continueStatement 解析continue语句
+func (p *Parser) continueStatement() ast.Stmt {
+	keyword := p.previous()
+	if p.loopDepth == 0 {
+		// 只报告错误，不需要同步
+		p.errorReporter.Error(keyword, 0, "Continue语句只能在循环内部使用。")
+	}
+	p.consume(token.SEMICOLON, "continue语句后需要';'。")
+	return p.finishStmt(keyword, ast.NewContinue(keyword))
 }
 
 // returnStatement 解析return语句
 func (p *Parser) returnStatement() ast.Stmt {
+	start := p.previous()
 	keyword := p.previous()
 	var value ast.Expr = nil
 
@@ -659,5 +1199,43 @@ func (p *Parser) returnStatement() ast.Stmt {
 	}
 
 	p.consume(token.SEMICOLON, "期望return语句后有';'。")
-	return ast.NewReturn(keyword, value)
+	return p.finishStmt(start, ast.NewReturn(keyword, value))
+}
+
+// throwStatement 解析throw语句
+func (p *Parser) throwStatement() ast.Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(token.SEMICOLON, "期望throw语句后有';'。")
+	return p.finishStmt(keyword, ast.NewThrow(keyword, value))
+}
+
+// tryStatement 解析try语句: try块后跟catch子句、finally子句或两者
+func (p *Parser) tryStatement() ast.Stmt {
+	keyword := p.previous()
+	body := p.blockStatement("期望在 'try' 后有 '{'")
+
+	var catchName *token.Token
+	var catch, finally *ast.Block
+	if p.match(token.CATCH) {
+		p.consume(token.LEFT_PAREN, "期望在 'catch' 后有 '('")
+		catchName = p.consume(token.IDENTIFIER, "期望异常变量名")
+		p.consume(token.RIGHT_PAREN, "期望在异常变量名后有 ')'")
+		catch = p.blockStatement("期望在catch子句后有 '{'")
+	}
+	if p.match(token.FINALLY) {
+		finally = p.blockStatement("期望在 'finally' 后有 '{'")
+	}
+
+	if catch == nil && finally == nil {
+		p.error(p.peek(), "try语句需要catch或finally子句。")
+	}
+
+	return p.finishStmt(keyword, ast.NewTry(keyword, body, catchName, catch, finally))
+}
+
+// blockStatement 解析以'{'开头的代码块语句
+func (p *Parser) blockStatement(message string) *ast.Block {
+	start := p.consume(token.LEFT_BRACE, message)
+	return p.finishStmt(start, ast.NewBlock(p.block())).(*ast.Block)
 }
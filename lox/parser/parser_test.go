@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/aixiasang/goLox/lox/ast"
 	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/scanner"
 	"github.com/aixiasang/goLox/lox/token"
 )
 
@@ -132,6 +134,271 @@ func TestParser(t *testing.T) {
 	}
 }
 
+// 测试位运算和乘方运算符的优先级与结合性
+func TestOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"2 ** 3 ** 2;", "2 3 2 ** **"},
+		{"-2 ** 2;", "2 2 ** -"},
+		{"2 ** -1;", "2 1 - **"},
+		{"2 * 3 ** 2;", "2 3 2 ** *"},
+		{"1 + 2 << 3;", "1 2 + 3 <<"},
+		{"a & b == 0;", "a b & 0 =="},
+		{"a | b ^ c & d;", "a b c d & ^ |"},
+		{"a << 1 < b >> 1;", "a 1 << b 1 >> <"},
+		{"~a & b;", "a ~ b &"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+
+			result := ast.NewRpnPrinter().Print(statements[0].(*ast.Expression).Expr)
+			if result != tt.expected {
+				t.Errorf("期望: %s\n实际: %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+// 测试匿名函数和箭头函数的解析，箭头函数的表达式体中逗号不是逗号运算符
+func TestLambda(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+		params   int
+		body     string
+	}{
+		{"fun (a, b) { return a + b; };", "(fun (a b))", 2, "return"},
+		{"fun () {}();", "(fun ())()", 0, ""},
+		{"(a, b) => a + b;", "(fun (a b))", 2, "(+ a b)"},
+		{"() => 1;", "(fun ())", 0, "1"},
+		{"x => x * 2;", "(fun (x))", 1, "(* x 2)"},
+		{"x => { return x; };", "(fun (x))", 1, "return"},
+		{"x => y => x + y;", "(fun (x))", 1, "(fun (y))"},
+		{"f(x => x, 1);", "f((fun (x)), 1)", 1, "x"},
+		{"(a, b = a * 2, ...rest) => rest;", "(fun (a b=(* a 2) ...rest))", 3, "rest"},
+		{"(f = (x) => x) => f;", "(fun (f=(fun (x))))", 1, "f"},
+		{"(a);", "(group a)", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+
+			expr := statements[0].(*ast.Expression).Expr
+			if result := ast.NewAstPrinter().Print(expr); result != tt.expected {
+				t.Errorf("期望: %s\n实际: %s", tt.expected, result)
+			}
+
+			if call, ok := expr.(*ast.Call); ok && len(call.Arguments) > 0 {
+				expr = call.Arguments[0]
+			}
+			lambda, ok := expr.(*ast.Lambda)
+			if !ok || tt.body == "" {
+				return
+			}
+			if lambda.Function.Name.Lexeme != ast.AnonymousName || len(lambda.Function.Params) != tt.params {
+				t.Errorf("函数名或参数个数不正确: %s %d", lambda.Function.Name.Lexeme, len(lambda.Function.Params))
+			}
+			ret, ok := lambda.Function.Body[0].(*ast.Return)
+			if !ok {
+				t.Fatalf("期望函数体以return开始，实际: %T", lambda.Function.Body[0])
+			}
+			if tt.body != "return" {
+				if result := ast.NewAstPrinter().Print(ret.Value); result != tt.body {
+					t.Errorf("期望函数体返回 %s，实际: %s", tt.body, result)
+				}
+			}
+		})
+	}
+}
+
+// 测试默认参数和剩余参数的解析
+func TestParameters(t *testing.T) {
+	tests := []struct {
+		source   string
+		min, max int
+		message  string
+	}{
+		{"fun f(a, b) {}", 2, 2, ""},
+		{"fun f(a, b = 1, c = a + b) {}", 1, 3, ""},
+		{"fun f(a, ...rest) {}", 1, -1, ""},
+		{"fun f(a = 1, ...rest) {}", 0, -1, ""},
+		{"fun f(a = 1, b) {}", 0, 0, "有默认值的参数之后的参数也必须有默认值。"},
+		{"fun f(...rest, b) {}", 0, 0, "剩余参数必须是最后一个参数，且不能有默认值。"},
+		{"fun f(...rest = []) {}", 0, 0, "剩余参数必须是最后一个参数，且不能有默认值。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			errors.SetSinks()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+
+			if tt.message != "" {
+				diagnostics := errors.Diagnostics()
+				if len(diagnostics) == 0 || diagnostics[0].Message != tt.message {
+					t.Errorf("期望错误 %q，实际: %v", tt.message, diagnostics)
+				}
+				return
+			}
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+
+			function := statements[0].(*ast.Function)
+			if function.MinArity() != tt.min || function.MaxArity() != tt.max {
+				t.Errorf("期望参数个数范围[%d, %d]，实际[%d, %d]", tt.min, tt.max, function.MinArity(), function.MaxArity())
+			}
+		})
+	}
+}
+
+// 测试调用处命名参数的解析，命名参数只能出现在位置参数之后，且不能重复
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+		message  string
+		lexeme   string // 出错位置的词素
+	}{
+		{"f(1, b: 2);", "f(1, b: 2)", "", ""},
+		{"f(b: 2, a: x + 1);", "f(b: 2, a: (+ x 1))", "", ""},
+		{"f(a, b: a);", "f(a, b: a)", "", ""},
+		{"f(b: 2, 1);", "", "命名参数之后不能有位置参数。", "1"},
+		{"f(a: 1, a: 2);", "", "重复的命名参数 'a'。", "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			errors.SetSinks()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+
+			if tt.message != "" {
+				diagnostics := errors.Diagnostics()
+				if len(diagnostics) == 0 || diagnostics[0].Message != tt.message || diagnostics[0].Token.Lexeme != tt.lexeme {
+					t.Errorf("期望在 %q 处报告错误 %q，实际: %v", tt.lexeme, tt.message, diagnostics)
+				}
+				return
+			}
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+			if result := ast.NewAstPrinter().Print(statements[0].(*ast.Expression).Expr); result != tt.expected {
+				t.Errorf("期望 %q，实际 %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// 测试插值字符串的解析，插值表达式之后的字符串片段只能由扫描器生成，普通字符串不能充当插值的结尾
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		lexeme  string // 出错位置的词素
+	}{
+		{`"a${x}b${y}c";`, "", ""},
+		{`"外${"内${x}"}";`, "", ""},
+		{`"${1 + }";`, "期望表达式", `}"`},
+		{`"${1 + }" "x";`, "期望表达式", `}"`},
+		{`"${}";`, "插值表达式不能为空。", `}"`},
+		{`"${}${x}";`, "插值表达式不能为空。", "}${"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			errors.SetSinks()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+
+			if tt.message != "" {
+				diagnostics := errors.Diagnostics()
+				if len(diagnostics) == 0 || diagnostics[0].Message != tt.message || diagnostics[0].Token.Lexeme != tt.lexeme {
+					t.Errorf("期望在 %q 处报告错误 %q，实际: %v", tt.lexeme, tt.message, diagnostics)
+				}
+				return
+			}
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+			if _, ok := statements[0].(*ast.Expression).Expr.(*ast.Interpolation); !ok {
+				t.Errorf("期望插值表达式，实际: %T", statements[0].(*ast.Expression).Expr)
+			}
+		})
+	}
+}
+
+// 测试import和export语句的解析
+func TestImportExport(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string // 导入的模块名或导出的名称
+		message  string
+	}{
+		{`import "lib/math.lox" as math;`, "math", ""},
+		{"export var pi = 3.14;", "pi", ""},
+		{"export fun square(x) { return x * x; }", "square", ""},
+		{"export class Point {}", "Point", ""},
+		{`import "math.lox";`, "", "期望在模块路径后有 'as'。"},
+		{"import math as m;", "", "期望在 'import' 后有模块路径字符串。"},
+		{`import "math.lox" as;`, "", "期望在 'as' 后有模块名称。"},
+		{"export print 1;", "", "期望在 'export' 后有变量、函数或类声明。"},
+		{"export fun (x) => x;", "", "期望在 'export' 后有变量、函数或类声明。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			errors := error.NewErrorReporter()
+			errors.SetSinks()
+			tokens := scanner.NewScanner(tt.source, errors).ScanTokens()
+			statements := NewParser(tokens, errors).Parse()
+
+			if tt.message != "" {
+				diagnostics := errors.Diagnostics()
+				if len(diagnostics) == 0 || diagnostics[0].Message != tt.message {
+					t.Errorf("期望错误 %q，实际: %v", tt.message, diagnostics)
+				}
+				return
+			}
+			if errors.HasError() || len(statements) != 1 {
+				t.Fatalf("解析失败: %v", errors.Diagnostics())
+			}
+
+			var name string
+			switch stmt := statements[0].(type) {
+			case *ast.Import:
+				name = stmt.Name.Lexeme
+			case *ast.Export:
+				name = stmt.Name().Lexeme
+				if exports := ast.ExportedNames(statements); len(exports) != 1 || exports[0] != tt.expected {
+					t.Errorf("期望导出 [%s]，实际: %v", tt.expected, exports)
+				}
+			}
+			if name != tt.expected {
+				t.Errorf("期望名称 %q，实际 %q", tt.expected, name)
+			}
+		})
+	}
+}
+
 func TestParserErrorHandling(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -171,6 +438,82 @@ func TestParserErrorHandling(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "循环外的continue: continue;",
+			tokens: []*token.Token{
+				token.NewToken(token.CONTINUE, "continue", nil, 1),
+				token.NewToken(token.SEMICOLON, ";", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: true,
+		},
+		{
+			name: "循环外的break: break;",
+			tokens: []*token.Token{
+				token.NewToken(token.BREAK, "break", nil, 1),
+				token.NewToken(token.SEMICOLON, ";", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: true,
+		},
+		{
+			name: "函数内跳出外层循环: while (true) fun () { break; };",
+			tokens: []*token.Token{
+				token.NewToken(token.WHILE, "while", nil, 1),
+				token.NewToken(token.LEFT_PAREN, "(", nil, 1),
+				token.NewToken(token.TRUE, "true", nil, 1),
+				token.NewToken(token.RIGHT_PAREN, ")", nil, 1),
+				token.NewToken(token.FUN, "fun", nil, 1),
+				token.NewToken(token.LEFT_PAREN, "(", nil, 1),
+				token.NewToken(token.RIGHT_PAREN, ")", nil, 1),
+				token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+				token.NewToken(token.BREAK, "break", nil, 1),
+				token.NewToken(token.SEMICOLON, ";", nil, 1),
+				token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+				token.NewToken(token.SEMICOLON, ";", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: true,
+		},
+		{
+			name: "循环内的continue: while (true) continue;",
+			tokens: []*token.Token{
+				token.NewToken(token.WHILE, "while", nil, 1),
+				token.NewToken(token.LEFT_PAREN, "(", nil, 1),
+				token.NewToken(token.TRUE, "true", nil, 1),
+				token.NewToken(token.RIGHT_PAREN, ")", nil, 1),
+				token.NewToken(token.CONTINUE, "continue", nil, 1),
+				token.NewToken(token.SEMICOLON, ";", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: false,
+		},
+		{
+			name: "缺少catch和finally的try: try {}",
+			tokens: []*token.Token{
+				token.NewToken(token.TRY, "try", nil, 1),
+				token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+				token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: true,
+		},
+		{
+			name: "try-catch: try {} catch (e) {}",
+			tokens: []*token.Token{
+				token.NewToken(token.TRY, "try", nil, 1),
+				token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+				token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+				token.NewToken(token.CATCH, "catch", nil, 1),
+				token.NewToken(token.LEFT_PAREN, "(", nil, 1),
+				token.NewToken(token.IDENTIFIER, "e", nil, 1),
+				token.NewToken(token.RIGHT_PAREN, ")", nil, 1),
+				token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+				token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+				token.NewToken(token.EOF, "", nil, 1),
+			},
+			expectErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -189,3 +532,113 @@ func TestParserErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestParseClass(t *testing.T) {
+	// class B < A { init(x) { this.x = x; } }
+	tokens := []*token.Token{
+		token.NewToken(token.CLASS, "class", nil, 1),
+		token.NewToken(token.IDENTIFIER, "B", nil, 1),
+		token.NewToken(token.LESS, "<", nil, 1),
+		token.NewToken(token.IDENTIFIER, "A", nil, 1),
+		token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+		token.NewToken(token.IDENTIFIER, "init", nil, 1),
+		token.NewToken(token.LEFT_PAREN, "(", nil, 1),
+		token.NewToken(token.IDENTIFIER, "x", nil, 1),
+		token.NewToken(token.RIGHT_PAREN, ")", nil, 1),
+		token.NewToken(token.LEFT_BRACE, "{", nil, 1),
+		token.NewToken(token.THIS, "this", nil, 1),
+		token.NewToken(token.DOT, ".", nil, 1),
+		token.NewToken(token.IDENTIFIER, "x", nil, 1),
+		token.NewToken(token.EQUAL, "=", nil, 1),
+		token.NewToken(token.IDENTIFIER, "x", nil, 1),
+		token.NewToken(token.SEMICOLON, ";", nil, 1),
+		token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+		token.NewToken(token.RIGHT_BRACE, "}", nil, 1),
+		token.NewToken(token.EOF, "", nil, 1),
+	}
+
+	errors := error.NewErrorReporter()
+	statements := NewParser(tokens, errors).Parse()
+	if errors.HasError() {
+		t.Fatalf("解析过程中出现错误")
+	}
+
+	class, ok := statements[0].(*ast.Class)
+	if !ok {
+		t.Fatalf("期望类声明，但获得了 %T", statements[0])
+	}
+	if class.Name.Lexeme != "B" || class.Superclass == nil || class.Superclass.Name.Lexeme != "A" {
+		t.Errorf("类名或父类解析错误")
+	}
+	if len(class.Methods) != 1 || class.Methods[0].Name.Lexeme != "init" {
+		t.Fatalf("方法解析错误")
+	}
+
+	body := class.Methods[0].Body[0].(*ast.Expression)
+	result := ast.NewRpnPrinter().Print(body.Expr)
+	if result != "x this x .=" {
+		t.Errorf("属性赋值解析错误，实际: %s", result)
+	}
+}
+
+// 测试扫描器记录的列号和偏移，以及解析器为节点设置的区间
+func TestSpans(t *testing.T) {
+	source := "var a = \"名\" + (1 + 2) * xs[0];\nprint f(a, b).c;\nfor (;;) { break; }"
+	errors := error.NewErrorReporter()
+	tokens := scanner.NewScanner(source, errors).ScanTokens()
+
+	// 第二行的f: 列号按字符计数，偏移按字节计数
+	for _, tok := range tokens {
+		if tok.Lexeme == "f" {
+			if tok.Line != 2 || tok.Column != 7 || tok.Offset != strings.Index(source, "f(") || tok.Length != 1 {
+				t.Errorf("标记f的位置不正确: %+v", tok)
+			}
+		}
+	}
+
+	statements := NewParser(tokens, errors).Parse()
+	if errors.HasError() || len(statements) != 3 {
+		t.Fatalf("解析失败: %v", errors.Diagnostics())
+	}
+
+	text := func(node ast.Node) string {
+		span := node.Span()
+		return source[span.Offset:span.End()]
+	}
+
+	varStmt := statements[0].(*ast.Var)
+	sum := varStmt.Initializer.(*ast.Binary)
+	product := sum.Right.(*ast.Binary)
+	printStmt := statements[1].(*ast.Print)
+	get := printStmt.Expr.(*ast.Get)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{varStmt, "var a = \"名\" + (1 + 2) * xs[0];"},
+		{sum, "\"名\" + (1 + 2) * xs[0]"},
+		{sum.Left, "\"名\""},
+		{product, "(1 + 2) * xs[0]"},
+		{product.Left, "(1 + 2)"},
+		{product.Left.(*ast.Grouping).Expression, "1 + 2"},
+		{product.Right, "xs[0]"},
+		{printStmt, "print f(a, b).c;"},
+		{get, "f(a, b).c"},
+		{get.Object, "f(a, b)"},
+		{statements[2], "for (;;) { break; }"},
+	}
+
+	for _, tt := range tests {
+		if actual := text(tt.node); actual != tt.expected {
+			t.Errorf("%T的区间期望覆盖%q，实际: %q", tt.node, tt.expected, actual)
+		}
+	}
+
+	if span := varStmt.Span(); span.Line != 1 || span.Column != 1 {
+		t.Errorf("变量声明的起始位置不正确: %+v", span)
+	}
+	if span := product.Right.Span(); span.Column != 25 {
+		t.Errorf("xs[0]的列号期望25，实际: %d", span.Column)
+	}
+}
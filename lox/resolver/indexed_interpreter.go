@@ -1,23 +1,19 @@
 package resolver
 
 import (
+	"context"
 	"fmt"
-	"math"
-	"strconv"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/environment"
 	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/interpreter"
 	"github.com/aixiasang/goLox/lox/token"
 )
 
-// ReturnValue 表示return语句的返回值
-type ReturnValue struct {
-	Value interface{}
-}
-
-// BreakException 表示遇到break语句的异常
-type BreakException struct{}
-
 // IndexedEnvironment 是一个基于数组的环境，用于高效地访问变量
 type IndexedEnvironment struct {
 	values    []interface{}       // 当前作用域中的值
@@ -69,54 +65,126 @@ func (e *IndexedEnvironment) ancestor(depth int) *IndexedEnvironment {
 }
 
 // IndexedInterpreter 使用索引访问变量的解释器
+// 局部变量通过(深度，索引)在数组环境中定位，全局变量仍然按名称查找
 type IndexedInterpreter struct {
 	errorReporter error.Reporter
-	environment   *IndexedEnvironment
-	globals       *IndexedEnvironment
+	environment   *IndexedEnvironment      // 当前局部环境，在全局作用域时为nil
+	globals       *environment.Environment // 当前执行的代码所属模块的全局环境
+	builtins      *environment.Environment // 内置函数，是所有模块全局环境的外层环境
+	importer      interpreter.Importer     // 加载import语句导入的模块
 	locals        map[ast.Expr]VarLocation
+	cancellation  interpreter.Cancellation // 执行取消检查
+	callStack     interpreter.CallStack    // 调用栈
+	stdout        io.Writer                // print语句的输出目标
 }
 
 // NewIndexedInterpreter 创建一个新的索引优化解释器
 func NewIndexedInterpreter(errorReporter error.Reporter) *IndexedInterpreter {
-	globals := NewIndexedEnvironment(nil)
-
-	// 添加内置函数和变量
-	// 注意: 这种情况下，全局变量和函数仍然需要使用名称查找
+	// 添加内置函数，与树遍历解释器保持一致
+	builtins := environment.NewEnvironment()
+	interpreter.DefineNatives(builtins)
 
-	interpreter := &IndexedInterpreter{
+	return &IndexedInterpreter{
 		errorReporter: errorReporter,
-		environment:   globals,
-		globals:       globals,
+		environment:   nil,
+		globals:       environment.NewEnclosedEnvironment(builtins),
+		builtins:      builtins,
 		locals:        make(map[ast.Expr]VarLocation),
+		stdout:        os.Stdout,
 	}
-
-	return interpreter
 }
 
-// SetLocations 设置变量位置信息
+// SetLocations 合并变量位置信息
+// 在REPL中每次输入都会单独解析，因此需要保留之前输入中的位置信息
 func (i *IndexedInterpreter) SetLocations(locals map[ast.Expr]VarLocation) {
-	i.locals = locals
+	for expr, location := range locals {
+		i.locals[expr] = location
+	}
+}
+
+// SetOutput 设置print语句的输出目标
+func (i *IndexedInterpreter) SetOutput(w io.Writer) {
+	i.stdout = w
+}
+
+// SetMaxCallDepth 设置最大调用深度，limit小于等于0时使用interpreter.DefaultMaxCallDepth
+func (i *IndexedInterpreter) SetMaxCallDepth(limit int) {
+	i.callStack.SetLimit(limit)
+}
+
+// DefineNative 注册一个由Go实现的内置函数，主脚本和所有模块都可以调用
+func (i *IndexedInterpreter) DefineNative(name string, arity int, fn interpreter.NativeFunc) {
+	i.builtins.Define(name, interpreter.NewNativeFunction(name, arity, fn))
+}
+
+// SetImporter 设置加载import语句导入的模块的函数
+func (i *IndexedInterpreter) SetImporter(importer interpreter.Importer) {
+	i.importer = importer
+}
+
+// ExecuteModule 在新的全局环境中执行模块的顶层语句，返回执行完毕的模块
+// at为导入模块的位置，模块执行期间作为调用栈中的一帧
+func (i *IndexedInterpreter) ExecuteModule(name string, statements []ast.Stmt, at *token.Token) *interpreter.Module {
+	module := interpreter.NewModule(name, environment.NewEnclosedEnvironment(i.builtins), ast.ExportedNames(statements))
+
+	previousEnvironment, previousGlobals := i.environment, i.globals
+	defer func() {
+		i.environment, i.globals = previousEnvironment, previousGlobals
+	}()
+	i.environment, i.globals = nil, module.Globals()
+
+	i.callStack.Push(module, at)
+	for _, stmt := range statements {
+		if completion := i.execute(stmt); completion != nil {
+			if message := completion.OutsideLoopMessage(); message != "" {
+				panic(error.RuntimeError{Message: message})
+			}
+			break
+		}
+	}
+	i.callStack.Pop()
+	return module
 }
 
 // Interpret 解释执行语句列表
-func (i *IndexedInterpreter) Interpret(statements []ast.Stmt) {
+// 如果最后一条语句是表达式语句，返回其值
+func (i *IndexedInterpreter) Interpret(statements []ast.Stmt) (result interface{}) {
 	defer i.handlePanic()
 
-	for _, stmt := range statements {
-		i.execute(stmt)
+	for index, stmt := range statements {
+		if expr, ok := stmt.(*ast.Expression); ok && index == len(statements)-1 {
+			return i.evaluate(expr.Expr)
+		}
+		if completion := i.execute(stmt); completion != nil {
+			// break或continue超出循环范围，return已经由静态检查排除
+			if message := completion.OutsideLoopMessage(); message != "" {
+				i.errorReporter.ReportRuntimeError(error.RuntimeError{Message: message})
+			}
+			return nil
+		}
 	}
+	return nil
 }
 
-// execute 执行一条语句
-func (i *IndexedInterpreter) execute(stmt ast.Stmt) {
-	stmt.Accept(i)
+// SetContext 设置用于取消执行的上下文
+func (i *IndexedInterpreter) SetContext(ctx context.Context) {
+	i.cancellation.Set(ctx)
+}
+
+// execute 执行一条语句，返回其执行结果，正常执行完毕时为nil
+func (i *IndexedInterpreter) execute(stmt ast.Stmt) *interpreter.Completion {
+	if completion, ok := stmt.Accept(i).(*interpreter.Completion); ok {
+		return completion
+	}
+	return nil
 }
 
 // executeBlock 在给定环境中执行语句块
-func (i *IndexedInterpreter) executeBlock(statements []ast.Stmt, env *IndexedEnvironment) {
+// 遇到break、continue或return时立即停止，并把执行结果交给外层处理
+func (i *IndexedInterpreter) executeBlock(statements []ast.Stmt, env *IndexedEnvironment) *interpreter.Completion {
 	previous := i.environment
 
-	// 恢复原来的环境，即使panic也要确保恢复
+	// 恢复原来的环境，运行时错误的panic穿过时也要确保恢复
 	defer func() {
 		i.environment = previous
 	}()
@@ -124,22 +192,83 @@ func (i *IndexedInterpreter) executeBlock(statements []ast.Stmt, env *IndexedEnv
 	i.environment = env
 
 	for _, statement := range statements {
-		i.execute(statement)
+		if completion := i.execute(statement); completion != nil {
+			return completion
+		}
 	}
+	return nil
+}
+
+// VisitThrowStmt 处理throw语句
+func (i *IndexedInterpreter) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	panic(interpreter.NewThrow(stmt.Keyword, i.evaluate(stmt.Value)))
+}
+
+// VisitTryStmt 处理try语句
+// finally块总会执行；它以break、continue或return结束时，会取代try块和catch块的结果以及未捕获的异常
+func (i *IndexedInterpreter) VisitTryStmt(stmt *ast.Try) interface{} {
+	completion, thrown := i.executeProtected(stmt.Body.Statements, NewEnclosedIndexedEnvironment(i.environment))
+	if thrown != nil && stmt.Catch != nil {
+		environment := NewEnclosedIndexedEnvironment(i.environment)
+		environment.Define(interpreter.CaughtValue(*thrown))
+		completion, thrown = i.executeProtected(stmt.Catch.Statements, environment)
+	}
+
+	if stmt.Finally != nil {
+		if finally := i.execute(stmt.Finally); finally != nil {
+			return finally
+		}
+	}
+
+	if thrown != nil {
+		panic(*thrown)
+	}
+	return completion
+}
+
+// executeProtected 执行语句块，并捕获其中抛出的运行时错误
+// 捕获时记录调用栈，然后丢弃错误发生处到当前位置之间的栈帧
+func (i *IndexedInterpreter) executeProtected(statements []ast.Stmt, environment *IndexedEnvironment) (completion *interpreter.Completion, thrown *error.RuntimeError) {
+	depth := i.callStack.Depth()
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeError, ok := r.(error.RuntimeError)
+			if !ok || runtimeError.Fatal {
+				panic(r)
+			}
+			if runtimeError.Trace == nil {
+				runtimeError.Trace = i.callStack.Trace(runtimeError.Token)
+			}
+			i.callStack.Truncate(depth)
+			thrown = &runtimeError
+		}
+	}()
+
+	return i.executeBlock(statements, environment), nil
+}
+
+// VisitImportStmt 处理import语句，加载模块并绑定到变量
+func (i *IndexedInterpreter) VisitImportStmt(stmt *ast.Import) interface{} {
+	module := interpreter.ImportModule(i.importer, stmt.Path)
+	i.define(stmt.Name, module)
+	return nil
+}
+
+// VisitExportStmt 处理export语句，被导出的声明照常执行
+func (i *IndexedInterpreter) VisitExportStmt(stmt *ast.Export) interface{} {
+	return i.execute(stmt.Declaration)
 }
 
 // handlePanic 处理解释过程中的异常
 func (i *IndexedInterpreter) handlePanic() {
 	if r := recover(); r != nil {
 		if runtimeError, ok := r.(error.RuntimeError); ok {
-			// 报告运行时错误
-			i.errorReporter.Error(runtimeError.Token, 0, runtimeError.Message)
-		} else if _, ok := r.(BreakException); ok {
-			// break语句超出循环范围
-			i.errorReporter.ReportError(0, "Break语句只能在循环内部使用。")
-		} else if _, ok := r.(ReturnValue); ok {
-			// 返回值流动到最顶层
-			return
+			// 栈帧在panic穿过时保留了下来，报告运行时错误时附带调用栈
+			if runtimeError.Trace == nil {
+				runtimeError.Trace = i.callStack.Trace(runtimeError.Token)
+			}
+			i.callStack.Truncate(0)
+			i.errorReporter.ReportRuntimeError(runtimeError)
 		} else {
 			// 重新抛出其他异常
 			panic(r)
@@ -147,22 +276,30 @@ func (i *IndexedInterpreter) handlePanic() {
 	}
 }
 
+// define 在当前作用域中定义变量
+func (i *IndexedInterpreter) define(name *token.Token, value interface{}) {
+	if i.environment == nil {
+		i.globals.Define(name.Lexeme, value)
+		return
+	}
+
+	// 局部变量按声明顺序追加，与解析器分配的索引一致
+	i.environment.Define(value)
+}
+
 // lookUpVariable 查找变量的值
 func (i *IndexedInterpreter) lookUpVariable(name *token.Token, expr ast.Expr) interface{} {
 	if location, ok := i.locals[expr]; ok {
 		return i.environment.GetAt(location.Depth, location.Index)
 	}
 
-	// 如果不是局部变量，可能是全局变量，但当前实现中没有全局变量表
-	// 所以返回错误
-	i.errorReporter.Error(name, 0, "未定义的变量。")
-	return nil
+	// 如果不是局部变量，则按名称在全局环境中查找
+	return i.globals.Get(name)
 }
 
 // VisitBlockStmt 处理代码块语句
 func (i *IndexedInterpreter) VisitBlockStmt(stmt *ast.Block) interface{} {
-	i.executeBlock(stmt.Statements, NewEnclosedIndexedEnvironment(i.environment))
-	return nil
+	return i.executeBlock(stmt.Statements, NewEnclosedIndexedEnvironment(i.environment))
 }
 
 // VisitExpressionStmt 处理表达式语句
@@ -174,9 +311,9 @@ func (i *IndexedInterpreter) VisitExpressionStmt(stmt *ast.Expression) interface
 // VisitIfStmt 处理条件语句
 func (i *IndexedInterpreter) VisitIfStmt(stmt *ast.If) interface{} {
 	if i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.ThenBranch)
+		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		i.execute(stmt.ElseBranch)
+		return i.execute(stmt.ElseBranch)
 	}
 	return nil
 }
@@ -184,7 +321,7 @@ func (i *IndexedInterpreter) VisitIfStmt(stmt *ast.If) interface{} {
 // VisitPrintStmt 处理打印语句
 func (i *IndexedInterpreter) VisitPrintStmt(stmt *ast.Print) interface{} {
 	value := i.evaluate(stmt.Expr)
-	fmt.Println(i.stringify(value))
+	fmt.Fprintln(i.stdout, interpreter.Stringify(value))
 	return nil
 }
 
@@ -196,34 +333,38 @@ func (i *IndexedInterpreter) VisitVarStmt(stmt *ast.Var) interface{} {
 		value = i.evaluate(stmt.Initializer)
 	}
 
-	// 变量定义返回索引
-	i.environment.Define(value)
+	i.define(stmt.Name, value)
 	return nil
 }
 
 // VisitWhileStmt 处理循环语句
 func (i *IndexedInterpreter) VisitWhileStmt(stmt *ast.While) interface{} {
-	defer func() {
-		// 捕获break异常，但不做处理，仅用于跳出循环
-		if r := recover(); r != nil {
-			// 如果是BreakException，就直接吞掉
-			if _, ok := r.(BreakException); !ok {
-				// 其他类型的异常继续向上抛
-				panic(r)
+	for i.isTruthy(i.evaluate(stmt.Condition)) {
+		if completion := i.execute(stmt.Body); completion != nil {
+			if completion.Kind == interpreter.CompletionBreak {
+				break
+			}
+			// return和尾调用交给外层的函数调用处理，continue继续执行更新表达式
+			if completion.Kind != interpreter.CompletionContinue {
+				return completion
 			}
 		}
-	}()
-
-	for i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.Body)
+		if stmt.Increment != nil {
+			i.evaluate(stmt.Increment)
+		}
+		i.cancellation.Check(nil)
 	}
 	return nil
 }
 
 // VisitBreakStmt 处理break语句
 func (i *IndexedInterpreter) VisitBreakStmt(stmt *ast.Break) interface{} {
-	// 通过抛出异常来跳出循环
-	panic(BreakException{})
+	return interpreter.BreakCompletion
+}
+
+// VisitContinueStmt 处理continue语句
+func (i *IndexedInterpreter) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	return interpreter.ContinueCompletion
 }
 
 // VisitFunctionStmt 处理函数声明语句
@@ -231,22 +372,72 @@ func (i *IndexedInterpreter) VisitFunctionStmt(stmt *ast.Function) interface{} {
 	function := &LoxFunction{
 		declaration: stmt,
 		closure:     i.environment,
+		globals:     i.globals,
 		interpreter: i,
 	}
 
-	i.environment.Define(function)
+	i.define(stmt.Name, function)
+	return nil
+}
+
+// VisitClassStmt 处理类声明语句
+func (i *IndexedInterpreter) VisitClassStmt(stmt *ast.Class) interface{} {
+	var superclass *LoxClass
+	if stmt.Superclass != nil {
+		class, ok := i.evaluate(stmt.Superclass).(*LoxClass)
+		if !ok {
+			panic(error.RuntimeError{Token: stmt.Superclass.Name, Message: "父类必须是一个类。"})
+		}
+		superclass = class
+	}
+
+	closure := i.environment
+	if superclass != nil {
+		closure = NewEnclosedIndexedEnvironment(i.environment)
+		closure.Define(superclass)
+	}
+
+	methods := make(map[string]*LoxFunction)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = &LoxFunction{
+			declaration:   method,
+			closure:       closure,
+			globals:       i.globals,
+			interpreter:   i,
+			isInitializer: method.Name.Lexeme == "init",
+		}
+	}
+
+	class := &LoxClass{
+		name:        stmt.Name.Lexeme,
+		superclass:  superclass,
+		methods:     methods,
+		interpreter: i,
+	}
+
+	i.define(stmt.Name, class)
 	return nil
 }
 
 // VisitReturnStmt 处理return语句
 func (i *IndexedInterpreter) VisitReturnStmt(stmt *ast.Return) interface{} {
+	// 尾调用Lox函数时不在这里调用，而是交给外层的LoxFunction.Call继续执行
+	if stmt.TailCall {
+		call := stmt.Value.(*ast.Call)
+		callee, arguments := i.evaluateCall(call)
+		if function, ok := callee.(*LoxFunction); ok {
+			interpreter.CheckArity(function.Arity(), call.Paren, len(arguments))
+			return interpreter.NewTailCallCompletion(function, arguments)
+		}
+		return interpreter.NewReturnCompletion(i.call(call.Paren, callee, arguments))
+	}
+
 	var value interface{} = nil
 	if stmt.Value != nil {
 		value = i.evaluate(stmt.Value)
 	}
 
-	// 通过抛出ReturnValue异常来实现return语句
-	panic(ReturnValue{Value: value})
+	return interpreter.NewReturnCompletion(value)
 }
 
 // evaluate 求值表达式
@@ -261,7 +452,7 @@ func (i *IndexedInterpreter) VisitAssignExpr(expr *ast.Assign) interface{} {
 	if location, ok := i.locals[expr]; ok {
 		i.environment.AssignAt(location.Depth, location.Index, value)
 	} else {
-		// 全局变量处理逻辑
+		i.globals.Assign(expr.Name, value)
 	}
 
 	return value
@@ -285,66 +476,14 @@ func (i *IndexedInterpreter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
 // VisitUnaryExpr 处理一元表达式
 func (i *IndexedInterpreter) VisitUnaryExpr(expr *ast.Unary) interface{} {
 	right := i.evaluate(expr.Right)
-
-	switch expr.Operator.Type {
-	case token.MINUS:
-		i.checkNumberOperand(expr.Operator, right)
-		return -i.asNumber(right)
-	case token.BANG:
-		return !i.isTruthy(right)
-	}
-
-	// 不可达
-	return nil
+	return interpreter.UnaryOp(expr.Operator, right)
 }
 
 // VisitBinaryExpr 处理二元表达式
 func (i *IndexedInterpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
 	left := i.evaluate(expr.Left)
 	right := i.evaluate(expr.Right)
-
-	switch expr.Operator.Type {
-	case token.MINUS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) - i.asNumber(right)
-	case token.SLASH:
-		i.checkNumberOperands(expr.Operator, left, right)
-		rightNum := i.asNumber(right)
-		if rightNum == 0 {
-			panic(error.RuntimeError{Token: expr.Operator, Message: "除数不能为零。"})
-		}
-		return i.asNumber(left) / rightNum
-	case token.STAR:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) * i.asNumber(right)
-	case token.PLUS:
-		if i.isNumber(left) && i.isNumber(right) {
-			return i.asNumber(left) + i.asNumber(right)
-		}
-		if i.isString(left) || i.isString(right) {
-			return i.stringify(left) + i.stringify(right)
-		}
-		panic(error.RuntimeError{Token: expr.Operator, Message: "'+'运算符只能用于数字或字符串。"})
-	case token.GREATER:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) > i.asNumber(right)
-	case token.GREATER_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) >= i.asNumber(right)
-	case token.LESS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) < i.asNumber(right)
-	case token.LESS_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return i.asNumber(left) <= i.asNumber(right)
-	case token.BANG_EQUAL:
-		return !i.isEqual(left, right)
-	case token.EQUAL_EQUAL:
-		return i.isEqual(left, right)
-	}
-
-	// 不可达
-	return nil
+	return interpreter.BinaryOp(expr.Operator, left, right)
 }
 
 // VisitLogicalExpr 处理逻辑表达式
@@ -366,171 +505,280 @@ func (i *IndexedInterpreter) VisitLogicalExpr(expr *ast.Logical) interface{} {
 
 // VisitCallExpr 处理函数调用表达式
 func (i *IndexedInterpreter) VisitCallExpr(expr *ast.Call) interface{} {
+	callee, arguments := i.evaluateCall(expr)
+	return i.call(expr.Paren, callee, arguments)
+}
+
+// evaluateCall 求值调用表达式的被调用者和参数
+func (i *IndexedInterpreter) evaluateCall(expr *ast.Call) (interface{}, []interface{}) {
+	i.cancellation.Check(expr.Paren)
+
 	callee := i.evaluate(expr.Callee)
 
 	arguments := make([]interface{}, len(expr.Arguments))
 	for j, argument := range expr.Arguments {
 		arguments[j] = i.evaluate(argument)
 	}
+	if len(expr.Names) > 0 {
+		arguments = interpreter.ArrangeArguments(callee, expr.Paren, arguments, expr.Names)
+	}
+	return callee, arguments
+}
 
-	if function, ok := callee.(LoxCallable); ok {
-		if len(arguments) != function.Arity() {
-			i.errorReporter.Error(expr.Paren, 0,
-				fmt.Sprintf("期望 %d 个参数但得到 %d 个。", function.Arity(), len(arguments)))
-			return nil
-		}
-
-		return function.Call(i, arguments)
+// call 以给定参数调用callee
+func (i *IndexedInterpreter) call(paren *token.Token, callee interface{}, arguments []interface{}) interface{} {
+	switch function := callee.(type) {
+	case LoxCallable:
+		interpreter.CheckArity(function.Arity(), paren, len(arguments))
+		i.callStack.Push(frameCallee(function), paren)
+		result := function.Call(i, arguments)
+		i.callStack.Pop()
+		return result
+	case interpreter.Native:
+		return interpreter.CallNative(function, paren, arguments)
 	}
 
-	i.errorReporter.Error(expr.Paren, 0, "只能调用函数和类。")
-	return nil
+	panic(error.RuntimeError{Token: paren, Message: "只能调用函数和类。"})
 }
 
-// VisitTernaryExpr 处理三元表达式
-func (i *IndexedInterpreter) VisitTernaryExpr(expr *ast.Ternary) interface{} {
-	condition := i.evaluate(expr.Condition)
-
-	if i.isTruthy(condition) {
-		return i.evaluate(expr.ThenBranch)
-	} else {
-		return i.evaluate(expr.ElseBranch)
+// frameCallee 返回调用栈中代表callee的函数，调用类时实际执行的是它的初始化方法
+func frameCallee(callee LoxCallable) LoxCallable {
+	if class, ok := callee.(*LoxClass); ok {
+		if initializer := class.FindMethod("init"); initializer != nil {
+			return initializer
+		}
 	}
+	return callee
 }
 
-// 辅助方法
+// bindParameters 在函数环境env中依次定义参数
+// 没有传入或以Missing占位的参数在env中对默认值求值，因此默认值可以引用它之前的参数；剩余参数收集多余的参数为列表
+func (i *IndexedInterpreter) bindParameters(declaration *ast.Function, env *IndexedEnvironment, arguments []interface{}) {
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+	}()
+	i.environment = env
 
-// isTruthy 确定值是否为"真"
-func (i *IndexedInterpreter) isTruthy(value interface{}) bool {
-	if value == nil {
-		return false
-	}
-	if b, ok := value.(bool); ok {
-		return b
+	for j := range declaration.Params {
+		var value interface{}
+		switch {
+		case declaration.IsRest(j):
+			value = interpreter.RestArguments(arguments, j)
+		case j < len(arguments) && arguments[j] != interpreter.Missing:
+			value = arguments[j]
+		default:
+			value = i.evaluate(declaration.Default(j))
+		}
+		env.Define(value)
 	}
-	return true
 }
 
-// isEqual 比较两个值是否相等
-func (i *IndexedInterpreter) isEqual(a, b interface{}) bool {
-	if a == nil && b == nil {
-		return true
-	}
-	if a == nil {
-		return false
+// VisitGetExpr 处理属性访问表达式
+func (i *IndexedInterpreter) VisitGetExpr(expr *ast.Get) interface{} {
+	object := i.evaluate(expr.Object)
+	switch object := object.(type) {
+	case *LoxInstance:
+		return object.Get(expr.Name)
+	case *interpreter.List:
+		return object.Get(expr.Name)
+	case *interpreter.Map:
+		return object.Get(expr.Name)
+	case *interpreter.ErrorValue:
+		return object.Get(expr.Name)
+	case *interpreter.Module:
+		return object.Get(expr.Name)
+	case string:
+		return interpreter.StringMethod(object, expr.Name)
 	}
 
-	return a == b
+	panic(error.RuntimeError{Token: expr.Name, Message: "只有实例才有属性。"})
 }
 
-// checkNumberOperand 检查操作数是否为数字
-func (i *IndexedInterpreter) checkNumberOperand(operator *token.Token, operand interface{}) {
-	if i.isNumber(operand) {
-		return
+// VisitSetExpr 处理属性赋值表达式
+func (i *IndexedInterpreter) VisitSetExpr(expr *ast.Set) interface{} {
+	object := i.evaluate(expr.Object)
+
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(error.RuntimeError{Token: expr.Name, Message: "只有实例才有字段。"})
 	}
-	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+
+	value := i.evaluate(expr.Value)
+	instance.Set(expr.Name, value)
+	return value
 }
 
-// checkNumberOperands 检查操作数是否为数字
-func (i *IndexedInterpreter) checkNumberOperands(operator *token.Token, left, right interface{}) {
-	if i.isNumber(left) && i.isNumber(right) {
-		return
+// VisitListExpr 处理列表字面量表达式
+func (i *IndexedInterpreter) VisitListExpr(expr *ast.List) interface{} {
+	elements := make([]interpreter.Value, len(expr.Elements))
+	for j, element := range expr.Elements {
+		elements[j] = i.evaluate(element)
 	}
-	panic(error.RuntimeError{Token: operator, Message: "操作数必须是数字。"})
+	return interpreter.NewList(elements)
 }
 
-// isNumber 检查值是否为数字
-func (i *IndexedInterpreter) isNumber(value interface{}) bool {
-	_, ok := value.(float64)
-	return ok
+// VisitMapExpr 处理映射字面量表达式，重复的键以后出现的值为准
+func (i *IndexedInterpreter) VisitMapExpr(expr *ast.Map) interface{} {
+	m := interpreter.NewMap()
+	for j, keyExpr := range expr.Keys {
+		key := i.evaluate(keyExpr)
+		value := i.evaluate(expr.Values[j])
+		interpreter.SetIndex(expr.Brace, m, key, value)
+	}
+	return m
 }
 
-// asNumber 将值转换为数字
-func (i *IndexedInterpreter) asNumber(value interface{}) float64 {
-	if num, ok := value.(float64); ok {
-		return num
+// VisitInterpolationExpr 处理插值字符串表达式，每一部分的值按print的格式转换为字符串后拼接
+func (i *IndexedInterpreter) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	var builder strings.Builder
+	for _, part := range expr.Parts {
+		builder.WriteString(interpreter.Stringify(i.evaluate(part)))
 	}
-	if str, ok := value.(string); ok {
-		if num, err := strconv.ParseFloat(str, 64); err == nil {
-			return num
-		}
+	return builder.String()
+}
+
+// VisitLambdaExpr 处理匿名函数表达式，创建捕获当前环境的闭包
+func (i *IndexedInterpreter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	return &LoxFunction{
+		declaration: expr.Function,
+		closure:     i.environment,
+		globals:     i.globals,
+		interpreter: i,
 	}
-	return 0
 }
 
-// isString 检查值是否为字符串
-func (i *IndexedInterpreter) isString(value interface{}) bool {
-	_, ok := value.(string)
-	return ok
+// VisitIndexExpr 处理下标访问表达式
+func (i *IndexedInterpreter) VisitIndexExpr(expr *ast.Index) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	return interpreter.GetIndex(expr.Bracket, object, index)
 }
 
-// stringify 将值转换为字符串
-func (i *IndexedInterpreter) stringify(value interface{}) string {
-	if value == nil {
-		return "nil"
+// VisitIndexSetExpr 处理下标赋值表达式
+func (i *IndexedInterpreter) VisitIndexSetExpr(expr *ast.IndexSet) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	value := i.evaluate(expr.Value)
+	return interpreter.SetIndex(expr.Bracket, object, index, value)
+}
+
+// VisitThisExpr 处理this表达式
+func (i *IndexedInterpreter) VisitThisExpr(expr *ast.This) interface{} {
+	return i.lookUpVariable(expr.Keyword, expr)
+}
+
+// VisitSuperExpr 处理super表达式
+func (i *IndexedInterpreter) VisitSuperExpr(expr *ast.Super) interface{} {
+	location := i.locals[expr]
+	superclass := i.environment.GetAt(location.Depth, 0).(*LoxClass)
+
+	// this总是位于super所在环境的内一层
+	object := i.environment.GetAt(location.Depth-1, 0).(*LoxInstance)
+
+	method := superclass.FindMethod(expr.Method.Lexeme)
+	if method == nil {
+		panic(error.RuntimeError{
+			Token:   expr.Method,
+			Message: fmt.Sprintf("未定义的属性 '%s'。", expr.Method.Lexeme),
+		})
 	}
 
-	if num, ok := value.(float64); ok {
-		text := fmt.Sprintf("%g", num)
-		if math.Floor(num) == num {
-			text = fmt.Sprintf("%.0f", num)
-		}
-		return text
+	return method.Bind(object)
+}
+
+// VisitTernaryExpr 处理三元表达式
+func (i *IndexedInterpreter) VisitTernaryExpr(expr *ast.Ternary) interface{} {
+	condition := i.evaluate(expr.Condition)
+
+	if i.isTruthy(condition) {
+		return i.evaluate(expr.ThenBranch)
+	} else {
+		return i.evaluate(expr.ElseBranch)
 	}
+}
 
-	return fmt.Sprintf("%v", value)
+// 辅助方法
+
+// isTruthy 确定值是否为"真"
+func (i *IndexedInterpreter) isTruthy(value interface{}) bool {
+	return interpreter.IsTruthy(value)
 }
 
-// LoxCallable 接口定义了可调用的Lox对象
+// LoxCallable 接口定义了可调用的Lox对象，与interpreter.Callable相对应
 type LoxCallable interface {
+	// Call 调用函数
 	Call(interpreter *IndexedInterpreter, arguments []interface{}) interface{}
-	Arity() int
+	// Arity 返回函数接受的参数个数范围
+	Arity() interpreter.ArityRange
+	// String 返回函数的字符串表示
+	String() string
 }
 
 // LoxFunction 表示Lox函数
 type LoxFunction struct {
 	declaration   *ast.Function
 	closure       *IndexedEnvironment
+	globals       *environment.Environment // 定义函数的模块的全局环境
 	interpreter   *IndexedInterpreter
 	isInitializer bool
 }
 
-// Arity 返回函数参数数量
-func (f *LoxFunction) Arity() int {
-	return len(f.declaration.Params)
+// Arity 返回函数接受的参数个数范围
+func (f *LoxFunction) Arity() interpreter.ArityRange {
+	return interpreter.FunctionArity(f.declaration)
+}
+
+// Parameters 返回可以按名称传入的参数
+func (f *LoxFunction) Parameters() interpreter.Parameters {
+	return interpreter.FunctionParameters(f.declaration)
 }
 
 // Call 调用函数
+// 函数体以尾调用结束时，在同一个循环中继续执行被调用的函数，尾递归因此只占用固定的Go调用栈
+// 函数中的全局变量属于定义它的模块，执行期间切换到该模块的全局环境
 func (f *LoxFunction) Call(interpreter *IndexedInterpreter, arguments []interface{}) interface{} {
-	environment := NewIndexedEnvironment(f.closure)
-
-	// 为函数的每个参数定义变量
-	for i := 0; i < len(f.declaration.Params); i++ {
-		environment.Define(arguments[i])
-	}
-
+	previous := interpreter.globals
 	defer func() {
-		if r := recover(); r != nil {
-			if returnValue, ok := r.(ReturnValue); ok {
-				if f.isInitializer {
-					// 如果是初始化方法，始终返回this
-					return
-				}
-				panic(returnValue)
+		interpreter.globals = previous
+	}()
+
+	function := f
+	for {
+		interpreter.globals = function.globals
+
+		environment := NewIndexedEnvironment(function.closure)
+		interpreter.bindParameters(function.declaration, environment, arguments)
+
+		// 执行函数体，return语句的返回值随执行结果一起返回
+		completion := interpreter.executeBlock(function.declaration.Body, environment)
+		if completion != nil {
+			if completion.IsTailCall() {
+				function = completion.Value.(*LoxFunction)
+				arguments = completion.Arguments
+				interpreter.callStack.ReplaceTop(function)
+				continue
+			}
+			if message := completion.OutsideLoopMessage(); message != "" {
+				panic(error.RuntimeError{Token: function.declaration.Name, Message: message})
 			}
-			// 重新抛出非返回值的panic
-			panic(r)
 		}
-	}()
 
-	interpreter.executeBlock(f.declaration.Body, environment)
+		if function.isInitializer {
+			// 初始化方法总是返回this
+			return function.closure.GetAt(0, 0)
+		}
 
-	if f.isInitializer {
-		// 返回this
-		return f.closure.GetAt(0, 0)
+		if completion == nil {
+			return nil
+		}
+		return completion.Value
 	}
+}
 
-	return nil
+// String 返回函数的字符串表示
+func (f *LoxFunction) String() string {
+	return "<fn " + f.declaration.Name.Lexeme + ">"
 }
 
 // Bind 将方法绑定到实例
@@ -541,6 +789,7 @@ func (f *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
 	return &LoxFunction{
 		declaration:   f.declaration,
 		closure:       environment,
+		globals:       f.globals,
 		interpreter:   f.interpreter,
 		isInitializer: f.isInitializer,
 	}
@@ -554,15 +803,24 @@ type LoxClass struct {
 	interpreter *IndexedInterpreter
 }
 
-// Arity 返回类构造函数参数数量
-func (c *LoxClass) Arity() int {
+// Arity 返回类构造函数接受的参数个数范围
+func (c *LoxClass) Arity() interpreter.ArityRange {
 	initializer := c.FindMethod("init")
 	if initializer == nil {
-		return 0
+		return interpreter.Exactly(0)
 	}
 	return initializer.Arity()
 }
 
+// Parameters 返回初始化方法中可以按名称传入的参数，没有初始化方法时没有参数
+func (c *LoxClass) Parameters() interpreter.Parameters {
+	initializer := c.FindMethod("init")
+	if initializer == nil {
+		return interpreter.Parameters{}
+	}
+	return initializer.Parameters()
+}
+
 // Call 调用类构造函数
 func (c *LoxClass) Call(interpreter *IndexedInterpreter, arguments []interface{}) interface{} {
 	instance := &LoxInstance{
@@ -578,6 +836,11 @@ func (c *LoxClass) Call(interpreter *IndexedInterpreter, arguments []interface{}
 	return instance
 }
 
+// String 返回类的字符串表示
+func (c *LoxClass) String() string {
+	return c.name
+}
+
 // FindMethod 查找类方法
 func (c *LoxClass) FindMethod(name string) *LoxFunction {
 	if method, ok := c.methods[name]; ok {
@@ -607,12 +870,18 @@ func (i *LoxInstance) Get(name *token.Token) interface{} {
 		return method.Bind(i)
 	}
 
-	i.class.interpreter.errorReporter.Error(name, 0,
-		fmt.Sprintf("未定义属性 '%s'。", name.Lexeme))
-	return nil
+	panic(error.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
 }
 
 // Set 设置实例属性
 func (i *LoxInstance) Set(name *token.Token, value interface{}) {
 	i.fields[name.Lexeme] = value
 }
+
+// String 返回实例的字符串表示
+func (i *LoxInstance) String() string {
+	return i.class.name + " instance"
+}
@@ -76,6 +76,22 @@ const (
 	FunctionNONE FunctionType = iota
 	// FunctionFUNCTION 普通函数上下文
 	FunctionFUNCTION
+	// FunctionMETHOD 类方法上下文
+	FunctionMETHOD
+	// FunctionINITIALIZER 类初始化方法(init)上下文
+	FunctionINITIALIZER
+)
+
+// ClassType 类上下文枚举
+type ClassType int
+
+const (
+	// ClassNONE 非类上下文
+	ClassNONE ClassType = iota
+	// ClassCLASS 普通类上下文
+	ClassCLASS
+	// ClassSUBCLASS 子类上下文
+	ClassSUBCLASS
 )
 
 // OptimizedResolver 优化的变量解析器
@@ -83,9 +99,10 @@ type OptimizedResolver struct {
 	errorReporter   error.Reporter
 	scopes          []map[string]VarInfo     // 作用域栈
 	currentScope    int                      // 当前作用域级别
-	variableCount   int                      // 当前作用域中的变量计数
 	locations       map[ast.Expr]VarLocation // 变量位置信息
 	currentFunction FunctionType             // 当前函数上下文
+	currentClass    ClassType                // 当前类上下文
+	tryDepth        int                      // 当前函数中包围当前语句的try块和catch块层数
 }
 
 // VarInfo 变量信息
@@ -107,9 +124,9 @@ func NewOptimizedResolver(errorReporter error.Reporter) *OptimizedResolver {
 		errorReporter:   errorReporter,
 		scopes:          make([]map[string]VarInfo, 0),
 		currentScope:    -1,
-		variableCount:   0,
 		locations:       make(map[ast.Expr]VarLocation),
 		currentFunction: FunctionNONE,
+		currentClass:    ClassNONE,
 	}
 }
 
@@ -117,15 +134,14 @@ func NewOptimizedResolver(errorReporter error.Reporter) *OptimizedResolver {
 func (r *OptimizedResolver) beginScope() {
 	r.scopes = append(r.scopes, make(map[string]VarInfo))
 	r.currentScope++
-	r.variableCount = 0
 }
 
 // endScope 结束当前作用域
 func (r *OptimizedResolver) endScope() {
-	// 检查未使用的变量
+	// 检查未使用的变量，与Resolver采用相同的忽略规则
 	scope := r.scopes[r.currentScope]
-	for name, info := range scope {
-		if !info.Used {
+	for _, name := range sortedNames(scope) {
+		if !scope[name].Used && !isIgnoredUnused(name) {
 			r.errorReporter.ReportError(0, fmt.Sprintf("局部变量 '%s' 已声明但从未使用", name))
 		}
 	}
@@ -148,9 +164,8 @@ func (r *OptimizedResolver) declare(name *token.Token) int {
 		return -1
 	}
 
-	// 分配一个新的索引
-	index := r.variableCount
-	r.variableCount++
+	// 分配一个新的索引，即该变量在运行时环境中的定义顺序
+	index := len(scope)
 
 	// 标记为"尚未初始化"且"未使用"
 	scope[name.Lexeme] = VarInfo{
@@ -206,18 +221,22 @@ func (r *OptimizedResolver) resolveLocal(expr ast.Expr, name *token.Token) {
 
 // resolveFunction 解析函数声明
 func (r *OptimizedResolver) resolveFunction(function *ast.Function, funcType FunctionType) {
-	enclosingFunction := r.currentFunction
-	r.currentFunction = funcType
+	enclosingFunction, enclosingTryDepth := r.currentFunction, r.tryDepth
+	r.currentFunction, r.tryDepth = funcType, 0
 
+	// 默认值在函数作用域中求值，只能引用它之前的参数
 	r.beginScope()
-	for _, param := range function.Params {
+	for i, param := range function.Params {
+		if value := function.Default(i); value != nil {
+			r.resolveExpr(value)
+		}
 		r.declare(param)
 		r.define(param)
 	}
 	r.ResolveStatements(function.Body)
 	r.endScope()
 
-	r.currentFunction = enclosingFunction
+	r.currentFunction, r.tryDepth = enclosingFunction, enclosingTryDepth
 }
 
 // ResolveStatements 解析一组语句
@@ -258,13 +277,7 @@ func (r *OptimizedResolver) VisitVarStmt(stmt *ast.Var) interface{} {
 
 // VisitVariableExpr 访问变量引用
 func (r *OptimizedResolver) VisitVariableExpr(expr *ast.Variable) interface{} {
-	if r.currentScope >= 0 {
-		scope := r.scopes[r.currentScope]
-		if info, exists := scope[expr.Name.Lexeme]; exists && !info.Initialized {
-			r.errorReporter.Error(expr.Name, 0, "不能在变量初始化中引用自身。")
-		}
-	}
-
+	// 自引用检查由resolveLocal完成
 	r.resolveLocal(expr, expr.Name)
 	return nil
 }
@@ -285,6 +298,59 @@ func (r *OptimizedResolver) VisitFunctionStmt(stmt *ast.Function) interface{} {
 	return nil
 }
 
+// VisitClassStmt 访问类声明
+func (r *OptimizedResolver) VisitClassStmt(stmt *ast.Class) interface{} {
+	enclosingClass := r.currentClass
+	r.currentClass = ClassCLASS
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
+			r.errorReporter.Error(stmt.Superclass.Name, 0, "类不能继承自身。")
+		}
+
+		r.currentClass = ClassSUBCLASS
+		r.resolveExpr(stmt.Superclass)
+
+		// super所在的作用域只包含父类，索引固定为0
+		r.beginScope()
+		r.defineImplicit("super")
+	}
+
+	// this所在的作用域由方法绑定时创建，索引固定为0
+	r.beginScope()
+	r.defineImplicit("this")
+
+	for _, method := range stmt.Methods {
+		declaration := FunctionMETHOD
+		if method.Name.Lexeme == "init" {
+			declaration = FunctionINITIALIZER
+		}
+		r.resolveFunction(method, declaration)
+	}
+
+	r.endScope()
+
+	if stmt.Superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
+	return nil
+}
+
+// defineImplicit 在当前作用域中定义隐式变量(this/super)，不参与未使用检查
+func (r *OptimizedResolver) defineImplicit(name string) {
+	scope := r.scopes[r.currentScope]
+	scope[name] = VarInfo{
+		Index:       len(scope),
+		Initialized: true,
+		Used:        true,
+	}
+}
+
 // VisitExpressionStmt 访问表达式语句
 func (r *OptimizedResolver) VisitExpressionStmt(stmt *ast.Expression) interface{} {
 	r.resolveExpr(stmt.Expr)
@@ -314,7 +380,12 @@ func (r *OptimizedResolver) VisitReturnStmt(stmt *ast.Return) interface{} {
 	}
 
 	if stmt.Value != nil {
+		if r.currentFunction == FunctionINITIALIZER {
+			r.errorReporter.Error(stmt.Keyword, 0, "不能在初始化方法中返回值。")
+		}
 		r.resolveExpr(stmt.Value)
+		// try块和catch块中的调用返回后还可能被捕获异常或执行finally块，不是尾调用
+		stmt.TailCall = r.tryDepth == 0 && isTailCall(stmt.Value)
 	}
 
 	return nil
@@ -324,6 +395,9 @@ func (r *OptimizedResolver) VisitReturnStmt(stmt *ast.Return) interface{} {
 func (r *OptimizedResolver) VisitWhileStmt(stmt *ast.While) interface{} {
 	r.resolveExpr(stmt.Condition)
 	r.resolveStmt(stmt.Body)
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
 	return nil
 }
 
@@ -332,6 +406,11 @@ func (r *OptimizedResolver) VisitBreakStmt(stmt *ast.Break) interface{} {
 	return nil
 }
 
+// VisitContinueStmt 访问continue语句
+func (r *OptimizedResolver) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	return nil
+}
+
 // VisitBinaryExpr 访问二元表达式
 func (r *OptimizedResolver) VisitBinaryExpr(expr *ast.Binary) interface{} {
 	r.resolveExpr(expr.Left)
@@ -382,7 +461,135 @@ func (r *OptimizedResolver) VisitTernaryExpr(expr *ast.Ternary) interface{} {
 	return nil
 }
 
+// VisitGetExpr 访问属性访问表达式
+func (r *OptimizedResolver) VisitGetExpr(expr *ast.Get) interface{} {
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+// VisitSetExpr 访问属性赋值表达式
+func (r *OptimizedResolver) VisitSetExpr(expr *ast.Set) interface{} {
+	r.resolveExpr(expr.Value)
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+// VisitListExpr 访问列表字面量表达式
+func (r *OptimizedResolver) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+// VisitMapExpr 访问映射字面量表达式
+func (r *OptimizedResolver) VisitMapExpr(expr *ast.Map) interface{} {
+	for j, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[j])
+	}
+	return nil
+}
+
+// VisitInterpolationExpr 访问插值字符串表达式
+func (r *OptimizedResolver) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		r.resolveExpr(part)
+	}
+	return nil
+}
+
+// VisitLambdaExpr 访问匿名函数表达式，与函数声明的作用域规则相同，只是不声明函数名
+func (r *OptimizedResolver) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	r.resolveFunction(expr.Function, FunctionFUNCTION)
+	return nil
+}
+
+// VisitIndexExpr 访问下标访问表达式
+func (r *OptimizedResolver) VisitIndexExpr(expr *ast.Index) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil
+}
+
+// VisitIndexSetExpr 访问下标赋值表达式
+func (r *OptimizedResolver) VisitIndexSetExpr(expr *ast.IndexSet) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+// VisitThisExpr 访问this表达式
+func (r *OptimizedResolver) VisitThisExpr(expr *ast.This) interface{} {
+	if r.currentClass == ClassNONE {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在类外部使用'this'。")
+		return nil
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+// VisitSuperExpr 访问super表达式
+func (r *OptimizedResolver) VisitSuperExpr(expr *ast.Super) interface{} {
+	if r.currentClass == ClassNONE {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在类外部使用'super'。")
+	} else if r.currentClass != ClassSUBCLASS {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在没有父类的类中使用'super'。")
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
 // GetLocations 获取所有变量的位置信息
 func (r *OptimizedResolver) GetLocations() map[ast.Expr]VarLocation {
 	return r.locations
 }
+
+// VisitThrowStmt 访问throw语句
+func (r *OptimizedResolver) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	r.resolveExpr(stmt.Value)
+	return nil
+}
+
+// VisitTryStmt 访问try语句，catch变量位于catch块外层的独立作用域中
+func (r *OptimizedResolver) VisitTryStmt(stmt *ast.Try) interface{} {
+	r.tryDepth++
+	r.resolveStmt(stmt.Body)
+	if stmt.Catch != nil {
+		r.beginScope()
+		r.declare(stmt.CatchName)
+		r.define(stmt.CatchName)
+		// catch变量常常只用于捕获异常，不参与未使用检查
+		if info, exists := r.scopes[r.currentScope][stmt.CatchName.Lexeme]; exists {
+			info.Used = true
+			r.scopes[r.currentScope][stmt.CatchName.Lexeme] = info
+		}
+		r.ResolveStatements(stmt.Catch.Statements)
+		r.endScope()
+	}
+	r.tryDepth--
+
+	if stmt.Finally != nil {
+		r.resolveStmt(stmt.Finally)
+	}
+	return nil
+}
+
+// VisitImportStmt 访问import语句，模块像变量一样绑定到名称
+func (r *OptimizedResolver) VisitImportStmt(stmt *ast.Import) interface{} {
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	return nil
+}
+
+// VisitExportStmt 访问export语句，只有顶层的声明可以导出
+func (r *OptimizedResolver) VisitExportStmt(stmt *ast.Export) interface{} {
+	if r.currentScope >= 0 {
+		r.errorReporter.Error(stmt.Keyword, 0, "只能导出顶层的声明。")
+	}
+	r.resolveStmt(stmt.Declaration)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/aixiasang/goLox/lox/ast"
+	"github.com/aixiasang/goLox/lox/error"
 	"github.com/aixiasang/goLox/lox/token"
 )
 
@@ -16,10 +17,18 @@ func (er *OptimizedTestErrorReporter) ReportError(line int, message string) {
 	er.errors = append(er.errors, message)
 }
 
+func (er *OptimizedTestErrorReporter) ReportErrorAt(span token.Span, message string) {
+	er.errors = append(er.errors, message)
+}
+
 func (er *OptimizedTestErrorReporter) HasError() bool {
 	return len(er.errors) > 0
 }
 
+func (er *OptimizedTestErrorReporter) ReportRuntimeError(err error.RuntimeError) {
+	er.errors = append(er.errors, err.Message)
+}
+
 func (er *OptimizedTestErrorReporter) ResetError() {
 	er.errors = nil
 }
@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/aixiasang/goLox/lox/ast"
@@ -26,6 +27,8 @@ type Resolver struct {
 	errorReporter   error.Reporter
 	scopes          []map[string]bool // 作用域栈
 	currentFunction FunctionType      // 当前函数上下文
+	currentClass    ClassType         // 当前类上下文
+	tryDepth        int               // 当前函数中包围当前语句的try块和catch块层数
 	locals          map[string]bool   // 追踪变量是否被使用
 }
 
@@ -36,6 +39,7 @@ func NewResolver(interpreter *interpreter.Interpreter, errorReporter error.Repor
 		errorReporter:   errorReporter,
 		scopes:          make([]map[string]bool, 0),
 		currentFunction: FunctionNONE,
+		currentClass:    ClassNONE,
 		locals:          make(map[string]bool),
 	}
 }
@@ -69,15 +73,12 @@ func (r *Resolver) endScope() {
 		scope := r.scopes[len(r.scopes)-1]
 		scopeDepth := len(r.scopes) - 1
 
-		// 遍历当前作用域中的所有变量
-		for name := range scope {
+		// 按名称顺序遍历当前作用域中的所有变量，保证报告顺序稳定
+		for _, name := range sortedNames(scope) {
 			varKey := fmt.Sprintf("%s:%d", name, scopeDepth)
 			// 如果变量已声明但从未使用过
-			if used, exists := r.locals[varKey]; exists && !used {
-				// 忽略函数名和循环变量（这是策略选择，可以根据需要调整）
-				if !strings.HasPrefix(name, "fn_") && !strings.HasPrefix(name, "loop_") {
-					r.errorReporter.ReportError(0, fmt.Sprintf("局部变量 '%s' 已声明但从未使用", name))
-				}
+			if used, exists := r.locals[varKey]; exists && !used && !isIgnoredUnused(name) {
+				r.errorReporter.ReportError(0, fmt.Sprintf("局部变量 '%s' 已声明但从未使用", name))
 			}
 		}
 
@@ -85,6 +86,29 @@ func (r *Resolver) endScope() {
 	}
 }
 
+// isIgnoredUnused 判断变量是否不参与未使用检查
+// 忽略函数名和循环变量（这是策略选择，可以根据需要调整）
+func isIgnoredUnused(name string) bool {
+	return strings.HasPrefix(name, "fn_") || strings.HasPrefix(name, "loop_")
+}
+
+// sortedNames 返回作用域中按字典序排列的变量名
+func sortedNames[V any](scope map[string]V) []string {
+	names := make([]string, 0, len(scope))
+	for name := range scope {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isTailCall 判断return语句的返回值是否为尾调用
+// 尾调用的结果直接作为函数的返回值，执行后端可以复用当前调用而不必加深调用栈
+func isTailCall(value ast.Expr) bool {
+	_, ok := value.(*ast.Call)
+	return ok
+}
+
 // declare 声明一个变量
 func (r *Resolver) declare(name *token.Token) {
 	if len(r.scopes) == 0 {
@@ -139,18 +163,22 @@ func (r *Resolver) resolveLocal(expr ast.Expr, name *token.Token) {
 
 // resolveFunction 解析函数声明
 func (r *Resolver) resolveFunction(function *ast.Function, funcType FunctionType) {
-	enclosingFunction := r.currentFunction
-	r.currentFunction = funcType
+	enclosingFunction, enclosingTryDepth := r.currentFunction, r.tryDepth
+	r.currentFunction, r.tryDepth = funcType, 0
 
+	// 默认值在函数作用域中求值，只能引用它之前的参数
 	r.beginScope()
-	for _, param := range function.Params {
+	for i, param := range function.Params {
+		if value := function.Default(i); value != nil {
+			r.resolveExpr(value)
+		}
 		r.declare(param)
 		r.define(param)
 	}
 	r.Resolve(function.Body)
 	r.endScope()
 
-	r.currentFunction = enclosingFunction
+	r.currentFunction, r.tryDepth = enclosingFunction, enclosingTryDepth
 }
 
 // VisitBlockStmt 访问代码块
@@ -200,6 +228,49 @@ func (r *Resolver) VisitFunctionStmt(stmt *ast.Function) interface{} {
 	return nil
 }
 
+// VisitClassStmt 访问类声明
+func (r *Resolver) VisitClassStmt(stmt *ast.Class) interface{} {
+	enclosingClass := r.currentClass
+	r.currentClass = ClassCLASS
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
+			r.errorReporter.Error(stmt.Superclass.Name, 0, "类不能继承自身。")
+		}
+
+		r.currentClass = ClassSUBCLASS
+		r.resolveExpr(stmt.Superclass)
+
+		// 为super单独开启一个作用域
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = true
+	}
+
+	// 方法体外层的作用域中绑定this
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+
+	for _, method := range stmt.Methods {
+		declaration := FunctionMETHOD
+		if method.Name.Lexeme == "init" {
+			declaration = FunctionINITIALIZER
+		}
+		r.resolveFunction(method, declaration)
+	}
+
+	r.endScope()
+
+	if stmt.Superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
+	return nil
+}
+
 // VisitExpressionStmt 访问表达式语句
 func (r *Resolver) VisitExpressionStmt(stmt *ast.Expression) interface{} {
 	r.resolveExpr(stmt.Expr)
@@ -229,7 +300,12 @@ func (r *Resolver) VisitReturnStmt(stmt *ast.Return) interface{} {
 	}
 
 	if stmt.Value != nil {
+		if r.currentFunction == FunctionINITIALIZER {
+			r.errorReporter.Error(stmt.Keyword, 0, "不能在初始化方法中返回值。")
+		}
 		r.resolveExpr(stmt.Value)
+		// try块和catch块中的调用返回后还可能被捕获异常或执行finally块，不是尾调用
+		stmt.TailCall = r.tryDepth == 0 && isTailCall(stmt.Value)
 	}
 
 	return nil
@@ -239,6 +315,9 @@ func (r *Resolver) VisitReturnStmt(stmt *ast.Return) interface{} {
 func (r *Resolver) VisitWhileStmt(stmt *ast.While) interface{} {
 	r.resolveExpr(stmt.Condition)
 	r.resolveStmt(stmt.Body)
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
 	return nil
 }
 
@@ -247,6 +326,11 @@ func (r *Resolver) VisitBreakStmt(stmt *ast.Break) interface{} {
 	return nil
 }
 
+// VisitContinueStmt 访问continue语句
+func (r *Resolver) VisitContinueStmt(stmt *ast.Continue) interface{} {
+	return nil
+}
+
 // VisitBinaryExpr 访问二元表达式
 func (r *Resolver) VisitBinaryExpr(expr *ast.Binary) interface{} {
 	r.resolveExpr(expr.Left)
@@ -296,3 +380,128 @@ func (r *Resolver) VisitTernaryExpr(expr *ast.Ternary) interface{} {
 	r.resolveExpr(expr.ElseBranch)
 	return nil
 }
+
+// VisitGetExpr 访问属性访问表达式
+func (r *Resolver) VisitGetExpr(expr *ast.Get) interface{} {
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+// VisitSetExpr 访问属性赋值表达式
+func (r *Resolver) VisitSetExpr(expr *ast.Set) interface{} {
+	r.resolveExpr(expr.Value)
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+// VisitListExpr 访问列表字面量表达式
+func (r *Resolver) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+// VisitMapExpr 访问映射字面量表达式
+func (r *Resolver) VisitMapExpr(expr *ast.Map) interface{} {
+	for j, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[j])
+	}
+	return nil
+}
+
+// VisitInterpolationExpr 访问插值字符串表达式
+func (r *Resolver) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		r.resolveExpr(part)
+	}
+	return nil
+}
+
+// VisitLambdaExpr 访问匿名函数表达式，与函数声明的作用域规则相同，只是不声明函数名
+func (r *Resolver) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	r.resolveFunction(expr.Function, FunctionFUNCTION)
+	return nil
+}
+
+// VisitIndexExpr 访问下标访问表达式
+func (r *Resolver) VisitIndexExpr(expr *ast.Index) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil
+}
+
+// VisitIndexSetExpr 访问下标赋值表达式
+func (r *Resolver) VisitIndexSetExpr(expr *ast.IndexSet) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+// VisitThisExpr 访问this表达式
+func (r *Resolver) VisitThisExpr(expr *ast.This) interface{} {
+	if r.currentClass == ClassNONE {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在类外部使用'this'。")
+		return nil
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+// VisitSuperExpr 访问super表达式
+func (r *Resolver) VisitSuperExpr(expr *ast.Super) interface{} {
+	if r.currentClass == ClassNONE {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在类外部使用'super'。")
+	} else if r.currentClass != ClassSUBCLASS {
+		r.errorReporter.Error(expr.Keyword, 0, "不能在没有父类的类中使用'super'。")
+	}
+
+	r.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+// VisitThrowStmt 访问throw语句
+func (r *Resolver) VisitThrowStmt(stmt *ast.Throw) interface{} {
+	r.resolveExpr(stmt.Value)
+	return nil
+}
+
+// VisitTryStmt 访问try语句，catch变量位于catch块外层的独立作用域中
+func (r *Resolver) VisitTryStmt(stmt *ast.Try) interface{} {
+	r.tryDepth++
+	r.resolveStmt(stmt.Body)
+	if stmt.Catch != nil {
+		r.beginScope()
+		r.declare(stmt.CatchName)
+		r.define(stmt.CatchName)
+		// catch变量常常只用于捕获异常，不参与未使用检查
+		r.locals[fmt.Sprintf("%s:%d", stmt.CatchName.Lexeme, len(r.scopes)-1)] = true
+		r.Resolve(stmt.Catch.Statements)
+		r.endScope()
+	}
+	r.tryDepth--
+
+	if stmt.Finally != nil {
+		r.resolveStmt(stmt.Finally)
+	}
+	return nil
+}
+
+// VisitImportStmt 访问import语句，模块像变量一样绑定到名称
+func (r *Resolver) VisitImportStmt(stmt *ast.Import) interface{} {
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	return nil
+}
+
+// VisitExportStmt 访问export语句，只有顶层的声明可以导出
+func (r *Resolver) VisitExportStmt(stmt *ast.Export) interface{} {
+	if len(r.scopes) > 0 {
+		r.errorReporter.Error(stmt.Keyword, 0, "只能导出顶层的声明。")
+	}
+	r.resolveStmt(stmt.Declaration)
+	return nil
+}
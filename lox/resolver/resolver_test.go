@@ -3,6 +3,10 @@ package resolver
 import (
 	"testing"
 
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/interpreter"
+	"github.com/aixiasang/goLox/lox/parser"
+	"github.com/aixiasang/goLox/lox/scanner"
 	"github.com/aixiasang/goLox/lox/token"
 )
 
@@ -15,10 +19,18 @@ func (er *TestErrorReporter) ReportError(line int, message string) {
 	er.errors = append(er.errors, message)
 }
 
+func (er *TestErrorReporter) ReportErrorAt(span token.Span, message string) {
+	er.errors = append(er.errors, message)
+}
+
 func (er *TestErrorReporter) HasError() bool {
 	return len(er.errors) > 0
 }
 
+func (er *TestErrorReporter) ReportRuntimeError(err error.RuntimeError) {
+	er.errors = append(er.errors, err.Message)
+}
+
 func (er *TestErrorReporter) ResetError() {
 	er.errors = nil
 }
@@ -173,3 +185,33 @@ func contains_substr(s, substr string) bool {
 	}
 	return false
 }
+
+// 测试类相关的静态检查
+func TestClassResolution(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"类外使用this", "print this;", "不能在类外部使用'this'"},
+		{"类外使用super", "print super.x;", "不能在类外部使用'super'"},
+		{"无父类使用super", "class A { m() { return super.m(); } }", "不能在没有父类的类中使用'super'"},
+		{"继承自身", "class A < A {}", "类不能继承自身"},
+		{"初始化方法返回值", "class A { init() { return 1; } }", "不能在初始化方法中返回值"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewTestErrorReporter()
+			statements := parser.NewParser(scanner.NewScanner(tt.source, errors).ScanTokens(), errors).Parse()
+			if errors.HasError() {
+				t.Fatalf("解析失败: %v", errors.errors)
+			}
+
+			NewResolver(interpreter.NewInterpreter(errors), errors).Resolve(statements)
+			if !contains(errors.errors, tt.expected) {
+				t.Errorf("期望错误 %q，实际: %v", tt.expected, errors.errors)
+			}
+		})
+	}
+}
@@ -2,8 +2,12 @@ package scanner
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/aixiasang/goLox/lox/error"
 	"github.com/aixiasang/goLox/lox/token"
@@ -17,40 +21,62 @@ type Scanner struct {
 	current int            // 当前字符的位置
 	line    int            // 当前行号
 	errors  error.Reporter // 错误报告器
-	debug   bool           // 调试模式标志
+	file    *token.Source  // 源文件，扫描被导入的模块时设置
+
+	interpolations []interpolation // 尚未结束的字符串插值，最内层在最后
+
+	lineStart      int       // 当前行首的字节偏移
+	startLine      int       // 当前词素起始处的行号
+	startLineStart int       // 当前词素起始行的行首字节偏移
+	debug          bool      // 调试模式标志
+	debugOutput    io.Writer // 调试信息的输出目标
+}
+
+// interpolation 一层尚未结束的字符串插值
+type interpolation struct {
+	braces int        // 插值表达式中尚未闭合的'{'数量
+	quote  token.Span // 字符串开头引号的位置，用于报告未闭合的字符串
 }
 
 // 关键字映射表
 var keywords = map[string]token.TokenType{
-	"and":    token.AND,
-	"break":  token.BREAK,
-	"class":  token.CLASS,
-	"else":   token.ELSE,
-	"false":  token.FALSE,
-	"for":    token.FOR,
-	"fun":    token.FUN,
-	"if":     token.IF,
-	"nil":    token.NIL,
-	"or":     token.OR,
-	"print":  token.PRINT,
-	"return": token.RETURN,
-	"super":  token.SUPER,
-	"this":   token.THIS,
-	"true":   token.TRUE,
-	"var":    token.VAR,
-	"while":  token.WHILE,
+	"and":      token.AND,
+	"break":    token.BREAK,
+	"catch":    token.CATCH,
+	"class":    token.CLASS,
+	"continue": token.CONTINUE,
+	"else":     token.ELSE,
+	"export":   token.EXPORT,
+	"false":    token.FALSE,
+	"finally":  token.FINALLY,
+	"for":      token.FOR,
+	"fun":      token.FUN,
+	"if":       token.IF,
+	"import":   token.IMPORT,
+	"nil":      token.NIL,
+	"or":       token.OR,
+	"print":    token.PRINT,
+	"return":   token.RETURN,
+	"super":    token.SUPER,
+	"this":     token.THIS,
+	"throw":    token.THROW,
+	"true":     token.TRUE,
+	"try":      token.TRY,
+	"var":      token.VAR,
+	"while":    token.WHILE,
 }
 
 // NewScanner 创建一个新的词法分析器
 func NewScanner(source string, errors error.Reporter) *Scanner {
 	return &Scanner{
-		source:  source,
-		tokens:  []*token.Token{},
-		start:   0,
-		current: 0,
-		line:    1,
-		errors:  errors,
-		debug:   false, // 默认关闭调试模式
+		source:      source,
+		tokens:      []*token.Token{},
+		start:       0,
+		current:     0,
+		line:        1,
+		errors:      errors,
+		debug:       false, // 默认关闭调试模式
+		debugOutput: os.Stdout,
 	}
 }
 
@@ -59,10 +85,20 @@ func (s *Scanner) SetDebug(debug bool) {
 	s.debug = debug
 }
 
+// SetDebugOutput 设置调试信息的输出目标
+func (s *Scanner) SetDebugOutput(w io.Writer) {
+	s.debugOutput = w
+}
+
+// SetFile 设置源文件名，之后扫描出的标记都记录所在的文件
+func (s *Scanner) SetFile(name string) {
+	s.file = &token.Source{Name: name, Text: s.source}
+}
+
 // 调试输出辅助函数
 func (s *Scanner) debugPrintf(format string, args ...interface{}) {
 	if s.debug {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(s.debugOutput, format, args...)
 	}
 }
 
@@ -70,10 +106,15 @@ func (s *Scanner) debugPrintf(format string, args ...interface{}) {
 func (s *Scanner) ScanTokens() []*token.Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.startLineStart = s.lineStart
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, token.NewToken(token.EOF, "", nil, s.line))
+	s.start = s.current
+	s.startLine = s.line
+	s.startLineStart = s.lineStart
+	s.addToken(token.EOF)
 	return s.tokens
 }
 
@@ -88,14 +129,37 @@ func (s *Scanner) scanToken() {
 	case ')':
 		s.addToken(token.RIGHT_PAREN)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1].braces++
+		}
 		s.addToken(token.LEFT_BRACE)
 	case '}':
+		if n := len(s.interpolations); n > 0 && s.interpolations[n-1].braces == 0 {
+			// 插值表达式结束，继续扫描字符串的剩余部分
+			quote := s.interpolations[n-1].quote
+			s.interpolations = s.interpolations[:n-1]
+			s.string(quote, true)
+			return
+		}
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1].braces--
+		}
 		s.addToken(token.RIGHT_BRACE)
+	case '[':
+		s.addToken(token.LEFT_BRACKET)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET)
 	case ',':
 		s.debugPrintf("发现逗号标记，行: %d\n", s.line)
 		s.addToken(token.COMMA)
 	case '.':
-		s.addToken(token.DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(token.ELLIPSIS)
+		} else {
+			s.addToken(token.DOT)
+		}
 	case '-':
 		s.addToken(token.MINUS)
 	case '+':
@@ -103,13 +167,25 @@ func (s *Scanner) scanToken() {
 	case ';':
 		s.addToken(token.SEMICOLON)
 	case '*':
-		s.addToken(token.STAR)
+		if s.match('*') {
+			s.addToken(token.STAR_STAR)
+		} else {
+			s.addToken(token.STAR)
+		}
 	case '%':
 		s.addToken(token.MODULO) // 取模运算符
 	case '?':
 		s.addToken(token.QUESTION) // 三元运算符问号
 	case ':':
 		s.addToken(token.COLON) // 三元运算符冒号
+	case '&':
+		s.addToken(token.AMPERSAND)
+	case '|':
+		s.addToken(token.PIPE)
+	case '^':
+		s.addToken(token.CARET)
+	case '~':
+		s.addToken(token.TILDE)
 
 	// 一个或两个字符的标记
 	case '!':
@@ -121,18 +197,24 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(token.EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(token.ARROW)
 		} else {
 			s.addToken(token.EQUAL)
 		}
 	case '<':
 		if s.match('=') {
 			s.addToken(token.LESS_EQUAL)
+		} else if s.match('<') {
+			s.addToken(token.LESS_LESS)
 		} else {
 			s.addToken(token.LESS)
 		}
 	case '>':
 		if s.match('=') {
 			s.addToken(token.GREATER_EQUAL)
+		} else if s.match('>') {
+			s.addToken(token.GREATER_GREATER)
 		} else {
 			s.addToken(token.GREATER)
 		}
@@ -156,18 +238,19 @@ func (s *Scanner) scanToken() {
 		// 忽略空白
 	case '\n':
 		s.line++
+		s.lineStart = s.current
 
 	// 字符串字面量
 	case '"':
-		s.string()
+		s.string(s.openerSpan(), false)
 
 	default:
-		if unicode.IsDigit(rune(c)) {
+		if isDigit(c) {
 			s.number()
-		} else if unicode.IsLetter(rune(c)) || c == '_' {
+		} else if isAlpha(c) {
 			s.identifier()
 		} else {
-			s.errors.ReportError(s.line, "未识别的字符。")
+			s.errors.ReportErrorAt(s.span(), "未识别的字符。")
 		}
 	}
 }
@@ -180,6 +263,7 @@ func (s *Scanner) blockComment() {
 	for nesting > 0 && !s.isAtEnd() {
 		if s.peek() == '\n' {
 			s.line++
+			s.lineStart = s.current + 1
 		} else if s.peek() == '/' && s.peekNext() == '*' {
 			s.advance() // 跳过 /
 			s.advance() // 跳过 *
@@ -195,55 +279,150 @@ func (s *Scanner) blockComment() {
 	}
 
 	if nesting > 0 {
-		s.errors.ReportError(s.line, "未闭合的块注释。")
+		s.errors.ReportErrorAt(s.openerSpan(), "未闭合的块注释。")
 	}
 }
 
-// string 处理字符串字面量
-func (s *Scanner) string() {
+// string 处理字符串字面量，字符串可以跨行，反斜杠开始转义序列
+// 遇到"${"时生成INTERPOLATION标记并回到普通扫描，与之匹配的'}'之后继续扫描字符串，
+// 因此"a${x}b${y}c"被扫描为 INTERPOLATION("a") IDENTIFIER(x) INTERPOLATION_MID("b") IDENTIFIER(y) INTERPOLATION_END("c")。
+// quote为字符串开头引号的位置，continued表示是否在插值表达式之后继续扫描字符串
+func (s *Scanner) string(quote token.Span, continued bool) {
+	var value strings.Builder
+
 	// 读取直到找到闭合的引号
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
+		c := s.advance()
+		switch c {
+		case '\n':
 			s.line++
+			s.lineStart = s.current
+			value.WriteRune(c)
+		case '\\':
+			s.escape(&value)
+		case '$':
+			if s.match('{') {
+				s.interpolations = append(s.interpolations, interpolation{quote: quote})
+				if continued {
+					s.addTokenWithLiteral(token.INTERPOLATION_MID, value.String())
+				} else {
+					s.addTokenWithLiteral(token.INTERPOLATION, value.String())
+				}
+				return
+			}
+			value.WriteRune(c)
+		default:
+			value.WriteRune(c)
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
-		s.errors.ReportError(s.line, "未闭合的字符串。")
+		s.errors.ReportErrorAt(quote, "未闭合的字符串。")
 		return
 	}
 
 	// 闭合的引号
 	s.advance()
 
-	// 提取字符串值（去除引号）
-	value := s.source[s.start+1 : s.current-1]
-	s.addTokenWithLiteral(token.STRING, value)
+	if continued {
+		s.addTokenWithLiteral(token.INTERPOLATION_END, value.String())
+	} else {
+		s.addTokenWithLiteral(token.STRING, value.String())
+	}
 }
 
-// number 处理数字字面量
+// escapes 单字符转义序列及其表示的字符
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$',
+}
+
+// escape 处理反斜杠之后的转义序列，将其表示的字符写入value
+// 支持\n、\t、\r、\0、\"、\\、\$以及\uXXXX和\u{X...}形式的Unicode转义
+func (s *Scanner) escape(value *strings.Builder) {
+	start := s.current - 1
+	if s.isAtEnd() {
+		return
+	}
+
+	c := s.advance()
+	if r, ok := escapes[c]; ok {
+		value.WriteRune(r)
+		return
+	}
+	if c != 'u' {
+		s.errors.ReportErrorAt(s.spanFrom(start), fmt.Sprintf("无效的转义序列 '\\%c'。", c))
+		return
+	}
+
+	var digits string
+	if s.match('{') {
+		digitsStart := s.current
+		for isHexDigit(s.peek()) {
+			s.advance()
+		}
+		digits = s.source[digitsStart:s.current]
+		if !s.match('}') || len(digits) == 0 || len(digits) > 6 {
+			s.errors.ReportErrorAt(s.spanFrom(start), "无效的Unicode转义序列，应为\\uXXXX或\\u{X...}。")
+			return
+		}
+	} else {
+		digitsStart := s.current
+		for i := 0; i < 4 && isHexDigit(s.peek()); i++ {
+			s.advance()
+		}
+		digits = s.source[digitsStart:s.current]
+		if len(digits) != 4 {
+			s.errors.ReportErrorAt(s.spanFrom(start), "无效的Unicode转义序列，应为\\uXXXX或\\u{X...}。")
+			return
+		}
+	}
+
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	r := rune(code)
+	if !utf8.ValidRune(r) {
+		s.errors.ReportErrorAt(s.spanFrom(start), fmt.Sprintf("无效的Unicode码点 U+%s。", strings.ToUpper(digits)))
+		return
+	}
+	value.WriteRune(r)
+}
+
+// number 处理数字字面量，不带小数点的为整数(int64)，带小数点的为浮点数(float64)
+// 超出int64范围的整数字面量作为浮点数处理
 func (s *Scanner) number() {
 	// 读取整数部分
-	for unicode.IsDigit(rune(s.peek())) {
+	for isDigit(s.peek()) {
 		s.advance()
 	}
 
 	// 处理小数部分
-	if s.peek() == '.' && unicode.IsDigit(rune(s.peekNext())) {
+	if s.peek() == '.' && isDigit(s.peekNext()) {
 		// 消费小数点
 		s.advance()
 
 		// 读取小数部分
-		for unicode.IsDigit(rune(s.peek())) {
+		for isDigit(s.peek()) {
 			s.advance()
 		}
 	}
 
+	text := s.source[s.start:s.current]
+	if !strings.Contains(text, ".") {
+		if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+			s.addTokenWithLiteral(token.NUMBER, value)
+			return
+		}
+	}
+
 	// 转换为浮点数
-	value, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
+	value, err := strconv.ParseFloat(text, 64)
 	if err != nil {
-		s.errors.ReportError(s.line, "无效的数字。")
+		s.errors.ReportErrorAt(s.span(), "无效的数字。")
 		return
 	}
 	s.addTokenWithLiteral(token.NUMBER, value)
@@ -252,7 +431,7 @@ func (s *Scanner) number() {
 // identifier 处理标识符和关键字
 func (s *Scanner) identifier() {
 	// 读取标识符剩余部分
-	for unicode.IsLetter(rune(s.peek())) || unicode.IsDigit(rune(s.peek())) || s.peek() == '_' {
+	for isAlphaNumeric(s.peek()) {
 		s.advance()
 	}
 
@@ -275,36 +454,62 @@ func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
-// advance 消费当前字符并前进
-func (s *Scanner) advance() byte {
-	result := s.source[s.current]
-	s.current++
-	return result
+// advance 消费当前字符(按UTF-8解码的一个rune)并前进
+func (s *Scanner) advance() rune {
+	r, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
+	return r
 }
 
 // match 检查当前字符是否匹配预期字符，如果匹配则消费
-func (s *Scanner) match(expected byte) bool {
-	if s.isAtEnd() || s.source[s.current] != expected {
+func (s *Scanner) match(expected rune) bool {
+	if s.isAtEnd() || s.peek() != expected {
 		return false
 	}
-	s.current++
+	s.advance()
 	return true
 }
 
 // peek 查看当前字符但不消费
-func (s *Scanner) peek() byte {
+func (s *Scanner) peek() rune {
 	if s.isAtEnd() {
 		return 0
 	}
-	return s.source[s.current]
+	r, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return r
 }
 
 // peekNext 查看下一个字符但不消费
-func (s *Scanner) peekNext() byte {
-	if s.current+1 >= len(s.source) {
+func (s *Scanner) peekNext() rune {
+	if s.isAtEnd() {
+		return 0
+	}
+	_, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if s.current+size >= len(s.source) {
 		return 0
 	}
-	return s.source[s.current+1]
+	r, _ := utf8.DecodeRuneInString(s.source[s.current+size:])
+	return r
+}
+
+// isDigit 判断是否为ASCII数字，数字字面量只由ASCII数字组成
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isHexDigit 判断是否为十六进制数字
+func isHexDigit(c rune) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isAlpha 判断字符能否作为标识符的开头，任意Unicode字母(例如汉字)和下划线都可以
+func isAlpha(c rune) bool {
+	return unicode.IsLetter(c) || c == '_'
+}
+
+// isAlphaNumeric 判断字符能否出现在标识符中
+func isAlphaNumeric(c rune) bool {
+	return isAlpha(c) || unicode.IsDigit(c)
 }
 
 // addToken 添加没有字面量的标记
@@ -315,5 +520,41 @@ func (s *Scanner) addToken(tokenType token.TokenType) {
 // addTokenWithLiteral 添加带有字面量的标记
 func (s *Scanner) addTokenWithLiteral(tokenType token.TokenType, literal interface{}) {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, token.NewToken(tokenType, text, literal, s.line))
+	tok := token.NewToken(tokenType, text, literal, s.startLine)
+	span := s.span()
+	tok.Column = span.Column
+	tok.Offset = span.Offset
+	tok.Length = span.Length
+	tok.Source = s.file
+	s.tokens = append(s.tokens, tok)
+}
+
+// span 返回当前词素在源代码中的区间，多行词素的行列号取自起始位置
+func (s *Scanner) span() token.Span {
+	return token.Span{
+		Line:   s.startLine,
+		Column: utf8.RuneCountInString(s.source[s.startLineStart:s.start]) + 1,
+		Offset: s.start,
+		Length: s.current - s.start,
+	}
+}
+
+// spanFrom 返回从start到当前位置的区间，用于指出词素内部的错误(例如字符串中的转义序列)
+func (s *Scanner) spanFrom(start int) token.Span {
+	return token.Span{
+		Line:   s.line,
+		Column: utf8.RuneCountInString(s.source[s.lineStart:start]) + 1,
+		Offset: start,
+		Length: s.current - start,
+	}
+}
+
+// openerSpan 返回未闭合的字符串或块注释的开始符号所在的区间
+func (s *Scanner) openerSpan() token.Span {
+	span := s.span()
+	span.Length = 1
+	if s.source[s.start] == '/' {
+		span.Length = 2
+	}
+	return span
 }
@@ -0,0 +1,194 @@
+package scanner
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// scan 扫描源代码，返回标记列表和错误报告器
+func scan(t *testing.T, source string) ([]*token.Token, *error.ErrorReporter) {
+	t.Helper()
+
+	errors := error.NewErrorReporter()
+	errors.SetSinks()
+	return NewScanner(source, errors).ScanTokens(), errors
+}
+
+// 测试字符串中的转义序列和多字节字符
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"普通字符串", `"hello"`, "hello"},
+		{"中文字符串", `"你好，世界"`, "你好，世界"},
+		{"换行和制表符", `"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{"引号和反斜杠", `"说\"你好\"\\"`, "说\"你好\"\\"},
+		{"空字符", `"a\0b"`, "a\x00b"},
+		{"四位Unicode转义", `"\u4e2d\u6587"`, "中文"},
+		{"花括号Unicode转义", `"\u{1F600}\u{41}"`, "😀A"},
+		{"转义与中文混合", `"第一行\n第二行"`, "第一行\n第二行"},
+		{"跨行字符串", "\"多\n行\"", "多\n行"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, errors := scan(t, tt.source)
+			if errors.HasError() {
+				t.Fatalf("扫描出错: %v", errors.Diagnostics())
+			}
+			if len(tokens) != 2 || tokens[0].Type != token.STRING {
+				t.Fatalf("期望一个字符串标记，实际: %v", tokens)
+			}
+			if tokens[0].Literal != tt.expected {
+				t.Errorf("期望%q，实际%q", tt.expected, tokens[0].Literal)
+			}
+			if tokens[0].Lexeme != tt.source {
+				t.Errorf("词素应保留源代码原文，实际%q", tokens[0].Lexeme)
+			}
+		})
+	}
+}
+
+// 测试插值字符串被拆分为字符串片段和表达式的标记
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{"单个插值", `"a${x}b"`, []string{`INTERPOLATION "a"`, "IDENTIFIER x", `INTERPOLATION_END "b"`}},
+		{"相邻插值", `"${x}${y}"`, []string{`INTERPOLATION ""`, "IDENTIFIER x", `INTERPOLATION_MID ""`, "IDENTIFIER y", `INTERPOLATION_END ""`}},
+		{"表达式中的花括号", `"${ {"k": 1} }!"`, []string{`INTERPOLATION ""`, "LEFT_BRACE {", `STRING "k"`, "COLON :", "NUMBER 1", "RIGHT_BRACE }", `INTERPOLATION_END "!"`}},
+		{"嵌套插值", `"外${"内${x}"}"`, []string{`INTERPOLATION "外"`, `INTERPOLATION "内"`, "IDENTIFIER x", `INTERPOLATION_END ""`, `INTERPOLATION_END ""`}},
+		{"插值之后的字符串", `"${x}" "y"`, []string{`INTERPOLATION ""`, "IDENTIFIER x", `INTERPOLATION_END ""`, `STRING "y"`}},
+		{"转义的美元符号", `"\${x} $"`, []string{`STRING "${x} $"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, errors := scan(t, tt.source)
+			if errors.HasError() {
+				t.Fatalf("扫描出错: %v", errors.Diagnostics())
+			}
+
+			var actual []string
+			for _, tok := range tokens[:len(tokens)-1] {
+				if text, ok := tok.Literal.(string); ok {
+					actual = append(actual, fmt.Sprintf("%s %q", token.TokenNames[tok.Type], text))
+				} else if tok.Literal != nil {
+					actual = append(actual, fmt.Sprintf("%s %v", token.TokenNames[tok.Type], tok.Literal))
+				} else {
+					actual = append(actual, fmt.Sprintf("%s %s", token.TokenNames[tok.Type], tok.Lexeme))
+				}
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("期望%v，实际%v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+// 测试数字字面量的类型：不带小数点的为整数，带小数点或超出int64范围的为浮点数
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		{"42", int64(42)},
+		{"0", int64(0)},
+		{"1.0", 1.0},
+		{"3.25", 3.25},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"9223372036854775808", 9223372036854775808.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, errors := scan(t, tt.source)
+			if errors.HasError() || tokens[0].Type != token.NUMBER {
+				t.Fatalf("期望数字标记，实际: %v %v", tokens, errors.Diagnostics())
+			}
+			if tokens[0].Literal != tt.expected {
+				t.Errorf("期望%v(%T)，实际%v(%T)", tt.expected, tt.expected, tokens[0].Literal, tokens[0].Literal)
+			}
+		})
+	}
+}
+
+// 测试中文标识符和关键字的扫描
+func TestIdentifiers(t *testing.T) {
+	tokens, errors := scan(t, "var 名字 = 年龄_2 + _私有;\nprint 名字;")
+	if errors.HasError() {
+		t.Fatalf("扫描出错: %v", errors.Diagnostics())
+	}
+
+	expected := []struct {
+		tokenType token.TokenType
+		lexeme    string
+		line      int
+		column    int
+	}{
+		{token.VAR, "var", 1, 1},
+		{token.IDENTIFIER, "名字", 1, 5},
+		{token.EQUAL, "=", 1, 8},
+		{token.IDENTIFIER, "年龄_2", 1, 10},
+		{token.PLUS, "+", 1, 15},
+		{token.IDENTIFIER, "_私有", 1, 17},
+		{token.SEMICOLON, ";", 1, 20},
+		{token.PRINT, "print", 2, 1},
+		{token.IDENTIFIER, "名字", 2, 7},
+		{token.SEMICOLON, ";", 2, 9},
+		{token.EOF, "", 2, 10},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("期望%d个标记，实际%d个: %v", len(expected), len(tokens), tokens)
+	}
+	for i, tt := range expected {
+		tok := tokens[i]
+		if tok.Type != tt.tokenType || tok.Lexeme != tt.lexeme || tok.Line != tt.line || tok.Column != tt.column {
+			t.Errorf("第%d个标记不正确: 期望%v %q %d:%d，实际%v %q %d:%d",
+				i, tt.tokenType, tt.lexeme, tt.line, tt.column, tok.Type, tok.Lexeme, tok.Line, tok.Column)
+		}
+	}
+}
+
+// 测试词法错误的信息和位置
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		column  int
+		length  int
+	}{
+		{"未知的转义", `"中\q"`, "无效的转义序列 '\\q'。", 3, 2},
+		{"Unicode转义位数不足", `"\u12"`, "无效的Unicode转义序列，应为\\uXXXX或\\u{X...}。", 2, 4},
+		{"花括号未闭合", `"\u{41"`, "无效的Unicode转义序列，应为\\uXXXX或\\u{X...}。", 2, 5},
+		{"超出范围的码点", `"\u{110000}"`, "无效的Unicode码点 U+110000。", 2, 10},
+		{"代理码点", `"\uD800"`, "无效的Unicode码点 U+D800。", 2, 6},
+		{"未闭合的字符串", `"中文\"`, "未闭合的字符串。", 1, 1},
+		{"全角标点", "var a；", "未识别的字符。", 6, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errors := scan(t, tt.source)
+			diagnostics := errors.Diagnostics()
+			if len(diagnostics) != 1 {
+				t.Fatalf("期望一个错误，实际: %v", diagnostics)
+			}
+			d := diagnostics[0]
+			if d.Message != tt.message || d.Span.Column != tt.column || d.Span.Length != tt.length {
+				t.Errorf("期望%q(列%d，长度%d)，实际%q(列%d，长度%d)",
+					tt.message, tt.column, tt.length, d.Message, d.Span.Column, d.Span.Length)
+			}
+		})
+	}
+}
@@ -11,6 +11,8 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
 	DOT
 	MINUS
@@ -18,9 +20,13 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
-	QUESTION // 问号(用于三元操作符)
-	COLON    // 冒号(用于三元操作符)
-	MODULO   // 取模运算符
+	QUESTION  // 问号(用于三元操作符)
+	COLON     // 冒号(用于三元操作符)
+	MODULO    // 取模运算符
+	AMPERSAND // 按位与
+	PIPE      // 按位或
+	CARET     // 按位异或
+	TILDE     // 按位取反
 
 	// 一个或两个字符的标记
 	BANG
@@ -31,28 +37,43 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	LESS_LESS       // 左移
+	GREATER_GREATER // 右移
+	STAR_STAR       // 乘方
+	ARROW           // 箭头函数的=>
+	ELLIPSIS        // 剩余参数的...
 
 	// 字面量
 	IDENTIFIER
 	STRING
+	INTERPOLATION     // 插值字符串中第一个"${"之前的部分
+	INTERPOLATION_MID // 插值字符串中两个插值表达式之间的部分
+	INTERPOLATION_END // 插值字符串中最后一个插值表达式之后的部分
 	NUMBER
 
 	// 关键字
 	AND
 	BREAK
+	CATCH
 	CLASS
+	CONTINUE
 	ELSE
+	EXPORT
 	FALSE
+	FINALLY
 	FUN
 	FOR
 	IF
+	IMPORT
 	NIL
 	OR
 	PRINT
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 
@@ -61,49 +82,70 @@ const (
 
 // Token名称映射表，用于调试和错误信息
 var TokenNames = map[TokenType]string{
-	LEFT_PAREN:    "LEFT_PAREN",
-	RIGHT_PAREN:   "RIGHT_PAREN",
-	LEFT_BRACE:    "LEFT_BRACE",
-	RIGHT_BRACE:   "RIGHT_BRACE",
-	COMMA:         "COMMA",
-	DOT:           "DOT",
-	MINUS:         "MINUS",
-	PLUS:          "PLUS",
-	SEMICOLON:     "SEMICOLON",
-	SLASH:         "SLASH",
-	STAR:          "STAR",
-	QUESTION:      "QUESTION",
-	COLON:         "COLON",
-	MODULO:        "MODULO",
-	BANG:          "BANG",
-	BANG_EQUAL:    "BANG_EQUAL",
-	EQUAL:         "EQUAL",
-	EQUAL_EQUAL:   "EQUAL_EQUAL",
-	GREATER:       "GREATER",
-	GREATER_EQUAL: "GREATER_EQUAL",
-	LESS:          "LESS",
-	LESS_EQUAL:    "LESS_EQUAL",
-	IDENTIFIER:    "IDENTIFIER",
-	STRING:        "STRING",
-	NUMBER:        "NUMBER",
-	AND:           "AND",
-	BREAK:         "BREAK",
-	CLASS:         "CLASS",
-	ELSE:          "ELSE",
-	FALSE:         "FALSE",
-	FUN:           "FUN",
-	FOR:           "FOR",
-	IF:            "IF",
-	NIL:           "NIL",
-	OR:            "OR",
-	PRINT:         "PRINT",
-	RETURN:        "RETURN",
-	SUPER:         "SUPER",
-	THIS:          "THIS",
-	TRUE:          "TRUE",
-	VAR:           "VAR",
-	WHILE:         "WHILE",
-	EOF:           "EOF",
+	LEFT_PAREN:        "LEFT_PAREN",
+	RIGHT_PAREN:       "RIGHT_PAREN",
+	LEFT_BRACE:        "LEFT_BRACE",
+	RIGHT_BRACE:       "RIGHT_BRACE",
+	LEFT_BRACKET:      "LEFT_BRACKET",
+	RIGHT_BRACKET:     "RIGHT_BRACKET",
+	COMMA:             "COMMA",
+	DOT:               "DOT",
+	MINUS:             "MINUS",
+	PLUS:              "PLUS",
+	SEMICOLON:         "SEMICOLON",
+	SLASH:             "SLASH",
+	STAR:              "STAR",
+	QUESTION:          "QUESTION",
+	COLON:             "COLON",
+	MODULO:            "MODULO",
+	AMPERSAND:         "AMPERSAND",
+	PIPE:              "PIPE",
+	CARET:             "CARET",
+	TILDE:             "TILDE",
+	BANG:              "BANG",
+	BANG_EQUAL:        "BANG_EQUAL",
+	EQUAL:             "EQUAL",
+	EQUAL_EQUAL:       "EQUAL_EQUAL",
+	GREATER:           "GREATER",
+	GREATER_EQUAL:     "GREATER_EQUAL",
+	LESS:              "LESS",
+	LESS_EQUAL:        "LESS_EQUAL",
+	LESS_LESS:         "LESS_LESS",
+	GREATER_GREATER:   "GREATER_GREATER",
+	STAR_STAR:         "STAR_STAR",
+	ARROW:             "ARROW",
+	ELLIPSIS:          "ELLIPSIS",
+	IDENTIFIER:        "IDENTIFIER",
+	STRING:            "STRING",
+	INTERPOLATION:     "INTERPOLATION",
+	INTERPOLATION_MID: "INTERPOLATION_MID",
+	INTERPOLATION_END: "INTERPOLATION_END",
+	NUMBER:            "NUMBER",
+	AND:               "AND",
+	BREAK:             "BREAK",
+	CATCH:             "CATCH",
+	CLASS:             "CLASS",
+	CONTINUE:          "CONTINUE",
+	ELSE:              "ELSE",
+	EXPORT:            "EXPORT",
+	FALSE:             "FALSE",
+	FINALLY:           "FINALLY",
+	FUN:               "FUN",
+	FOR:               "FOR",
+	IF:                "IF",
+	IMPORT:            "IMPORT",
+	NIL:               "NIL",
+	OR:                "OR",
+	PRINT:             "PRINT",
+	RETURN:            "RETURN",
+	SUPER:             "SUPER",
+	THIS:              "THIS",
+	THROW:             "THROW",
+	TRUE:              "TRUE",
+	TRY:               "TRY",
+	VAR:               "VAR",
+	WHILE:             "WHILE",
+	EOF:               "EOF",
 }
 
 // Token 表示一个标记
@@ -112,6 +154,16 @@ type Token struct {
 	Lexeme  string      // 词素
 	Literal interface{} // 字面值
 	Line    int         // 行号
+	Column  int         // 列号，从1开始按字符计数，未知时为0
+	Offset  int         // 词素在源代码中的字节偏移
+	Length  int         // 词素的字节长度，合成的标记为0
+	Source  *Source     // 标记所在的源文件，主脚本的标记为nil
+}
+
+// Source 被导入模块的源文件，运行时错误据此给出文件名和源代码摘录
+type Source struct {
+	Name string // 文件名
+	Text string // 源代码
 }
 
 // NewToken 创建一个新的标记
@@ -140,3 +192,47 @@ func (t *Token) String() string {
 	}
 	return fmt.Sprintf("%s '%s'%s", GetTokenName(t.Type), t.Lexeme, literalStr)
 }
+
+// Span 返回标记在源代码中的区间
+func (t *Token) Span() Span {
+	return Span{Line: t.Line, Column: t.Column, Offset: t.Offset, Length: t.Length}
+}
+
+// Span 源代码中的一段连续区间
+type Span struct {
+	Line   int // 起始行号
+	Column int // 起始列号，从1开始按字符计数，未知时为0
+	Offset int // 起始字节偏移
+	Length int // 字节长度
+}
+
+// End 返回区间结束位置的字节偏移(不含)
+func (s Span) End() int {
+	return s.Offset + s.Length
+}
+
+// IsZero 判断区间是否未知，合成的标记和节点没有区间
+func (s Span) IsZero() bool {
+	return s.Column == 0
+}
+
+// Union 返回同时覆盖两个区间的最小区间，未知的区间被忽略
+func (s Span) Union(other Span) Span {
+	if s.IsZero() {
+		return other
+	}
+	if other.IsZero() {
+		return s
+	}
+
+	start := s
+	if other.Offset < s.Offset {
+		start = other
+	}
+	end := s.End()
+	if other.End() > end {
+		end = other.End()
+	}
+	start.Length = end - start.Offset
+	return start
+}
@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/aixiasang/goLox/lox/compiler"
+	"github.com/aixiasang/goLox/lox/environment"
+	"github.com/aixiasang/goLox/lox/interpreter"
+)
+
+// Upvalue 闭包捕获的变量
+// 变量仍在栈上时为打开状态，通过槽位访问；离开作用域后关闭，值移入closed
+type Upvalue struct {
+	slot   int         // 打开状态下变量在值栈中的槽位
+	closed interface{} // 关闭后的变量值
+	isOpen bool
+	next   *Upvalue // 按槽位降序排列的下一个打开上值
+}
+
+// Closure 运行时的函数对象
+type Closure struct {
+	Function *compiler.Function
+	Upvalues []*Upvalue
+	Globals  *environment.Environment // 定义函数的模块的全局环境
+}
+
+// String 返回闭包的字符串表示
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// Parameters 返回可以按名称传入的参数
+func (c *Closure) Parameters() interpreter.Parameters {
+	return interpreter.Parameters{Names: c.Function.Params, Required: c.Function.MinArity}
+}
+
+// Class 运行时的类对象
+type Class struct {
+	Name    string
+	Methods map[string]*Closure
+}
+
+// NewClass 创建一个新的类对象
+func NewClass(name string) *Class {
+	return &Class{
+		Name:    name,
+		Methods: make(map[string]*Closure),
+	}
+}
+
+// String 返回类的字符串表示
+func (c *Class) String() string {
+	return c.Name
+}
+
+// Parameters 返回初始化方法中可以按名称传入的参数，没有初始化方法时没有参数
+func (c *Class) Parameters() interpreter.Parameters {
+	if initializer, ok := c.Methods["init"]; ok {
+		return initializer.Parameters()
+	}
+	return interpreter.Parameters{}
+}
+
+// Instance 类的实例
+type Instance struct {
+	Class  *Class
+	Fields map[string]interface{}
+}
+
+// NewInstance 创建一个新的实例
+func NewInstance(class *Class) *Instance {
+	return &Instance{
+		Class:  class,
+		Fields: make(map[string]interface{}),
+	}
+}
+
+// String 返回实例的字符串表示
+func (i *Instance) String() string {
+	return fmt.Sprintf("%s instance", i.Class.Name)
+}
+
+// BoundMethod 绑定了接收者的方法
+type BoundMethod struct {
+	Receiver interface{}
+	Method   *Closure
+}
+
+// String 返回绑定方法的字符串表示
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+// Parameters 返回可以按名称传入的参数
+func (b *BoundMethod) Parameters() interpreter.Parameters {
+	return b.Method.Parameters()
+}
@@ -0,0 +1,835 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/aixiasang/goLox/lox/compiler"
+	"github.com/aixiasang/goLox/lox/environment"
+	"github.com/aixiasang/goLox/lox/error"
+	"github.com/aixiasang/goLox/lox/interpreter"
+	"github.com/aixiasang/goLox/lox/token"
+)
+
+// callFrame 一次函数调用的栈帧
+type callFrame struct {
+	closure *Closure
+	ip      int // 下一条待执行指令的偏移
+	base    int // 栈帧在值栈中的起始槽位，槽位0为被调用者或this
+}
+
+// handler try语句安装的异常处理器
+type handler struct {
+	frames      int // 安装时的栈帧数，处理代码属于最后一帧
+	stackHeight int // 安装时的值栈高度
+	target      int // 处理代码的偏移
+}
+
+// pendingError 异常处理器捕获的运行时错误，暂存在值栈上
+// 由OP_CATCH转换为catch变量的值，或者在finally块执行后由OP_THROW重新抛出
+type pendingError struct {
+	err error.RuntimeError
+}
+
+// VM 执行字节码的栈式虚拟机
+type VM struct {
+	errorReporter error.Reporter
+	stack         []interface{}            // 值栈
+	frames        []callFrame              // 调用栈
+	globals       *environment.Environment // 主脚本的全局变量
+	builtins      *environment.Environment // 内置函数，是所有模块全局环境的外层环境
+	importer      interpreter.Importer     // 加载import语句导入的模块
+	baseFrames    int                      // 当前的run开始时的栈帧数，执行模块时不为0
+	openUpvalues  *Upvalue                 // 仍指向栈上变量的上值链表
+	handlers      []handler                // 已安装的异常处理器
+	cancellation  interpreter.Cancellation // 执行取消检查
+	maxCallDepth  int                      // 最大调用深度，不计顶层脚本的栈帧
+	stdout        io.Writer                // print语句的输出目标
+}
+
+// NewVM 创建一个新的虚拟机
+func NewVM(errorReporter error.Reporter) *VM {
+	builtins := environment.NewEnvironment()
+	interpreter.DefineNatives(builtins)
+
+	return &VM{
+		errorReporter: errorReporter,
+		stack:         make([]interface{}, 0, 256),
+		frames:        make([]callFrame, 0, 64),
+		globals:       environment.NewEnclosedEnvironment(builtins),
+		builtins:      builtins,
+		stdout:        os.Stdout,
+	}
+}
+
+// SetOutput 设置print语句的输出目标
+func (vm *VM) SetOutput(w io.Writer) {
+	vm.stdout = w
+}
+
+// SetMaxCallDepth 设置最大调用深度，limit小于等于0时使用interpreter.DefaultMaxCallDepth
+func (vm *VM) SetMaxCallDepth(limit int) {
+	vm.maxCallDepth = limit
+}
+
+// DefineNative 注册一个由Go实现的内置函数，主脚本和所有模块都可以调用
+func (vm *VM) DefineNative(name string, arity int, fn interpreter.NativeFunc) {
+	vm.builtins.Define(name, interpreter.NewNativeFunction(name, arity, fn))
+}
+
+// SetImporter 设置加载import语句导入的模块的函数
+func (vm *VM) SetImporter(importer interpreter.Importer) {
+	vm.importer = importer
+}
+
+// Interpret 执行编译后的顶层脚本函数，返回脚本的返回值
+func (vm *VM) Interpret(function *compiler.Function) (result interface{}) {
+	defer vm.handlePanic()
+
+	closure := &Closure{Function: function, Globals: vm.globals}
+	vm.push(closure)
+	vm.call(closure, 0, nil)
+	return vm.run()
+}
+
+// ExecuteModule 在新的全局环境中执行编译后的模块顶层函数，返回执行完毕的模块
+// 模块在当前调用栈之上嵌套执行，at为导入模块的位置；exports为模块导出的名称
+func (vm *VM) ExecuteModule(name string, function *compiler.Function, exports []string, at *token.Token) *interpreter.Module {
+	module := interpreter.NewModule(name, environment.NewEnclosedEnvironment(vm.builtins), exports)
+	closure := &Closure{Function: function, Globals: module.Globals()}
+
+	// 模块的顶层函数返回时，嵌套的run随之返回
+	previous := vm.baseFrames
+	defer func() {
+		vm.baseFrames = previous
+	}()
+	vm.baseFrames = len(vm.frames)
+
+	vm.push(closure)
+	vm.call(closure, 0, at)
+	vm.run()
+	return module
+}
+
+// SetContext 设置用于取消执行的上下文
+func (vm *VM) SetContext(ctx context.Context) {
+	vm.cancellation.Set(ctx)
+}
+
+// handlePanic 处理执行过程中的运行时错误
+func (vm *VM) handlePanic() {
+	if r := recover(); r != nil {
+		runtimeError, ok := r.(error.RuntimeError)
+		if !ok {
+			// 重新抛出其他异常
+			panic(r)
+		}
+
+		if runtimeError.Trace == nil {
+			runtimeError.Trace = vm.trace(runtimeError.Token)
+		}
+		vm.errorReporter.ReportRuntimeError(runtimeError)
+		vm.resetStack()
+	}
+}
+
+// trace 生成错误发生在at处时的调用栈，最外层在前，错误发生在顶层脚本时返回nil
+// 外层栈帧的ip停在调用指令之后，其前一个字节对应调用处的标记
+func (vm *VM) trace(at *token.Token) []error.StackFrame {
+	if len(vm.frames) <= 1 {
+		return nil
+	}
+
+	trace := make([]error.StackFrame, len(vm.frames))
+	for i, frame := range vm.frames {
+		tok := at
+		if i < len(vm.frames)-1 {
+			tok = frame.closure.Function.Chunk.Tokens[frame.ip-1]
+		}
+		trace[i] = error.NewStackFrame(frame.closure.Function.String(), tok)
+	}
+	return trace
+}
+
+// resetStack 清空值栈和调用栈
+func (vm *VM) resetStack() {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.handlers = vm.handlers[:0]
+	vm.openUpvalues = nil
+}
+
+// ---------- 值栈操作 ----------
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// ---------- 主循环 ----------
+
+// run 执行当前栈帧直到顶层脚本返回
+// 运行时错误被try语句捕获后，从异常处理代码处继续执行
+func (vm *VM) run() interface{} {
+	for {
+		if result, done := vm.runProtected(); done {
+			return result
+		}
+	}
+}
+
+// runProtected 执行字节码，运行时错误被异常处理器捕获时返回done为false
+func (vm *VM) runProtected() (result interface{}, done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeError, ok := r.(error.RuntimeError)
+			// 只有当前的run安装的处理器可以捕获，其余的交给外层的run
+			if !ok || runtimeError.Fatal || len(vm.handlers) == 0 || vm.handlers[len(vm.handlers)-1].frames <= vm.baseFrames {
+				panic(r)
+			}
+			vm.catch(runtimeError)
+		}
+	}()
+
+	return vm.execute(), true
+}
+
+// catch 将运行时错误交给最近安装的异常处理器
+// 丢弃处理器之上的栈帧和值，压入暂存的异常，然后跳转到处理代码
+func (vm *VM) catch(err error.RuntimeError) {
+	if err.Trace == nil {
+		err.Trace = vm.trace(err.Token)
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.frames = vm.frames[:h.frames]
+	vm.closeUpvalues(h.stackHeight)
+	vm.stack = vm.stack[:h.stackHeight]
+	vm.push(&pendingError{err: err})
+	vm.frames[h.frames-1].ip = h.target
+}
+
+// execute 执行字节码的主循环
+func (vm *VM) execute() interface{} {
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := frame.closure.Function.Chunk
+	code := chunk.Code
+
+	readByte := func() byte {
+		b := code[frame.ip]
+		frame.ip++
+		return b
+	}
+	readShort := func() int {
+		frame.ip += 2
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	// readToken 读取名称常量的操作数，返回操作数字节对应的名称标记
+	// 同名的引用共享一个常量，出错位置因此取自操作数而不是常量表
+	readToken := func() *token.Token {
+		readShort()
+		return chunk.Tokens[frame.ip-1]
+	}
+	// 调用或返回后重新加载当前栈帧
+	loadFrame := func() {
+		frame = &vm.frames[len(vm.frames)-1]
+		chunk = frame.closure.Function.Chunk
+		code = chunk.Code
+	}
+
+	for {
+		op := compiler.OpCode(readByte())
+		// 当前指令对应的源码标记，用于运行时错误
+		tok := chunk.Tokens[frame.ip-1]
+
+		switch op {
+		case compiler.OP_CONSTANT:
+			vm.push(chunk.Constants[readShort()])
+		case compiler.OP_NIL:
+			vm.push(nil)
+		case compiler.OP_TRUE:
+			vm.push(true)
+		case compiler.OP_FALSE:
+			vm.push(false)
+		case compiler.OP_POP:
+			vm.pop()
+
+		case compiler.OP_GET_LOCAL:
+			vm.push(vm.stack[frame.base+int(readByte())])
+		case compiler.OP_SET_LOCAL:
+			vm.stack[frame.base+int(readByte())] = vm.peek(0)
+		case compiler.OP_GET_GLOBAL:
+			vm.push(frame.closure.Globals.Get(readToken()))
+		case compiler.OP_DEFINE_GLOBAL:
+			frame.closure.Globals.Define(readToken().Lexeme, vm.pop())
+		case compiler.OP_SET_GLOBAL:
+			frame.closure.Globals.Assign(readToken(), vm.peek(0))
+		case compiler.OP_GET_UPVALUE:
+			vm.push(vm.getUpvalue(frame.closure.Upvalues[readByte()]))
+		case compiler.OP_SET_UPVALUE:
+			vm.setUpvalue(frame.closure.Upvalues[readByte()], vm.peek(0))
+
+		case compiler.OP_GET_PROPERTY:
+			name := readToken()
+			vm.stack[len(vm.stack)-1] = vm.getProperty(vm.peek(0), name)
+		case compiler.OP_SET_PROPERTY:
+			name := readToken()
+			instance, ok := vm.peek(1).(*Instance)
+			if !ok {
+				panic(error.RuntimeError{Token: name, Message: "只有实例才有字段。"})
+			}
+			value := vm.pop()
+			instance.Fields[name.Lexeme] = value
+			vm.stack[len(vm.stack)-1] = value
+		case compiler.OP_GET_SUPER:
+			name := readToken()
+			superclass := vm.pop().(*Class)
+			vm.stack[len(vm.stack)-1] = vm.bindMethod(superclass, vm.peek(0), name)
+
+		case compiler.OP_GET_INDEX:
+			index := vm.pop()
+			vm.stack[len(vm.stack)-1] = interpreter.GetIndex(tok, vm.peek(0), index)
+		case compiler.OP_SET_INDEX:
+			value := vm.pop()
+			index := vm.pop()
+			vm.stack[len(vm.stack)-1] = interpreter.SetIndex(tok, vm.peek(0), index, value)
+		case compiler.OP_LIST:
+			count := readShort()
+			elements := make([]interface{}, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(interpreter.NewList(elements))
+		case compiler.OP_MAP:
+			count := readShort()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			m := interpreter.NewMap()
+			for i := 0; i < len(entries); i += 2 {
+				interpreter.SetIndex(tok, m, entries[i], entries[i+1])
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(m)
+
+		case compiler.OP_INTERPOLATE:
+			count := readShort()
+			var builder strings.Builder
+			for _, part := range vm.stack[len(vm.stack)-count:] {
+				builder.WriteString(interpreter.Stringify(part))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(builder.String())
+
+		case compiler.OP_EQUAL:
+			b := vm.pop()
+			vm.stack[len(vm.stack)-1] = interpreter.IsEqual(vm.peek(0), b)
+		case compiler.OP_NOT_EQUAL:
+			b := vm.pop()
+			vm.stack[len(vm.stack)-1] = !interpreter.IsEqual(vm.peek(0), b)
+		case compiler.OP_GREATER, compiler.OP_GREATER_EQUAL, compiler.OP_LESS, compiler.OP_LESS_EQUAL:
+			vm.compare(op, tok)
+		case compiler.OP_ADD, compiler.OP_SUBTRACT, compiler.OP_MULTIPLY, compiler.OP_DIVIDE, compiler.OP_MODULO:
+			vm.arithmetic(op, tok)
+		case compiler.OP_POWER, compiler.OP_BIT_AND, compiler.OP_BIT_OR, compiler.OP_BIT_XOR,
+			compiler.OP_SHIFT_LEFT, compiler.OP_SHIFT_RIGHT:
+			// 运算符标记决定具体的运算
+			b := vm.pop()
+			vm.stack[len(vm.stack)-1] = interpreter.BinaryOp(tok, vm.peek(0), b)
+		case compiler.OP_NOT:
+			vm.stack[len(vm.stack)-1] = !interpreter.IsTruthy(vm.peek(0))
+		case compiler.OP_NEGATE:
+			if n, ok := vm.peek(0).(int64); ok && n != math.MinInt64 {
+				vm.stack[len(vm.stack)-1] = -n
+				break
+			}
+			if n, ok := vm.peek(0).(float64); ok {
+				vm.stack[len(vm.stack)-1] = -n
+				break
+			}
+			vm.stack[len(vm.stack)-1] = interpreter.UnaryOp(tok, vm.peek(0))
+		case compiler.OP_BIT_NOT:
+			vm.stack[len(vm.stack)-1] = interpreter.UnaryOp(tok, vm.peek(0))
+
+		case compiler.OP_PRINT:
+			fmt.Fprintln(vm.stdout, interpreter.Stringify(vm.pop()))
+		case compiler.OP_JUMP:
+			offset := readShort()
+			frame.ip += offset
+		case compiler.OP_JUMP_IF_FALSE:
+			offset := readShort()
+			if !interpreter.IsTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case compiler.OP_SKIP_DEFAULT:
+			index := int(readByte())
+			offset := readShort()
+			// 没有传入的参数以interpreter.Missing占位
+			if vm.stack[frame.base+1+index] != interpreter.Missing {
+				frame.ip += offset
+			}
+		case compiler.OP_LOOP:
+			offset := readShort()
+			frame.ip -= offset
+			vm.cancellation.Check(nil)
+
+		case compiler.OP_CALL:
+			vm.cancellation.Check(tok)
+			argCount := int(readByte())
+			vm.callValue(vm.peek(argCount), argCount, tok)
+			loadFrame()
+		case compiler.OP_TAIL_CALL:
+			vm.cancellation.Check(tok)
+			argCount := int(readByte())
+			vm.tailCall(vm.peek(argCount), argCount, tok)
+			loadFrame()
+		case compiler.OP_INVOKE:
+			vm.cancellation.Check(tok)
+			name := readToken()
+			argCount := int(readByte())
+			vm.invoke(name, argCount, tok, false)
+			loadFrame()
+		case compiler.OP_SUPER_INVOKE:
+			vm.cancellation.Check(tok)
+			name := readToken()
+			argCount := int(readByte())
+			superclass := vm.pop().(*Class)
+			vm.invokeFromClass(superclass, name, argCount, tok, false)
+			loadFrame()
+		case compiler.OP_TAIL_INVOKE:
+			vm.cancellation.Check(tok)
+			name := readToken()
+			argCount := int(readByte())
+			vm.invoke(name, argCount, tok, true)
+			loadFrame()
+		case compiler.OP_TAIL_SUPER:
+			vm.cancellation.Check(tok)
+			name := readToken()
+			argCount := int(readByte())
+			superclass := vm.pop().(*Class)
+			vm.invokeFromClass(superclass, name, argCount, tok, true)
+			loadFrame()
+		case compiler.OP_CALL_NAMED, compiler.OP_TAIL_NAMED:
+			vm.cancellation.Check(tok)
+			names := chunk.Constants[readShort()].([]*token.Token)
+			argCount := vm.arrangeArguments(int(readByte()), names, tok)
+			vm.callOrTailCall(vm.peek(argCount), argCount, tok, op == compiler.OP_TAIL_NAMED)
+			loadFrame()
+		case compiler.OP_CLOSURE:
+			function := chunk.Constants[readShort()].(*compiler.Function)
+			closure := &Closure{
+				Function: function,
+				Upvalues: make([]*Upvalue, function.UpvalueCount),
+				Globals:  frame.closure.Globals,
+			}
+			for i := range closure.Upvalues {
+				isLocal := readByte() == 1
+				index := int(readByte())
+				if isLocal {
+					closure.Upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.Upvalues[i] = frame.closure.Upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case compiler.OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case compiler.OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+
+			base := frame.base
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:base]
+			if len(vm.frames) == vm.baseFrames {
+				return result
+			}
+
+			vm.push(result)
+			loadFrame()
+
+		case compiler.OP_CLASS:
+			vm.push(NewClass(chunk.Constants[readShort()].(string)))
+		case compiler.OP_INHERIT:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				panic(error.RuntimeError{Token: tok, Message: "父类必须是一个类。"})
+			}
+			subclass := vm.peek(0).(*Class)
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+			vm.pop()
+		case compiler.OP_METHOD:
+			name := chunk.Constants[readShort()].(string)
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*Class).Methods[name] = method
+
+		case compiler.OP_THROW:
+			value := vm.pop()
+			if pending, ok := value.(*pendingError); ok {
+				panic(pending.err)
+			}
+			panic(interpreter.NewThrow(tok, value))
+		case compiler.OP_TRY:
+			offset := readShort()
+			vm.handlers = append(vm.handlers, handler{
+				frames:      len(vm.frames),
+				stackHeight: len(vm.stack),
+				target:      frame.ip + offset,
+			})
+		case compiler.OP_POP_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compiler.OP_CATCH:
+			pending := vm.peek(0).(*pendingError)
+			vm.stack[len(vm.stack)-1] = interpreter.CaughtValue(pending.err)
+
+		case compiler.OP_IMPORT:
+			// 模块在嵌套的run中执行，调用栈可能因此重新分配
+			module := interpreter.ImportModule(vm.importer, readToken())
+			loadFrame()
+			vm.push(module)
+
+		default:
+			panic(fmt.Sprintf("未知的字节码指令: %d", op))
+		}
+	}
+}
+
+// arithmetic 执行算术运算，同类型数字的常见运算走快速路径，其余情况(包括溢出和错误)交给共享的运算语义
+func (vm *VM) arithmetic(op compiler.OpCode, tok *token.Token) {
+	b := vm.pop()
+	a := vm.peek(0)
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			var result int64
+			ok = false
+			switch op {
+			case compiler.OP_ADD:
+				result, ok = interpreter.AddInt(x, y)
+			case compiler.OP_SUBTRACT:
+				result, ok = interpreter.SubtractInt(x, y)
+			case compiler.OP_MULTIPLY:
+				result, ok = interpreter.MultiplyInt(x, y)
+			}
+			if ok {
+				vm.stack[len(vm.stack)-1] = result
+				return
+			}
+		}
+	}
+
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch op {
+			case compiler.OP_ADD:
+				vm.stack[len(vm.stack)-1] = x + y
+				return
+			case compiler.OP_SUBTRACT:
+				vm.stack[len(vm.stack)-1] = x - y
+				return
+			case compiler.OP_MULTIPLY:
+				vm.stack[len(vm.stack)-1] = x * y
+				return
+			case compiler.OP_DIVIDE:
+				if y != 0 {
+					vm.stack[len(vm.stack)-1] = x / y
+					return
+				}
+			}
+		}
+	}
+
+	vm.stack[len(vm.stack)-1] = interpreter.BinaryOp(tok, a, b)
+}
+
+// compare 执行比较运算
+func (vm *VM) compare(op compiler.OpCode, tok *token.Token) {
+	b := vm.pop()
+	a := vm.peek(0)
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			var result bool
+			switch op {
+			case compiler.OP_GREATER:
+				result = x > y
+			case compiler.OP_GREATER_EQUAL:
+				result = x >= y
+			case compiler.OP_LESS:
+				result = x < y
+			case compiler.OP_LESS_EQUAL:
+				result = x <= y
+			}
+			vm.stack[len(vm.stack)-1] = result
+			return
+		}
+	}
+
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			var result bool
+			switch op {
+			case compiler.OP_GREATER:
+				result = x > y
+			case compiler.OP_GREATER_EQUAL:
+				result = x >= y
+			case compiler.OP_LESS:
+				result = x < y
+			case compiler.OP_LESS_EQUAL:
+				result = x <= y
+			}
+			vm.stack[len(vm.stack)-1] = result
+			return
+		}
+	}
+
+	vm.stack[len(vm.stack)-1] = interpreter.BinaryOp(tok, a, b)
+}
+
+// ---------- 调用 ----------
+
+// callValue 调用栈上的值，参数位于其上方
+func (vm *VM) callValue(callee interface{}, argCount int, tok *token.Token) {
+	switch callee := callee.(type) {
+	case *Closure:
+		vm.call(callee, argCount, tok)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.Receiver
+		vm.call(callee.Method, argCount, tok)
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = NewInstance(callee)
+		if initializer, ok := callee.Methods["init"]; ok {
+			vm.call(initializer, argCount, tok)
+		} else {
+			interpreter.CheckArity(interpreter.Exactly(0), tok, argCount)
+		}
+	case interpreter.Native:
+		arguments := make([]interface{}, argCount)
+		copy(arguments, vm.stack[len(vm.stack)-argCount:])
+		result := interpreter.CallNative(callee, tok, arguments)
+		vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+		vm.push(result)
+	default:
+		panic(error.RuntimeError{Token: tok, Message: "只能调用函数和类。"})
+	}
+}
+
+// tailCall 以尾调用方式调用栈上的值
+// 被调用者是闭包或绑定方法时，用它替换当前栈帧而不是压入新栈帧，尾递归因此只占用固定的栈空间
+func (vm *VM) tailCall(callee interface{}, argCount int, tok *token.Token) {
+	if bound, ok := callee.(*BoundMethod); ok {
+		vm.stack[len(vm.stack)-argCount-1] = bound.Receiver
+		callee = bound.Method
+	}
+	closure, ok := callee.(*Closure)
+	if !ok {
+		vm.callValue(callee, argCount, tok)
+		return
+	}
+	slots := vm.prepareArguments(closure.Function, argCount, tok)
+
+	// 当前函数的局部变量不再需要，先关闭上值，再把被调用者和参数移到栈帧起始位置
+	frame := &vm.frames[len(vm.frames)-1]
+	vm.closeUpvalues(frame.base)
+	start := len(vm.stack) - slots - 1
+	copy(vm.stack[frame.base:], vm.stack[start:])
+	vm.stack = vm.stack[:frame.base+slots+1]
+
+	*frame = callFrame{closure: closure, base: frame.base}
+}
+
+// call 为闭包创建新的栈帧
+func (vm *VM) call(closure *Closure, argCount int, tok *token.Token) {
+	slots := vm.prepareArguments(closure.Function, argCount, tok)
+	// 顶层脚本的栈帧不计入调用深度
+	interpreter.CheckCallDepth(len(vm.frames), vm.maxCallDepth, tok)
+
+	vm.frames = append(vm.frames, callFrame{
+		closure: closure,
+		base:    len(vm.stack) - slots - 1,
+	})
+}
+
+// prepareArguments 检查参数个数，并使栈顶恰好有函数的每个参数的槽位，返回槽位数
+// 没有传入的参数以interpreter.Missing占位，由函数开头的OP_SKIP_DEFAULT之后的代码计算默认值；多余的参数收集为剩余参数的列表
+func (vm *VM) prepareArguments(function *compiler.Function, argCount int, tok *token.Token) int {
+	arity := interpreter.ArityRange{Min: function.MinArity, Max: function.Arity}
+	fixed := function.Arity
+	if function.Variadic {
+		arity.Max = interpreter.Variadic
+		fixed--
+	}
+	interpreter.CheckArity(arity, tok, argCount)
+
+	var rest *interpreter.List
+	if function.Variadic {
+		rest = interpreter.RestArguments(vm.stack[len(vm.stack)-argCount:], fixed)
+		if argCount > fixed {
+			vm.stack = vm.stack[:len(vm.stack)-(argCount-fixed)]
+			argCount = fixed
+		}
+	}
+	for ; argCount < fixed; argCount++ {
+		vm.push(interpreter.Missing)
+	}
+	if rest != nil {
+		vm.push(rest)
+	}
+	return function.Arity
+}
+
+// arrangeArguments 按被调用者的参数名重新排列栈顶argCount个参数中的命名参数，返回排列后的参数个数
+func (vm *VM) arrangeArguments(argCount int, names []*token.Token, tok *token.Token) int {
+	start := len(vm.stack) - argCount
+	arguments := interpreter.ArrangeArguments(vm.stack[start-1], tok, vm.stack[start:], names)
+	vm.stack = append(vm.stack[:start], arguments...)
+	return len(arguments)
+}
+
+// invoke 调用实例上的方法，同名字段优先于方法；tail为true时以尾调用方式调用
+func (vm *VM) invoke(name *token.Token, argCount int, tok *token.Token, tail bool) {
+	instance, ok := vm.peek(argCount).(*Instance)
+	if !ok {
+		// 列表等内置对象的方法先取出再调用
+		method := vm.getProperty(vm.peek(argCount), name)
+		vm.stack[len(vm.stack)-argCount-1] = method
+		vm.callOrTailCall(method, argCount, tok, tail)
+		return
+	}
+
+	if value, ok := instance.Fields[name.Lexeme]; ok {
+		vm.stack[len(vm.stack)-argCount-1] = value
+		vm.callOrTailCall(value, argCount, tok, tail)
+		return
+	}
+
+	vm.invokeFromClass(instance.Class, name, argCount, tok, tail)
+}
+
+// invokeFromClass 在类中查找方法并直接调用；tail为true时以尾调用方式调用
+func (vm *VM) invokeFromClass(class *Class, name *token.Token, argCount int, tok *token.Token, tail bool) {
+	method, ok := class.Methods[name.Lexeme]
+	if !ok {
+		vm.undefinedProperty(name)
+	}
+	if tail {
+		vm.tailCall(method, argCount, tok)
+		return
+	}
+	vm.call(method, argCount, tok)
+}
+
+// callOrTailCall 调用栈上的值，tail为true时以尾调用方式调用
+func (vm *VM) callOrTailCall(callee interface{}, argCount int, tok *token.Token, tail bool) {
+	if tail {
+		vm.tailCall(callee, argCount, tok)
+		return
+	}
+	vm.callValue(callee, argCount, tok)
+}
+
+// getProperty 读取对象的属性，实例的字段优先于方法
+func (vm *VM) getProperty(object interface{}, name *token.Token) interface{} {
+	switch object := object.(type) {
+	case *Instance:
+		if value, ok := object.Fields[name.Lexeme]; ok {
+			return value
+		}
+		return vm.bindMethod(object.Class, object, name)
+	case *interpreter.List:
+		return object.Get(name)
+	case *interpreter.Map:
+		return object.Get(name)
+	case *interpreter.ErrorValue:
+		return object.Get(name)
+	case *interpreter.Module:
+		return object.Get(name)
+	case string:
+		return interpreter.StringMethod(object, name)
+	}
+
+	panic(error.RuntimeError{Token: name, Message: "只有实例才有属性。"})
+}
+
+// bindMethod 在类中查找方法并绑定接收者
+func (vm *VM) bindMethod(class *Class, receiver interface{}, name *token.Token) *BoundMethod {
+	method, ok := class.Methods[name.Lexeme]
+	if !ok {
+		vm.undefinedProperty(name)
+	}
+	return &BoundMethod{Receiver: receiver, Method: method}
+}
+
+// undefinedProperty 报告未定义的属性
+func (vm *VM) undefinedProperty(name *token.Token) {
+	panic(error.RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("未定义的属性 '%s'。", name.Lexeme),
+	})
+}
+
+// ---------- 上值 ----------
+
+// captureUpvalue 为栈上的变量创建上值，同一变量共享同一个上值
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	var prev *Upvalue
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.slot > slot {
+		prev = upvalue
+		upvalue = upvalue.next
+	}
+
+	if upvalue != nil && upvalue.slot == slot {
+		return upvalue
+	}
+
+	created := &Upvalue{slot: slot, isOpen: true, next: upvalue}
+	if prev == nil {
+		vm.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+// closeUpvalues 关闭所有指向slot及其以上槽位的上值
+func (vm *VM) closeUpvalues(slot int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= slot {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.isOpen = false
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+// getUpvalue 读取上值
+func (vm *VM) getUpvalue(upvalue *Upvalue) interface{} {
+	if upvalue.isOpen {
+		return vm.stack[upvalue.slot]
+	}
+	return upvalue.closed
+}
+
+// setUpvalue 写入上值
+func (vm *VM) setUpvalue(upvalue *Upvalue, value interface{}) {
+	if upvalue.isOpen {
+		vm.stack[upvalue.slot] = value
+		return
+	}
+	upvalue.closed = value
+}
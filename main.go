@@ -1,45 +1,86 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/aixiasang/goLox/lox"
 )
 
 func main() {
-	loxInstance := lox.NewLox()
 	args := os.Args[1:]
 
 	// 处理命令行参数
 	var scriptPath string
 	var debug bool
+	var maxCallDepth int
+	backend := lox.BackendTree
 
-	// 检查是否有--debug或-d标志
+	// 检查是否有--debug/-d、--backend和--max-depth标志
 	for i := 0; i < len(args); i++ {
 		if args[i] == "--debug" || args[i] == "-d" {
 			debug = true
-			// 从参数列表中移除debug标志
-			if i < len(args)-1 {
-				args = append(args[:i], args[i+1:]...)
-			} else {
-				args = args[:i]
+		} else if strings.HasPrefix(args[i], "--backend=") {
+			b, err := lox.ParseBackend(strings.TrimPrefix(args[i], "--backend="))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(64)
 			}
-			i-- // 调整索引，因为我们移除了一个元素
+			backend = b
+		} else if strings.HasPrefix(args[i], "--max-depth=") {
+			depth, err := strconv.Atoi(strings.TrimPrefix(args[i], "--max-depth="))
+			if err != nil || depth <= 0 {
+				fmt.Println("最大调用深度必须是正整数:", strings.TrimPrefix(args[i], "--max-depth="))
+				os.Exit(64)
+			}
+			maxCallDepth = depth
+		} else {
+			continue
 		}
+
+		// 从参数列表中移除已处理的标志
+		args = append(args[:i], args[i+1:]...)
+		i-- // 调整索引，因为我们移除了一个元素
 	}
 
-	// 设置调试模式
-	loxInstance.SetDebug(debug)
+	loxInstance := lox.New(lox.Options{
+		Backend:      backend,
+		Debug:        debug,
+		MaxCallDepth: maxCallDepth,
+	})
 
 	// 检查参数执行文件，否则启动REPL
 	if len(args) > 1 {
-		fmt.Println("用法: golox [脚本] [--debug/-d]")
+		fmt.Println("用法: golox [脚本] [--debug/-d] [--backend=tree|indexed|vm] [--max-depth=N]")
 		os.Exit(64)
 	} else if len(args) == 1 {
 		scriptPath = args[0]
-		loxInstance.RunFile(scriptPath)
+		if err := loxInstance.RunFile(scriptPath); err != nil {
+			os.Exit(exitCode(err))
+		}
 	} else {
 		loxInstance.RunPrompt()
 	}
 }
+
+// exitCode 根据错误类型返回进程退出码
+func exitCode(err error) int {
+	var runtimeError *lox.RuntimeError
+	if errors.As(err, &runtimeError) {
+		return 70
+	}
+
+	var scanError *lox.ScanError
+	var parseError *lox.ParseError
+	var resolveError *lox.ResolveError
+	if errors.As(err, &scanError) || errors.As(err, &parseError) || errors.As(err, &resolveError) {
+		return 65
+	}
+
+	// 无法读取脚本文件
+	fmt.Fprintln(os.Stderr, err)
+	return 66
+}